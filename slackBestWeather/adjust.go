@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// adjustFlag is a repeatable Location=delta flag, added to a location's
+// score after scoring and before ranking, eg a boost for home
+type adjustFlag map[string]float64
+
+func (a adjustFlag) String() string {
+	var s []string
+	for k, v := range a {
+		s = append(s, fmt.Sprintf("%s=%+g", k, v))
+	}
+	return strings.Join(s, ",")
+}
+
+func (a adjustFlag) Set(s string) error {
+	k, v, err := parseLocFloat("adjust", s)
+	if err != nil {
+		return err
+	}
+	a[k] = v
+	return nil
+}
+
+// weigh scales n by the location's weight
+func weigh(name string, l loc, n float64) float64 {
+	if l.weight == 0 || l.weight == 1 {
+		return n
+	}
+	vlog("%s: score %s weighted by %g to %s", name, formatNum(n), l.weight, formatNum(n*l.weight))
+	return n * l.weight
+}
+
+// adjust applies the -adjust flag, or failing that the locations file
+// adjust, to n
+func (a adjustFlag) adjust(name string, l loc, n float64) float64 {
+	d := a.amount(name, l)
+	if d == 0 {
+		return n
+	}
+	vlog("%s: score %s adjusted by %+g to %s", name, formatNum(n), d, formatNum(n+d))
+	return n + d
+}
+
+// amount is what adjust adds for name
+func (a adjustFlag) amount(name string, l loc) float64 {
+	if d, ok := a[name]; ok {
+		return d
+	}
+	return l.adjust
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// auditRecord is everything that went into one location's score, a line
+// of -audit-file. It's for working out better weights offline, so unlike
+// the display it keeps the raw inputs and every factor at full precision.
+type auditRecord struct {
+	RunID    string      `json:"run_id"`
+	Time     time.Time   `json:"time"`
+	Location string      `json:"location"`
+	Config   auditConfig `json:"config"`
+	// Weight and Adjust are the location's, applied in that order to Raw
+	// to get Score
+	Weight float64    `json:"weight"`
+	Adjust float64    `json:"adjust"`
+	Days   []auditDay `json:"days,omitempty"`
+	Raw    float64    `json:"raw"`
+	Score  float64    `json:"score"`
+}
+
+// auditConfig are the scoreConfig settings, which change what the same
+// inputs score
+type auditConfig struct {
+	Mode      string  `json:"mode"`
+	Days      int     `json:"days"`
+	Decay     float64 `json:"decay,omitempty"`
+	Units     string  `json:"units"`
+	FeelsLike bool    `json:"feels_like,omitempty"`
+	// ComfortModel is -comfort-model, when it isn't custom
+	ComfortModel string             `json:"comfort_model,omitempty"`
+	Sunshine     bool               `json:"sunshine,omitempty"`
+	DewPoint     bool               `json:"dew_point,omitempty"`
+	Surprise     float64            `json:"surprise,omitempty"`
+	Expr         string             `json:"expr,omitempty"`
+	Floors       map[string]float64 `json:"floors,omitempty"`
+	Curves       map[string]string  `json:"curves,omitempty"`
+	// MissingSky is -missing-sky, when it isn't exclude
+	MissingSky string `json:"missing_sky,omitempty"`
+}
+
+// auditDay is a scored day's inputs, in the forecast's units, and what
+// came of them. Factors is only there for the comfort score (daily and
+// peak), the other modes don't break down.
+type auditDay struct {
+	Date              string        `json:"date"`
+	TemperatureMax    float64       `json:"temperatureMax"`
+	TemperatureMin    float64       `json:"temperatureMin"`
+	Humidity          float64       `json:"humidity"`
+	CloudCover        float64       `json:"cloudCover"`
+	PrecipProbability float64       `json:"precipProbability"`
+	DewPoint          float64       `json:"dewPoint"`
+	WindSpeed         float64       `json:"windSpeed"`
+	Extra             dayExtra      `json:"extra"`
+	Factors           *auditFactors `json:"factors,omitempty"`
+	// Weight is the day's share of the average with -day-decay
+	Weight float64 `json:"weight,omitempty"`
+	Score  float64 `json:"score"`
+}
+
+type auditFactors struct {
+	High     float64 `json:"high"`
+	Low      float64 `json:"low"`
+	Clouds   float64 `json:"clouds"`
+	Precip   float64 `json:"precip"`
+	Humidity float64 `json:"humidity"`
+	Bonus    float64 `json:"bonus"`
+}
+
+// auditConfigOf is the audit record of sc
+func auditConfigOf(sc scoreConfig, units string) auditConfig {
+	c := auditConfig{Mode: sc.Mode, Days: sc.Days, Decay: sc.Decay, Units: units, FeelsLike: sc.FeelsLike,
+		Sunshine: sc.Sunshine, DewPoint: sc.DewPoint, Surprise: sc.Surprise}
+	if sc.ComfortModel != "custom" {
+		c.ComfortModel = sc.ComfortModel
+	}
+	if sc.Expr != nil {
+		c.Expr = sc.Expr.String()
+	}
+	if sc.Missing.Mode == "neutral" {
+		c.MissingSky = fmt.Sprintf("neutral %g", sc.Missing.Value)
+	} else if sc.Missing.Mode == "skip" {
+		c.MissingSky = "skip"
+	}
+	for k, v := range sc.Floor.Fields() {
+		if *v > 0 {
+			if c.Floors == nil {
+				c.Floors = make(map[string]float64)
+			}
+			c.Floors[k] = *v
+		}
+	}
+	for k, v := range sc.Curve.Fields() {
+		if *v != "" && *v != weather.CurveLinear {
+			if c.Curves == nil {
+				c.Curves = make(map[string]string)
+			}
+			c.Curves[k] = string(*v)
+		}
+	}
+	return c
+}
+
+// auditScore records how l's forecast f came to score n
+func auditScore(name string, l loc, f *forecast, sc scoreConfig, adj adjustFlag, n float64) auditRecord {
+	r := auditRecord{Location: name, Config: auditConfigOf(sc, f.Units), Weight: l.weight, Adjust: adj.amount(name, l),
+		Raw: weather.Score(f, l.scoring(), sc), Score: n}
+	if r.Weight == 0 {
+		r.Weight = 1
+	}
+	for i, d := range weather.ScoredDays(f, sc) {
+		ad := auditDay{
+			Date:              d.Time.Format("2006-01-02"),
+			TemperatureMax:    d.TemperatureMax,
+			TemperatureMin:    d.TemperatureMin,
+			Humidity:          d.Humidity,
+			CloudCover:        d.CloudCover,
+			PrecipProbability: d.PrecipProbability,
+			DewPoint:          d.DewPoint,
+			WindSpeed:         d.WindSpeed,
+			Extra:             d.Extra,
+			Score:             sc.Day(d, f.Units, l.scoring()),
+		}
+		if tz, err := time.LoadLocation(f.Timezone); err == nil {
+			ad.Date = d.Time.In(tz).Format("2006-01-02")
+		}
+		if sc.Mode == "daily" || sc.Mode == "peak" {
+			fs := weather.FactorsOf(d, f.Units, l.scoring(), sc)
+			ad.Factors = &auditFactors{High: fs.High, Low: fs.Low, Clouds: fs.Clouds, Precip: fs.Precip, Humidity: fs.Humidity, Bonus: fs.Bonus}
+		}
+		if sc.Decay > 0 && sc.Mode != "peak" {
+			ad.Weight = sc.DayWeight(i)
+		}
+		r.Days = append(r.Days, ad)
+	}
+	return r
+}
+
+// appendAudit adds a run's records to the audit file, in one O_APPEND
+// write like appendHistory
+func appendAudit(fn string, recs []auditRecord, now time.Time) error {
+	id := fmt.Sprintf("%d", now.UnixNano())
+	var buf bytes.Buffer
+	for _, r := range recs {
+		r.RunID, r.Time = id, now
+		b, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("audit %s: %v", fn, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// a band labels comfort indexes from min up to the next band
+type band struct {
+	min   float64
+	label string
+}
+
+// bands is a -bands flag like "85:Gorgeous,70:Nice,50:Meh,0:Grim",
+// kept sorted from the highest band down
+type bands []band
+
+var defaultBands = "85:Gorgeous,70:Nice,50:Meh,0:Grim"
+
+func (b *bands) String() string {
+	var s []string
+	for _, v := range *b {
+		s = append(s, fmt.Sprintf("%g:%s", v.min, v.label))
+	}
+	return strings.Join(s, ",")
+}
+
+func (b *bands) Set(s string) error {
+	var nb bands
+	for _, p := range strings.Split(s, ",") {
+		i := strings.Index(p, ":")
+		if i <= 0 {
+			return fmt.Errorf("band %q should look like min:label", p)
+		}
+		min, err := strconv.ParseFloat(strings.TrimSpace(p[:i]), 64)
+		if err != nil {
+			return fmt.Errorf("band %q: %v", p, err)
+		}
+		nb = append(nb, band{min: min, label: strings.TrimSpace(p[i+1:])})
+	}
+	sort.Slice(nb, func(i, j int) bool { return nb[i].min > nb[j].min })
+	*b = nb
+	return nil
+}
+
+// label is the band the comfort index c falls in, or "" if it's below
+// every band
+func (b bands) label(c float64) string {
+	for _, v := range b {
+		if c >= v.min {
+			return v.label
+		}
+	}
+	return ""
+}
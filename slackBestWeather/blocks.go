@@ -0,0 +1,70 @@
+package main
+
+import "fmt"
+
+// Block Kit, which slack prefers to attachments these days
+type blockText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type block struct {
+	Type   string      `json:"type"`
+	Text   *blockText  `json:"text,omitempty"`
+	Fields []blockText `json:"fields,omitempty"`
+	// Elements are blockTexts in a context block, blockButtons in an
+	// actions block
+	Elements []interface{} `json:"elements,omitempty"`
+}
+
+// blockFields are the ranking value, and the raw numbers with
+// -verbose-fields
+func (so slackOpts) blockFields(v locScore) []blockText {
+	fs := []blockText{mrkdwn(fmt.Sprintf("*%s*\n%s", so.key.title, so.key.format(so.key.value(v))))}
+	if so.numbers {
+		for _, f := range metricFields(v) {
+			fs = append(fs, mrkdwn(fmt.Sprintf("*%s*\n%s", f.Title, f.Value)))
+		}
+	}
+	return fs
+}
+
+func mrkdwn(s string) blockText { return blockText{Type: "mrkdwn", Text: s} }
+
+// blockKit is a section per location, under a header per region, with the
+// footer as a context block at the end
+func (so slackOpts) blockKit(res []locScore, footer string) []block {
+	rank := make(map[string]int)
+	for i, v := range res {
+		rank[v.Location] = i + 1
+	}
+	var bs []block
+	for _, g := range groupByRegion(so.display(res)) {
+		if g.region != "" {
+			bs = append(bs, block{Type: "header", Text: &blockText{Type: "plain_text", Text: g.region}})
+		}
+		for _, v := range g.scores {
+			name := v.displayName()
+			if v.Here {
+				name = ":house: " + name
+			}
+			t := fmt.Sprintf("*%d. %s* %s\n%s", rank[v.Location], name, emoji(v.Condition), so.details(v))
+			bs = append(bs, block{
+				Type:   "section",
+				Text:   &blockText{Type: "mrkdwn", Text: t},
+				Fields: so.blockFields(v),
+			})
+			for _, m := range so.marks(v) {
+				bs = append(bs, block{Type: "context", Elements: []interface{}{mrkdwn(m)}})
+			}
+		}
+		bs = append(bs, block{Type: "divider"})
+	}
+	if footer != "" {
+		bs = append(bs, block{Type: "context", Elements: []interface{}{mrkdwn(footer)}})
+	}
+	if so.refreshButton {
+		bs = append(bs, refreshBlock())
+	}
+	return bs
+}
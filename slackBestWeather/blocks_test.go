@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBlockKitParity is -slack-blocks, and so a refreshed message,
+// showing everything the attachments do for a location
+func TestBlockKitParity(t *testing.T) {
+	dist, raw := 120.0, 310.0
+	v := locScore{Location: "Islip", Score: 350, Summary: "Clear", Units: "us", Streak: 3,
+		Fetched: time.Date(2016, 10, 12, 15, 0, 0, 0, time.UTC), Distance: &dist, Aliases: []string{"Long Island"},
+		RawScore: &raw, Reference: true, BeatsReference: true, Notes: []string{"gusty"}}
+	so := slackOpts{key: sortKeys["score"], order: "score", streakAbove: 300, localTimes: true, refs: "Boston"}
+	res := []locScore{v}
+	var blocks []string
+	for _, b := range so.blockKit(res, "") {
+		if b.Text != nil {
+			blocks = append(blocks, b.Text.Text)
+		}
+		for _, e := range b.Elements {
+			if e, ok := e.(blockText); ok {
+				blocks = append(blocks, e.Text)
+			}
+		}
+	}
+	text := strings.Join(blocks, "\n")
+	var want []string
+	for _, f := range so.attachments(res)[0].Fields[2:] {
+		want = append(want, strings.Split(f.Value, "\n")...)
+	}
+	if len(want) < 9 {
+		t.Fatalf("the attachment only has %q, the test needs every note in it", want)
+	}
+	for _, w := range want {
+		if !strings.Contains(text, w) {
+			t.Errorf("blocks are missing %q:\n%s", w, text)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultBuckets are the -bucket-bands, on the same comfort index the
+// -bands labels use
+var defaultBuckets = "70:Nice,45:Okay,0:Avoid"
+
+// bucketAttachments is the -buckets report: an attachment per band, best
+// first, listing its locations in rank order without their scores.
+// Locations below every band go in the last one.
+func (so slackOpts) bucketAttachments(res []locScore) []attachment {
+	b := so.buckets
+	groups := make([][]locScore, len(b))
+	for _, v := range res {
+		i := len(b) - 1
+		for j, band := range b {
+			if v.Comfort >= band.min {
+				i = j
+				break
+			}
+		}
+		groups[i] = append(groups[i], v)
+	}
+	var as []attachment
+	for i, g := range groups {
+		if len(g) == 0 {
+			continue
+		}
+		var lines []string
+		for _, v := range g {
+			lines = append(lines, fmt.Sprintf("• %s %s %s", v.Location, emoji(v.Condition), v.summary()))
+		}
+		// the top band gets the best color, the bottom the worst
+		c := 1.0
+		if len(b) > 1 {
+			c = 1 - float64(i)/float64(len(b)-1)
+		}
+		as = append(as, attachment{
+			Title:    b[i].label,
+			Fallback: fmt.Sprintf("%s: %d locations", b[i].label, len(g)),
+			Text:     strings.Join(lines, "\n"),
+			Color:    getValueBetweenTwoFixedColors(c),
+		})
+	}
+	return as
+}
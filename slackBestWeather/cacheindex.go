@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheIndexFile says what's in each of the opaque cache files
+const cacheIndexFile = "cache/index.json"
+
+type cacheEntry struct {
+	Key      string    `json:"key"`
+	Location string    `json:"location,omitempty"`
+	Date     string    `json:"date"`
+	Fetched  time.Time `json:"fetched"`
+}
+
+// cacheIndex maps a cache file's base name to what's in it
+type cacheIndex map[string]cacheEntry
+
+// cacheLocks has a lock per cache key, so two fetches of the same key
+// don't race each other to the network and the file. indexLock guards the
+// read, update, write of the index.
+var (
+	cacheLocks = struct {
+		sync.Mutex
+		m map[string]*sync.Mutex
+	}{m: make(map[string]*sync.Mutex)}
+	indexLock sync.Mutex
+)
+
+// lockCache locks the cache key and returns the unlock
+func lockCache(key string) func() {
+	cacheLocks.Lock()
+	l, ok := cacheLocks.m[key]
+	if !ok {
+		l = new(sync.Mutex)
+		cacheLocks.m[key] = l
+	}
+	cacheLocks.Unlock()
+	l.Lock()
+	return l.Unlock
+}
+
+func loadCacheIndex() cacheIndex {
+	idx := make(cacheIndex)
+	buf, err := ioutil.ReadFile(cacheIndexFile)
+	if err != nil {
+		return idx
+	}
+	if err := json.Unmarshal(buf, &idx); err != nil {
+		// it's only an index, start a new one
+		warn("", err, "ignoring a bad "+cacheIndexFile)
+		return make(cacheIndex)
+	}
+	return idx
+}
+
+func (idx cacheIndex) save() error {
+	buf, err := json.MarshalIndent(idx, "", " ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(cacheIndexFile, buf, 0640)
+}
+
+// indexCache records a freshly written cache file
+func indexCache(fn, key string, fetched time.Time) {
+	indexLock.Lock()
+	defer indexLock.Unlock()
+	idx := loadCacheIndex()
+	e := cacheEntry{Key: key, Location: locationAt(key), Fetched: fetched}
+	if i := strings.LastIndex(key, "|"); i >= 0 {
+		e.Date = key[i+1:]
+	}
+	idx[filepath.Base(fn)] = e
+	if err := idx.save(); err != nil && !os.IsNotExist(err) {
+		warn("", err, "saving the cache index failed")
+	}
+}
+
+// locationAt is the name of the configured location nearest the
+// coordinates in a cache key. They were rounded (see -coord-precision) so this
+// looks for the closest rather than an exact match.
+func locationAt(key string) string {
+	parts := strings.Split(key, "|")
+	if len(parts) < 3 {
+		return ""
+	}
+	lat, err1 := strconv.ParseFloat(parts[1], 64)
+	lng, err2 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil {
+		return ""
+	}
+	name, best := "", 0.5
+	for _, k := range locationNames(deterministic) {
+		l := locations[k]
+		if d := math.Max(math.Abs(l.lat-lat), math.Abs(l.lng-lng)); d <= best {
+			name, best = k, d
+		}
+	}
+	return name
+}
+
+// pruneCache deletes the cache files fetched more than keep ago, going by
+// the index or the file's time when it isn't indexed, and tidies the
+// index to match
+func pruneCache(keep time.Duration) (int, error) {
+	indexLock.Lock()
+	defer indexLock.Unlock()
+	idx := loadCacheIndex()
+	files, err := filepath.Glob("cache/v2-*")
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-keep)
+	exists := make(map[string]bool)
+	n := 0
+	for _, fn := range files {
+		if strings.Contains(fn, ".tmp") {
+			continue
+		}
+		base := filepath.Base(fn)
+		t := idx[base].Fetched
+		if t.IsZero() {
+			fi, err := os.Stat(fn)
+			if err != nil {
+				continue
+			}
+			t = fi.ModTime()
+		}
+		if t.After(cutoff) {
+			exists[base] = true
+			continue
+		}
+		if err := os.Remove(fn); err != nil {
+			return n, err
+		}
+		vlog("pruned %s %s", base, idx[base].Key)
+		n++
+	}
+	for k := range idx {
+		if !exists[k] {
+			delete(idx, k)
+		}
+	}
+	return n, idx.save()
+}
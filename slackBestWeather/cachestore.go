@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// a cacheStore keeps fetched responses by cache key
+type cacheStore interface {
+	load(key string) (buf []byte, fetched time.Time, ok bool)
+	save(key string, buf []byte, fetched time.Time) error
+	// prune deletes what was fetched longer than keep ago
+	prune(keep time.Duration) (int, error)
+}
+
+// cache is the -compact-cache store, or a file per key by default
+var cache cacheStore = fileCache{}
+
+// fileCache is a file per key under cache/, listed in the cache index
+type fileCache struct{}
+
+func (fileCache) load(key string) ([]byte, time.Time, bool) {
+	fn := cacheFile(key)
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil || len(buf) == 0 {
+		return nil, time.Time{}, false
+	}
+	var t time.Time
+	if fi, err := os.Stat(fn); err == nil {
+		t = fi.ModTime()
+	}
+	return buf, t, true
+}
+
+func (fileCache) save(key string, buf []byte, fetched time.Time) error {
+	fn := cacheFile(key)
+	if err := writeFileAtomic(fn, buf, 0740); err != nil {
+		return err
+	}
+	indexCache(fn, key, fetched)
+	return nil
+}
+
+func (fileCache) prune(keep time.Duration) (int, error) { return pruneCache(keep) }
+
+// packFile is where -compact-cache keeps everything
+const packFile = "cache/cache.gob"
+
+type packEntry struct {
+	Data    []byte
+	Fetched time.Time
+}
+
+// packCache is the whole cache in one file, for when thousands of little
+// files are a problem. It's read once and rewritten (atomically) on each
+// save, which is fine for the few dozen entries a run makes.
+type packCache struct {
+	mu      sync.Mutex
+	entries map[string]packEntry
+}
+
+func newPackCache() (*packCache, error) {
+	p := &packCache{entries: make(map[string]packEntry)}
+	buf, err := ioutil.ReadFile(packFile)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&p.entries); err != nil {
+		// it's only a cache, start over
+		warn("", err, "ignoring a bad "+packFile)
+		p.entries = make(map[string]packEntry)
+	}
+	return p, nil
+}
+
+func (p *packCache) load(key string) ([]byte, time.Time, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[key]
+	return e.Data, e.Fetched, ok && len(e.Data) > 0
+}
+
+func (p *packCache) save(key string, buf []byte, fetched time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries[key] = packEntry{Data: buf, Fetched: fetched}
+	return p.write()
+}
+
+func (p *packCache) prune(keep time.Duration) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cutoff := time.Now().Add(-keep)
+	n := 0
+	for k, e := range p.entries {
+		if e.Fetched.Before(cutoff) {
+			vlog("pruned %s", k)
+			delete(p.entries, k)
+			n++
+		}
+	}
+	return n, p.write()
+}
+
+func (p *packCache) write() error {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(p.entries); err != nil {
+		return err
+	}
+	return writeFileAtomic(packFile, b.Bytes(), 0640)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// cardOpts size the -format png leaderboard card
+type cardOpts struct {
+	// width and rowHeight are in pixels
+	width, rowHeight int
+	// scale is the font size, how many pixels each dot of the 5x7 font is
+	scale int
+}
+
+var (
+	cardBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	cardText       = color.RGBA{0x22, 0x22, 0x22, 0xff}
+	cardFaint      = color.RGBA{0x77, 0x77, 0x77, 0xff}
+	cardStripe     = color.RGBA{0xf4, 0xf4, 0xf4, 0xff}
+	cardSun        = color.RGBA{0xf5, 0xb8, 0x00, 0xff}
+	cardCloud      = color.RGBA{0x9a, 0xa4, 0xae, 0xff}
+	cardRain       = color.RGBA{0x2a, 0x7a, 0xd8, 0xff}
+	cardSnow       = color.RGBA{0x8c, 0xc8, 0xf0, 0xff}
+)
+
+// writeCard draws the ranked leaderboard as a png: a row per location
+// with its rank, an icon for the sky, the name, a bar in its color and
+// the score and high/low
+func writeCard(w io.Writer, r *report, so slackOpts) error {
+	img, err := drawCard(r, so)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
+
+func drawCard(r *report, so slackOpts) (*image.RGBA, error) {
+	co := so.card
+	res := r.Results
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no results to draw")
+	}
+	adv := 6 * co.scale
+	pad := co.rowHeight / 4
+	header := 2 * 8 * (co.scale + 1)
+	// rank, icon, name, bar and score, then the high/low on the right
+	inset := co.rowHeight / 8
+	iconSize := co.rowHeight - 2*inset
+	nameX := pad + 3*adv + iconSize + pad
+	nameChars := co.width * 35 / 100 / adv
+	barX := nameX + nameChars*adv + pad
+	right := 16 * adv
+	barMax := co.width - pad - right - barX
+	if barMax < 2*adv || iconSize < 7 {
+		return nil, fmt.Errorf("a %dx%d card is too small for font scale %d", co.width, co.rowHeight, co.scale)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, co.width, header+len(res)*co.rowHeight+pad))
+	fill(img, img.Bounds(), cardBackground)
+
+	title := "BEST WEATHER"
+	drawText(img, pad, pad, co.scale+1, cardText, title)
+	drawText(img, pad, pad+8*(co.scale+1), co.scale, cardFaint, r.Time.Format("Mon Jan 2")+", "+r.Mode+" mode")
+
+	rank := make(map[string]int)
+	for i, v := range res {
+		rank[v.Location] = i + 1
+	}
+	textY := (co.rowHeight - 7*co.scale) / 2
+	for i, v := range so.display(res) {
+		y := header + i*co.rowHeight
+		if i%2 == 1 {
+			fill(img, image.Rect(0, y, co.width, y+co.rowHeight), cardStripe)
+		}
+		drawText(img, pad, y+textY, co.scale, cardFaint, strconv.Itoa(rank[v.Location]))
+		drawIcon(img, image.Rect(pad+3*adv, y+inset, pad+3*adv+iconSize, y+inset+iconSize), v.Condition)
+		drawText(img, nameX, y+textY, co.scale, cardText, truncate(v.displayName(), nameChars))
+
+		n := normalize(so.key.value(v), so.key.value(res[len(res)-1]), so.key.value(res[0]))
+		// the worst still gets a sliver so it reads as a bar
+		bw := int(float64(barMax)*n) + co.scale
+		fill(img, image.Rect(barX, y+pad, barX+bw, y+co.rowHeight-pad), parseHexColor(so.color(v, res)))
+		drawText(img, barX+bw+co.scale*2, y+textY, co.scale, cardText, so.key.format(so.key.value(v)))
+		hl := fmt.Sprintf("%.0f°/%.0f°", v.TemperatureMax, v.TemperatureMin)
+		drawText(img, co.width-pad-textWidth(hl, co.scale), y+textY, co.scale, cardFaint, hl)
+	}
+	return img, nil
+}
+
+// postCard uploads the card to a slack channel, it takes the web api and
+// $SLACK_TOKEN like -post-as-snippet
+func postCard(so slackOpts, channel string, r *report) error {
+	var img bytes.Buffer
+	if err := writeCard(&img, r, so); err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	res := r.Results
+	for k, v := range map[string]string{
+		"channels":        channel,
+		"filename":        "best-weather.png",
+		"title":           "Best weather competition",
+		"initial_comment": fmt.Sprintf("%s wins with %s", res[0].Location, so.key.format(so.key.value(res[0]))),
+	} {
+		mw.WriteField(k, v)
+	}
+	fw, err := mw.CreateFormFile("file", "best-weather.png")
+	if err != nil {
+		return err
+	}
+	fw.Write(img.Bytes())
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	return callSlackAPI("files.upload", mw.FormDataContentType(), body.Bytes())
+}
+
+// truncate cuts s to n characters, marking the cut
+func truncate(s string, n int) string {
+	rs := []rune(s)
+	if len(rs) <= n {
+		return s
+	}
+	if n < 2 {
+		return string(rs[:n])
+	}
+	return string(rs[:n-1]) + "."
+}
+
+// parseHexColor reads a #rrggbb color, grey if it isn't one
+func parseHexColor(s string) color.RGBA {
+	v, err := strconv.ParseUint(strings.TrimPrefix(s, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(s, "#")) != 6 {
+		return cardCloud
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}
+}
+
+func fill(img *image.RGBA, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+func fillCircle(img *image.RGBA, cx, cy, r int, c color.Color) {
+	for y := -r; y <= r; y++ {
+		for x := -r; x <= r; x++ {
+			if x*x+y*y <= r*r {
+				img.Set(cx+x, cy+y, c)
+			}
+		}
+	}
+}
+
+// drawIcon draws a small picture of the sky in r
+func drawIcon(img *image.RGBA, r image.Rectangle, c condition) {
+	s := r.Dx()
+	cloud := func(top int, col color.Color) {
+		// three puffs on a flat bottom
+		bottom := top + s/3
+		fillCircle(img, r.Min.X+s/3, top+s/6, s/6, col)
+		fillCircle(img, r.Min.X+s*3/5, top+s/8, s/5, col)
+		fill(img, image.Rect(r.Min.X+s/6, top+s/6, r.Min.X+s*5/6, bottom), col)
+		fillCircle(img, r.Min.X+s*4/5, top+s/5, s/7, col)
+	}
+	drops := func(col color.Color, dot bool) {
+		y := r.Min.Y + s*2/3
+		for i := 1; i <= 3; i++ {
+			x := r.Min.X + i*s/4
+			if dot {
+				fillCircle(img, x, y+s/8, s/14+1, col)
+			} else {
+				fill(img, image.Rect(x-1, y, x+1, y+s/4), col)
+			}
+		}
+	}
+	switch c {
+	case weather.CondClear:
+		fillCircle(img, r.Min.X+s/2, r.Min.Y+s/2, s*2/5, cardSun)
+	case weather.CondPartlyCloudy:
+		fillCircle(img, r.Min.X+s*3/5, r.Min.Y+s*2/5, s/3, cardSun)
+		cloud(r.Min.Y+s/2, cardCloud)
+	case weather.CondCloudy:
+		cloud(r.Min.Y+s/4, cardCloud)
+	case weather.CondRain:
+		cloud(r.Min.Y+s/8, cardCloud)
+		drops(cardRain, false)
+	case weather.CondSnow:
+		cloud(r.Min.Y+s/8, cardCloud)
+		drops(cardSnow, true)
+	case weather.CondSleet:
+		cloud(r.Min.Y+s/8, cardCloud)
+		drops(cardRain, true)
+	case weather.CondWind:
+		for i := 1; i <= 3; i++ {
+			y := r.Min.Y + i*s/4
+			fill(img, image.Rect(r.Min.X+(i%2)*s/6, y-1, r.Max.X-((i+1)%2)*s/6, y+1), cardCloud)
+		}
+	case weather.CondFog:
+		for i := 1; i <= 3; i++ {
+			y := r.Min.Y + i*s/4
+			fill(img, image.Rect(r.Min.X, y-s/16-1, r.Max.X, y+s/16), cardCloud)
+		}
+	default:
+		scale := s / 8
+		if scale < 1 {
+			scale = 1
+		}
+		drawText(img, r.Min.X+(s-5*scale)/2, r.Min.Y+(s-7*scale)/2, scale, cardFaint, "?")
+	}
+}
+
+// textWidth is how wide drawText makes s
+func textWidth(s string, scale int) int {
+	return len([]rune(s)) * 6 * scale
+}
+
+// drawText writes s in the 5x7 font with its top left at x, y, each dot
+// scale pixels square. Lower case is drawn as upper case and anything
+// the font doesn't have as a box.
+func drawText(img *image.RGBA, x, y, scale int, c color.Color, s string) {
+	for _, r := range s {
+		g, ok := glyphs[unicode.ToUpper(r)]
+		if !ok {
+			g = [7]uint8{0x1f, 0x11, 0x11, 0x11, 0x11, 0x11, 0x1f}
+		}
+		for row, bits := range g {
+			for col := 0; col < 5; col++ {
+				if bits&(0x10>>col) != 0 {
+					fill(img, image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale), c)
+				}
+			}
+		}
+		x += 6 * scale
+	}
+}
+
+// glyphs is a 5x7 font, a row per byte with the left dot in bit 4.
+// There's no font rendering in the standard library and this is enough
+// for names and numbers.
+var glyphs = map[rune][7]uint8{
+	' ':  {},
+	'0':  {0x0e, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0e},
+	'1':  {0x04, 0x0c, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'2':  {0x0e, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1f},
+	'3':  {0x1f, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0e},
+	'4':  {0x02, 0x06, 0x0a, 0x12, 0x1f, 0x02, 0x02},
+	'5':  {0x1f, 0x10, 0x1e, 0x01, 0x01, 0x11, 0x0e},
+	'6':  {0x06, 0x08, 0x10, 0x1e, 0x11, 0x11, 0x0e},
+	'7':  {0x1f, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8':  {0x0e, 0x11, 0x11, 0x0e, 0x11, 0x11, 0x0e},
+	'9':  {0x0e, 0x11, 0x11, 0x0f, 0x01, 0x02, 0x0c},
+	'A':  {0x0e, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'B':  {0x1e, 0x11, 0x11, 0x1e, 0x11, 0x11, 0x1e},
+	'C':  {0x0e, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0e},
+	'D':  {0x1c, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1c},
+	'E':  {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x1f},
+	'F':  {0x1f, 0x10, 0x10, 0x1e, 0x10, 0x10, 0x10},
+	'G':  {0x0e, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0f},
+	'H':  {0x11, 0x11, 0x11, 0x1f, 0x11, 0x11, 0x11},
+	'I':  {0x0e, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0e},
+	'J':  {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0c},
+	'K':  {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L':  {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1f},
+	'M':  {0x11, 0x1b, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N':  {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O':  {0x0e, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},
+	'P':  {0x1e, 0x11, 0x11, 0x1e, 0x10, 0x10, 0x10},
+	'Q':  {0x0e, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0d},
+	'R':  {0x1e, 0x11, 0x11, 0x1e, 0x14, 0x12, 0x11},
+	'S':  {0x0f, 0x10, 0x10, 0x0e, 0x01, 0x01, 0x1e},
+	'T':  {0x1f, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0e},
+	'V':  {0x11, 0x11, 0x11, 0x11, 0x11, 0x0a, 0x04},
+	'W':  {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0a},
+	'X':  {0x11, 0x11, 0x0a, 0x04, 0x0a, 0x11, 0x11},
+	'Y':  {0x11, 0x11, 0x11, 0x0a, 0x04, 0x04, 0x04},
+	'Z':  {0x1f, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1f},
+	'.':  {0x00, 0x00, 0x00, 0x00, 0x00, 0x0c, 0x0c},
+	',':  {0x00, 0x00, 0x00, 0x00, 0x0c, 0x04, 0x08},
+	'-':  {0x00, 0x00, 0x00, 0x1f, 0x00, 0x00, 0x00},
+	'+':  {0x00, 0x04, 0x04, 0x1f, 0x04, 0x04, 0x00},
+	'%':  {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+	':':  {0x00, 0x0c, 0x0c, 0x00, 0x0c, 0x0c, 0x00},
+	'\'': {0x0c, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00},
+	'(':  {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')':  {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+	'/':  {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'#':  {0x0a, 0x0a, 0x1f, 0x0a, 0x1f, 0x0a, 0x0a},
+	'&':  {0x0c, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0d},
+	'?':  {0x0e, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+	'!':  {0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04},
+	'°':  {0x0c, 0x12, 0x12, 0x0c, 0x00, 0x00, 0x00},
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// checkConfig validates everything that can be checked without asking a
+// weather service for a forecast and writes a PASS/FAIL line per check.
+// It reports whether every check passed.
+func checkConfig(w io.Writer, providerList string, fo fetchOpts, format, webhook string) bool {
+	ok := true
+	report := func(what string, err error) {
+		if err != nil {
+			ok = false
+			fmt.Fprintf(w, "FAIL %s: %v\n", what, err)
+			return
+		}
+		fmt.Fprintf(w, "PASS %s\n", what)
+	}
+
+	provs, err := newProviders(providerList, fo)
+	report("providers", err)
+	for _, p := range provs {
+		if p.name() == "forecastio" && fo.fioKey == "" {
+			report("forecastio key", fmt.Errorf("no key, set -forecastio-key or $FORECASTIO_KEY"))
+		}
+	}
+
+	var bad []string
+	for _, k := range locationNames(deterministic) {
+		v := locations[k]
+		if v.lat < -90 || v.lat > 90 || v.lng < -180 || v.lng > 180 {
+			bad = append(bad, fmt.Sprintf("%s (%f,%f)", k, v.lat, v.lng))
+		}
+	}
+	if len(bad) > 0 {
+		report("locations", fmt.Errorf("coordinates out of range: %s", strings.Join(bad, ", ")))
+	} else if len(locations) == 0 {
+		report("locations", fmt.Errorf("no locations configured"))
+	} else {
+		report(fmt.Sprintf("%d locations", len(locations)), nil)
+	}
+
+	if format == "slack" && webhook != "" {
+		report("webhook", checkWebhook(webhook))
+	}
+	fmt.Fprintln(w, map[bool]string{true: "PASS", false: "FAIL"}[ok])
+	return ok
+}
+
+// checkWebhook makes sure the webhook is a url whose host answers. It
+// only sends a HEAD to the host so nothing is posted to the channel.
+func checkWebhook(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" || u.Host == "" {
+		return fmt.Errorf("%q is not an http url", webhook)
+	}
+	req, err := newRequest("HEAD", u.Scheme+"://"+u.Host+"/", nil)
+	if err != nil {
+		return err
+	}
+	c := *httpClient
+	c.Timeout = 10 * time.Second
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
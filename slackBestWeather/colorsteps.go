@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// warnColor is the middle color of -color-steps
+var warnColor = rgb{R: 255, G: 204}
+
+// colorSteps is the -color-steps flag, good,bad thresholds in -sort-by
+// units. At good or better a location is bestColor, at bad or worse
+// worstColor, and warnColor in between, rather than a gradient.
+type colorSteps struct {
+	good, bad float64
+	set       bool
+}
+
+func (c *colorSteps) String() string {
+	if c == nil || !c.set {
+		return ""
+	}
+	return fmt.Sprintf("%g,%g", c.good, c.bad)
+}
+
+func (c *colorSteps) Set(s string) error {
+	g, b, ok := strings.Cut(s, ",")
+	if !ok {
+		return fmt.Errorf("%q should be good,bad, eg 450,350", s)
+	}
+	var err error
+	if c.good, err = strconv.ParseFloat(strings.TrimSpace(g), 64); err != nil {
+		return fmt.Errorf("good threshold %q isn't a number", g)
+	}
+	if c.bad, err = strconv.ParseFloat(strings.TrimSpace(b), 64); err != nil {
+		return fmt.Errorf("bad threshold %q isn't a number", b)
+	}
+	if c.good == c.bad {
+		return fmt.Errorf("the good and bad thresholds are both %g", c.good)
+	}
+	c.set = true
+	return nil
+}
+
+// color is which of the three colors v gets. Which side of the
+// thresholds is better follows the order the values were given in, so
+// it works for keys where lower is better too.
+func (c colorSteps) color(v float64) rgb {
+	up := c.good > c.bad
+	switch {
+	case up && v >= c.good, !up && v <= c.good:
+		return bestColor
+	case up && v <= c.bad, !up && v >= c.bad:
+		return worstColor
+	}
+	return warnColor
+}
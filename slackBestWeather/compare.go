@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// refsFlag is a comma separated list of reference locations for -compare
+type refsFlag []string
+
+func (r *refsFlag) String() string { return strings.Join(*r, ",") }
+
+func (r *refsFlag) Set(s string) error {
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			*r = append(*r, n)
+		}
+	}
+	return nil
+}
+
+// markReferences flags the reference locations in res and the locations
+// that beat all of them by the ranking key. It returns how many did.
+func markReferences(res []locScore, refs []string, key sortKey) (int, error) {
+	isRef := make(map[string]bool)
+	for _, n := range refs {
+		isRef[n] = true
+	}
+	var bar float64
+	found := 0
+	for i := range res {
+		if !isRef[res[i].Location] {
+			continue
+		}
+		res[i].Reference = true
+		v := key.value(res[i])
+		if found == 0 || better(key, v, bar) {
+			bar = v
+		}
+		found++
+	}
+	if found == 0 {
+		return 0, fmt.Errorf("none of the -compare locations (%s) could be scored", strings.Join(refs, ", "))
+	}
+	n := 0
+	for i := range res {
+		if !res[i].Reference && better(key, key.value(res[i]), bar) {
+			res[i].BeatsReference = true
+			n++
+		}
+	}
+	return n, nil
+}
+
+// better reports whether a ranks ahead of b by key
+func better(key sortKey, a, b float64) bool {
+	if key.desc {
+		return a > b
+	}
+	return a < b
+}
+
+// refMark is the compact table's beats-the-reference column
+func refMark(v locScore) string {
+	switch {
+	case v.Reference:
+		return "ref"
+	case v.BeatsReference:
+		return "beats"
+	}
+	return ""
+}
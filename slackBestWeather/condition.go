@@ -0,0 +1,51 @@
+package main
+
+import (
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// emoji is the slack emoji for the condition
+func emoji(c condition) string {
+	switch c {
+	case weather.CondClear:
+		return ":sunny:"
+	case weather.CondPartlyCloudy:
+		return ":partly_sunny:"
+	case weather.CondCloudy:
+		return ":cloud:"
+	case weather.CondRain:
+		return ":rain_cloud:"
+	case weather.CondSnow:
+		return ":snowflake:"
+	case weather.CondSleet:
+		return ":snow_cloud:"
+	case weather.CondWind:
+		return ":dash:"
+	case weather.CondFog:
+		return ":fog:"
+	}
+	return ":grey_question:"
+}
+
+// fioCondition maps a forecast.io icon
+func fioCondition(icon string) condition {
+	switch icon {
+	case "clear-day", "clear-night":
+		return weather.CondClear
+	case "partly-cloudy-day", "partly-cloudy-night":
+		return weather.CondPartlyCloudy
+	case "cloudy":
+		return weather.CondCloudy
+	case "rain", "thunderstorm":
+		return weather.CondRain
+	case "snow":
+		return weather.CondSnow
+	case "sleet", "hail":
+		return weather.CondSleet
+	case "wind", "tornado":
+		return weather.CondWind
+	case "fog":
+		return weather.CondFog
+	}
+	return weather.CondUnknown
+}
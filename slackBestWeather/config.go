@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// locConfig is how a location is written in a locations file, eg
+//
+//	[{"name": "Islip", "lat": 40.726911, "lng": -73.218542, "region": "East Coast"}]
+//
+// Leave out lat and lng to have the place (or the name) geocoded.
+type locConfig struct {
+	Name   string  `json:"name"`
+	Place  string  `json:"place,omitempty"`
+	Lat    float64 `json:"lat"`
+	Lng    float64 `json:"lng"`
+	Region string  `json:"region,omitempty"`
+	// Normals are 12 monthly normal highs in fahrenheit, for -surprise
+	Normals []float64 `json:"normals,omitempty"`
+	// Adjust is added to the score, eg 10 to favour home
+	Adjust float64 `json:"adjust,omitempty"`
+	// Weight multiplies the score, eg 0.8 for somewhere hard to get to
+	Weight float64 `json:"weight,omitempty"`
+	// Enabled false keeps a location in the file without using it, eg a
+	// beach town in winter. It can switch off a built in location too.
+	Enabled *bool `json:"enabled,omitempty"`
+	// Bearing is the wind direction, degrees from north it blows from,
+	// that suits -mode sail here, eg 225 for a south westerly sea breeze
+	Bearing *float64 `json:"bearing,omitempty"`
+	// Beach is set for places on the water, which -mode beach looks up a
+	// sea temperature for
+	Beach bool `json:"beach,omitempty"`
+	// AliasOf makes this another name for a location, eg NYC for New
+	// York, at the same point and fetched once for both. It takes lat,
+	// lng, normals, bearing and beach from that location, the rest is its
+	// own.
+	AliasOf string `json:"alias_of,omitempty"`
+}
+
+// disabled are the locations switched off with enabled: false
+var disabled = make(map[string]bool)
+
+// configOrder is the order locations first appeared in the files, for
+// -display-order config
+var configOrder []string
+
+// loadLocations reads a JSON locations file into locations. Entries with
+// the same name as a built in location replace it.
+func loadLocations(fn string, gc *geocoder) error {
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return err
+	}
+	return parseLocations(fn, filepath.Ext(fn), buf, gc)
+}
+
+// the last good -locations-url response, used when the url can't be
+// reached. It keeps the list's extension, .json, .csv or .kml, so it's
+// read back the way it was fetched.
+const locationsURLCache = "cache/locations-url"
+
+// locationFormats are the extensions parseLocations reads, json first
+// as the default
+var locationFormats = []string{".json", ".csv", ".kml"}
+
+// loadLocationsURL is loadLocations for a list served over http. A copy
+// is kept so a run still works when the server is down.
+func loadLocationsURL(u string, gc *geocoder) error {
+	var buf []byte
+	var ext string
+	err := ErrNotCached
+	if !offline {
+		buf, ext, err = fetchLocations(u)
+	}
+	if err != nil {
+		for _, e := range locationFormats {
+			fn := locationsURLCache + e
+			cached, cerr := ioutil.ReadFile(fn)
+			if cerr != nil {
+				continue
+			}
+			if !offline {
+				warn("", err, "can't fetch "+u+", using the cached copy")
+			}
+			return parseLocations(fn, e, cached, gc)
+		}
+		return fmt.Errorf("%s: %v (and no cached copy)", u, err)
+	}
+	if err := parseLocations(u, ext, buf, gc); err != nil {
+		return err
+	}
+	for _, e := range locationFormats {
+		if e != ext {
+			os.Remove(locationsURLCache + e)
+		}
+	}
+	writeFileAtomic(locationsURLCache+ext, buf, 0640)
+	return nil
+}
+
+// fetchLocations gets the list at u and the extension its format is
+// read with, from the url's path or failing that its content type. A
+// query string, eg list.csv?token=x, is no part of the extension.
+func fetchLocations(u string) ([]byte, string, error) {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := newRequest("GET", u, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("bad http response %s", resp.Status)
+	}
+	buf, err := ioutil.ReadAll(resp.Body)
+	return buf, locationsFormat(pu.Path, resp.Header.Get("Content-Type")), err
+}
+
+// locationsFormat is the extension for a list at path served as
+// contentType, .json unless either says csv or kml
+func locationsFormat(path, contentType string) string {
+	if ext := strings.ToLower(filepath.Ext(path)); contains(locationFormats, ext) {
+		return ext
+	}
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasSuffix(mt, "/csv"):
+		return ".csv"
+	case strings.Contains(mt, "kml"):
+		return ".kml"
+	}
+	return ".json"
+}
+
+// parseLocations adds the location list in buf, read from fn, to
+// locations. ext is the format it's in, .csv or .kml, or JSON for
+// anything else.
+func parseLocations(fn, ext string, buf []byte, gc *geocoder) error {
+	var err error
+	var lcs []locConfig
+	switch strings.ToLower(ext) {
+	case ".csv":
+		lcs, err = parseLocationsCSV(fn, buf)
+	case ".kml":
+		lcs, err = parseLocationsKML(fn, buf)
+	default:
+		if err = json.Unmarshal(buf, &lcs); err != nil {
+			err = fmt.Errorf("%s: %v", fn, err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	var aliases []locConfig
+	for i, lc := range lcs {
+		if lc.Name == "" {
+			return fmt.Errorf("%s: location %d has no name", fn, i+1)
+		}
+		if lc.Enabled != nil && !*lc.Enabled {
+			delete(locations, lc.Name)
+			disabled[lc.Name] = true
+			continue
+		}
+		delete(disabled, lc.Name)
+		if lc.AliasOf != "" {
+			// the location it names may be further down
+			aliases = append(aliases, lc)
+			continue
+		}
+		if lc.Lat == 0 && lc.Lng == 0 {
+			place := lc.Place
+			if place == "" {
+				place = lc.Name
+			}
+			lc.Lat, lc.Lng, err = gc.lookup(place)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %v", fn, lc.Name, err)
+			}
+		}
+		if len(lc.Normals) != 0 && len(lc.Normals) != 12 {
+			return fmt.Errorf("%s: %s: normals needs 12 months, got %d", fn, lc.Name, len(lc.Normals))
+		}
+		if lc.Bearing != nil && (*lc.Bearing < 0 || *lc.Bearing >= 360) {
+			return fmt.Errorf("%s: %s: bearing should be 0 to 360 degrees", fn, lc.Name)
+		}
+		if lc.Weight < 0 {
+			return fmt.Errorf("%s: %s: weight can't be negative", fn, lc.Name)
+		}
+		if lc.Normals == nil {
+			lc.Normals = builtinNormals[lc.Name]
+		}
+		if !contains(configOrder, lc.Name) {
+			configOrder = append(configOrder, lc.Name)
+		}
+		locations[lc.Name] = loc{lat: lc.Lat, lng: lc.Lng, region: lc.Region, normals: lc.Normals, adjust: lc.Adjust, weight: lc.Weight, bearing: lc.Bearing, beach: lc.Beach}
+	}
+	for _, lc := range aliases {
+		l, ok := locations[lc.AliasOf]
+		if !ok || lc.AliasOf == lc.Name {
+			return fmt.Errorf("%s: %s: alias_of %q isn't another location", fn, lc.Name, lc.AliasOf)
+		}
+		if lc.Lat != 0 || lc.Lng != 0 || lc.Place != "" {
+			return fmt.Errorf("%s: %s: an alias_of takes its place from %s, it can't have its own", fn, lc.Name, lc.AliasOf)
+		}
+		l.region, l.adjust, l.weight, l.aliasOf = lc.Region, lc.Adjust, lc.Weight, lc.AliasOf
+		if !contains(configOrder, lc.Name) {
+			configOrder = append(configOrder, lc.Name)
+		}
+		locations[lc.Name] = l
+	}
+	return gc.save()
+}
+
+// parseLocationsCSV reads locations from a spreadsheet export, with a
+// header row naming the columns: name, lat and lng, and optionally place,
+// region, adjust, weight, normals, bearing, beach, enabled and alias_of.
+// normals is the 12 months in one cell, separated by spaces or
+// semicolons.
+func parseLocationsCSV(fn string, buf []byte) ([]locConfig, error) {
+	r := csv.NewReader(bytes.NewReader(buf))
+	r.TrimLeadingSpace = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	col := make(map[string]int)
+	for i, h := range header {
+		col[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := col["name"]; !ok {
+		return nil, fmt.Errorf("%s: the header needs a name column", fn)
+	}
+	var lcs []locConfig
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fn, err)
+		}
+		line, _ := r.FieldPos(0)
+		get := func(c string) string {
+			if i, ok := col[c]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		num := func(c string) (float64, error) {
+			s := get(c)
+			if s == "" {
+				return 0, nil
+			}
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return 0, fmt.Errorf("%s:%d: %s %q isn't a number", fn, line, c, s)
+			}
+			return v, nil
+		}
+		lc := locConfig{Name: get("name"), Place: get("place"), Region: get("region"), AliasOf: get("alias_of")}
+		if lc.Lat, err = num("lat"); err != nil {
+			return nil, err
+		}
+		if lc.Lng, err = num("lng"); err != nil {
+			return nil, err
+		}
+		if lc.Adjust, err = num("adjust"); err != nil {
+			return nil, err
+		}
+		if lc.Weight, err = num("weight"); err != nil {
+			return nil, err
+		}
+		for _, s := range strings.FieldsFunc(get("normals"), func(r rune) bool { return r == ';' || unicode.IsSpace(r) }) {
+			n, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: normals %q isn't a number", fn, line, s)
+			}
+			lc.Normals = append(lc.Normals, n)
+		}
+		if get("bearing") != "" {
+			b, err := num("bearing")
+			if err != nil {
+				return nil, err
+			}
+			lc.Bearing = &b
+		}
+		if s := get("beach"); s != "" {
+			if lc.Beach, err = strconv.ParseBool(s); err != nil {
+				return nil, fmt.Errorf("%s:%d: beach %q should be true or false", fn, line, s)
+			}
+		}
+		if s := get("enabled"); s != "" {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: enabled %q should be true or false", fn, line, s)
+			}
+			lc.Enabled = &b
+		}
+		if lc.Name == "" {
+			return nil, fmt.Errorf("%s:%d: no name", fn, line)
+		}
+		lcs = append(lcs, lc)
+	}
+	return lcs, nil
+}
+
+// deterministic makes everything that would go in map order go in a
+// sorted one instead, so the same inputs give the same logs and output.
+// The sorting costs a little on every lookup, which is why it's a switch.
+var deterministic bool
+
+// locationNames lists the configured locations, alphabetically if sorted
+// is set and in map order otherwise.
+func locationNames(sorted bool) []string {
+	names := make([]string, 0, len(locations))
+	for k := range locations {
+		names = append(names, k)
+	}
+	if sorted {
+		sort.Strings(names)
+	}
+	return names
+}
+
+// listLocations writes every location as it ended up after the built in
+// list, -locations and -locations-url were merged, disabled ones included
+func listLocations(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tLAT\tLNG\tREGION\tWEIGHT\tADJUST\tENABLED")
+	for _, k := range locationNames(true) {
+		l := locations[k]
+		weight := l.weight
+		if weight == 0 {
+			weight = 1
+		}
+		fmt.Fprintf(tw, "%s\t%.6f\t%.6f\t%s\t%g\t%g\ttrue\n", k, l.lat, l.lng, l.region, weight, l.adjust)
+	}
+	for _, k := range sortedKeys(disabled) {
+		fmt.Fprintf(tw, "%s\t\t\t\t\t\tfalse\n", k)
+	}
+	return tw.Flush()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
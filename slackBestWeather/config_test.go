@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const testCSV = `name,lat,lng,region,adjust,weight,normals
+Cape May,38.9351,-74.906,Shore,5,0.8,44 46 53 62 71 80 85 84 78 67 57 48
+`
+
+// TestLoadLocationsURL is a csv list that has a query string after its
+// extension, or only its content type to go on, read the same from the
+// url and from the cached copy once the url is down
+func TestLoadLocationsURL(t *testing.T) {
+	for _, c := range []struct {
+		name, path, contentType string
+	}{
+		{"extension", "/list.csv?token=x", "text/plain"},
+		{"content type", "/list", "text/csv; charset=utf-8"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			inCacheDir(t, cacheStores["files"])
+			saved := locations
+			t.Cleanup(func() { locations = saved })
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", c.contentType)
+				w.Write([]byte(testCSV))
+			}))
+			u := srv.URL + c.path
+			want := loc{lat: 38.9351, lng: -74.906, region: "Shore", adjust: 5, weight: .8,
+				normals: []float64{44, 46, 53, 62, 71, 80, 85, 84, 78, 67, 57, 48}}
+			for _, down := range []bool{false, true} {
+				if down {
+					srv.Close()
+				}
+				locations = make(map[string]loc)
+				if err := loadLocationsURL(u, &geocoder{}); err != nil {
+					t.Fatalf("down %v: %v", down, err)
+				}
+				if got := locations["Cape May"]; !reflect.DeepEqual(got, want) {
+					t.Errorf("down %v: got %+v, want %+v", down, got, want)
+				}
+			}
+		})
+	}
+}
+
+// TestLocationsFormat is the format of a -locations-url list
+func TestLocationsFormat(t *testing.T) {
+	for _, c := range []struct{ path, contentType, want string }{
+		{"/list.csv", "", ".csv"},
+		{"/My%20Places.KML", "", ".kml"},
+		{"/list.json", "text/csv", ".json"},
+		{"/list", "text/csv", ".csv"},
+		{"/export", "application/vnd.google-earth.kml+xml", ".kml"},
+		{"/list", "application/json", ".json"},
+		{"/list.php", "", ".json"},
+	} {
+		if got := locationsFormat(c.path, c.contentType); got != c.want {
+			t.Errorf("%s as %q is %s, want %s", c.path, c.contentType, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "fmt"
+
+// noCoverage is why f looks like it's for somewhere the provider has no
+// real data, mid ocean or off the edge of its model, or "" when it looks
+// fine. A typo'd or swapped lat and lng parses and fetches like any
+// other point, this is how it shows up: no summary or icon, or nothing
+// but zeros.
+func noCoverage(f *forecast, days []day) string {
+	if len(days) == 0 {
+		return ""
+	}
+	if days[0].Summary == "" && days[0].Icon == "" {
+		return "the forecast has no summary or icon"
+	}
+	for _, d := range days {
+		if d.TemperatureMax != 0 || d.TemperatureMin != 0 || d.Humidity != 0 || d.CloudCover != 0 ||
+			d.PrecipProbability != 0 || d.WindSpeed != 0 {
+			return ""
+		}
+	}
+	return "every metric in the forecast is zero"
+}
+
+// coverageWarning is the warning for l, whose forecast has problem
+func coverageWarning(l loc, problem string) string {
+	s := fmt.Sprintf("%s, %f,%f may be over water or outside the provider's coverage", problem, l.lat, l.lng)
+	if l.lng >= -90 && l.lng <= 90 {
+		s += fmt.Sprintf(" (check the lat and lng aren't swapped, that would be %f,%f)", l.lng, l.lat)
+	}
+	return s
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// readResults loads a saved run, either -format json (an array) or jsonl.
+// A jsonl file with several runs in it, like a -history-file, gives the
+// last one.
+func readResults(fn string) ([]locScore, error) {
+	buf, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	buf = bytes.TrimSpace(buf)
+	var res []locScore
+	if bytes.HasPrefix(buf, []byte("[")) {
+		if err := json.Unmarshal(buf, &res); err != nil {
+			return nil, fmt.Errorf("%s: %v", fn, err)
+		}
+		return res, nil
+	}
+	last := ""
+	for i, line := range bytes.Split(buf, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var l jsonlLine
+		if err := json.Unmarshal(line, &l); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", fn, i+1, err)
+		}
+		if l.RunID != last {
+			res, last = res[:0], l.RunID
+		}
+		res = append(res, l.locScore)
+	}
+	return res, nil
+}
+
+// scoringVersion is the version res was scored with, empty for runs
+// saved before there were versions
+func scoringVersion(res []locScore) string {
+	if len(res) == 0 {
+		return ""
+	}
+	return res[0].ScoringVersion
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+// ranks are each location's place by score, 1 being the best
+func ranks(res []locScore) map[string]int {
+	sorted := append([]locScore(nil), res...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	r := make(map[string]int)
+	for i, v := range sorted {
+		r[v.Location] = i + 1
+	}
+	return r
+}
+
+// diffResults describes how each location moved from run a to run b, in
+// b's ranking order with the ones that dropped out at the end
+func diffResults(a, b []locScore) []string {
+	ra, rb := ranks(a), ranks(b)
+	scoreA := make(map[string]float64)
+	for _, v := range a {
+		scoreA[v.Location] = v.Score
+	}
+	names := make([]string, 0, len(rb))
+	for k := range rb {
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool { return rb[names[i]] < rb[names[j]] })
+	var lines []string
+	for _, k := range names {
+		var s float64
+		for _, v := range b {
+			if v.Location == k {
+				s = v.Score
+			}
+		}
+		was, ok := ra[k]
+		if !ok {
+			lines = append(lines, fmt.Sprintf("%2d. %-20s new       %s", rb[k], k, formatNum(s)))
+			continue
+		}
+		move := "  ="
+		switch {
+		case was > rb[k]:
+			move = fmt.Sprintf("▲%2d", was-rb[k])
+		case was < rb[k]:
+			move = fmt.Sprintf("▼%2d", rb[k]-was)
+		}
+		lines = append(lines, fmt.Sprintf("%2d. %-20s %s (%d)  %s (%+.1f)", rb[k], k, move, was, formatNum(s), s-scoreA[k]))
+	}
+	var gone []string
+	for k := range ra {
+		if _, ok := rb[k]; !ok {
+			gone = append(gone, k)
+		}
+	}
+	sort.Strings(gone)
+	for _, k := range gone {
+		lines = append(lines, fmt.Sprintf("    %-20s dropped (was %d)", k, ra[k]))
+	}
+	return lines
+}
+
+// diffRuns prints, or posts when there's somewhere to post, the ranking
+// changes from the saved run in fa to the one in fb. With explain it's
+// why each location moved instead, see explainDiff.
+func diffRuns(so slackOpts, fa, fb string, explain bool) error {
+	a, err := readResults(fa)
+	if err != nil {
+		return err
+	}
+	b, err := readResults(fb)
+	if err != nil {
+		return err
+	}
+	head := fmt.Sprintf("%s → %s", fa, fb)
+	lines := diffResults(a, b)
+	if explain {
+		lines = explainDiff(a, b)
+	}
+	if va, vb := scoringVersion(a), scoringVersion(b); va != vb {
+		head += fmt.Sprintf("\nwarning: these were scored differently (version %s vs %s), the score changes aren't comparable", orUnknown(va), orUnknown(vb))
+	}
+	if so.webhook == "" && so.threadTS == "" && so.out == nil {
+		fmt.Println(head)
+		fmt.Println(strings.Join(lines, "\n"))
+		return nil
+	}
+	return sendText(so, head+"\n```\n"+strings.Join(lines, "\n")+"\n```")
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// activeAlert is a weather alert with every location it's in effect for.
+// Neighbouring locations often get the same alert, so they're merged on
+// the title and regions.
+type activeAlert struct {
+	weather.Alert
+	Locations []string
+}
+
+// activeAlerts gathers the alerts across res, most severe first and
+// soonest expiring first within a severity
+func activeAlerts(res []locScore) []activeAlert {
+	var all []activeAlert
+	seen := make(map[string]int)
+	for _, v := range res {
+		for _, a := range v.Alerts {
+			k := a.Title + "|" + strings.Join(a.Regions, ",")
+			i, ok := seen[k]
+			if !ok {
+				i = len(all)
+				seen[k] = i
+				all = append(all, activeAlert{Alert: a})
+			}
+			if !contains(all[i].Locations, v.Location) {
+				all[i].Locations = append(all[i].Locations, v.Location)
+			}
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.SeverityRank() != b.SeverityRank() {
+			return a.SeverityRank() < b.SeverityRank()
+		}
+		return a.Expires.Before(b.Expires)
+	})
+	return all
+}
+
+// alertDigest is the "Active Alerts" attachment, nil when nothing is
+// under an alert
+func alertDigest(res []locScore) *attachment {
+	all := activeAlerts(res)
+	if len(all) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, a := range all {
+		s := fmt.Sprintf("*%s*", a.Title)
+		if a.URI != "" {
+			s = fmt.Sprintf("*<%s|%s>*", a.URI, a.Title)
+		}
+		if a.Severity != "" {
+			s += " (" + strings.ToLower(a.Severity) + ")"
+		}
+		s += ": " + strings.Join(a.Locations, ", ")
+		if !a.Expires.IsZero() {
+			s += ", until " + a.Expires.Format("Mon 3:04pm")
+		}
+		lines = append(lines, s)
+	}
+	color := warnColor.String()
+	if all[0].SeverityRank() == 0 {
+		color = worstColor.String()
+	}
+	return &attachment{
+		Title:     ":rotating_light: Active Alerts",
+		Fallback:  fmt.Sprintf("%d weather alerts in effect", len(all)),
+		Color:     color,
+		Text:      strings.Join(lines, "\n"),
+		Mrkdwn_In: []string{"text"},
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "fmt"
+
+// nearDuplicates is -near-duplicates: locations within within miles of
+// each other, which would only ever tie and clutter the ranking. With
+// merge the later name (by name order) is dropped and kept as an alias
+// of the first, otherwise they're warned about. alias_of locations are
+// meant to be duplicates and -include-here is wherever it is, both are
+// left alone.
+func nearDuplicates(within float64, merge bool) {
+	names := locationNames(true)
+	gone := make(map[string]bool)
+	for i, a := range names {
+		la := locations[a]
+		if gone[a] || la.aliasOf != "" || la.here {
+			continue
+		}
+		for _, b := range names[i+1:] {
+			lb := locations[b]
+			if gone[b] || lb.aliasOf != "" || lb.here {
+				continue
+			}
+			d := milesBetween(la, lb)
+			if d > within {
+				continue
+			}
+			if !merge {
+				warn(b, nil, fmt.Sprintf("only %.1f miles from %s, they'll score about the same (-near-duplicates-action merge to keep one)", d, a))
+				continue
+			}
+			vlog("merging %s into %s, they're %.1f miles apart", b, a, d)
+			la.aliases = append(la.aliases, b)
+			delete(locations, b)
+			gone[b] = true
+		}
+		locations[a] = la
+	}
+}
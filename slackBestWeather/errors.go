@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// the kinds of fetch failure main treats differently, check for them
+// with errors.Is
+var (
+	// ErrRateLimited is a 429, more requests will only make it worse
+	ErrRateLimited = errors.New("rate limited")
+	// ErrBadKey is a 401 or 403, every location will fail the same way
+	ErrBadKey = errors.New("api key rejected")
+	// ErrNoData is a response without the forecast in it
+	ErrNoData = errors.New("no forecast data")
+	// ErrNetwork is a failure to talk to the provider at all
+	ErrNetwork = errors.New("network error")
+	// ErrNotCached is -offline asking for something that isn't cached
+	ErrNotCached = errors.New("not in the cache")
+	// ErrBadResponse is a body that isn't json, usually one cut off when
+	// the connection dropped. It's never cached and is worth a retry.
+	ErrBadResponse = errors.New("bad response")
+	// ErrServer is a 5xx, the provider's own trouble, worth a retry
+	ErrServer = errors.New("provider error")
+	// ErrRequest is any other non 200, eg a 404 or a 400 for coordinates
+	// the provider doesn't cover. The same request will fail again.
+	ErrRequest = errors.New("request refused")
+)
+
+// statusError is the error for a non 200 provider response
+func statusError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return fmt.Errorf("%w: %s", ErrRateLimited, resp.Status)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %s", ErrBadKey, resp.Status)
+	}
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%w: %s", ErrServer, resp.Status)
+	}
+	return fmt.Errorf("%w: bad http response %s", ErrRequest, resp.Status)
+}
+
+// transient is whether err is worth trying again later in the run: the
+// network or the provider having trouble, rather than anything about the
+// request
+func transient(err error) bool {
+	return errors.Is(err, ErrNetwork) || errors.Is(err, ErrServer) || errors.Is(err, ErrBadResponse)
+}
+
+// redactedError is an error with the secrets masked in its message, that
+// still unwraps to the original for errors.Is
+type redactedError struct {
+	err error
+}
+
+func (e redactedError) Error() string { return redact(e.err.Error()) }
+func (e redactedError) Unwrap() error { return e.err }
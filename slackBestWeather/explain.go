@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// factorDelta is how many points of a location's score change one
+// factor accounts for, and what changed underneath it
+type factorDelta struct {
+	points float64
+	why    string
+}
+
+// sharesOf is v's factor breakdown, worked out again from its metrics
+// with the default scoring when the run was saved without -factors
+func sharesOf(v locScore) (*factorShares, bool) {
+	if v.Factors != nil {
+		return v.Factors, true
+	}
+	d := day{TemperatureMax: v.TemperatureMax, TemperatureMin: v.TemperatureMin, Humidity: v.Humidity,
+		CloudCover: v.CloudCover, PrecipProbability: v.PrecipProbability}
+	return weather.FactorsOf(d, v.Units, weather.Location{}, scoreConfig{}).Shares(), false
+}
+
+// factorDeltas are the factors behind a's score becoming b's, biggest
+// first. Each share is out of its part of BestScore, the temp 300 and
+// the rest 100 each.
+func factorDeltas(a, b locScore) []factorDelta {
+	sa, _ := sharesOf(a)
+	sb, _ := sharesOf(b)
+	deg := func(t float64) string { return formatNum(t) + "°" }
+	ds := []factorDelta{
+		{(sb.Temp - sa.Temp) * 300, change("the high", a.TemperatureMax, b.TemperatureMax, deg)},
+		{(sb.Sun - sa.Sun) * 100, change("cloud cover", a.CloudCover, b.CloudCover, formatPct)},
+		{(sb.Dry - sa.Dry) * 100, change("precip probability", a.PrecipProbability, b.PrecipProbability, formatPct)},
+		{(sb.Humidity - sa.Humidity) * 100, change("humidity", a.Humidity, b.Humidity, formatPct)},
+	}
+	if a.TemperatureMax == b.TemperatureMax && a.TemperatureMin != b.TemperatureMin {
+		ds[0].why = change("the low", a.TemperatureMin, b.TemperatureMin, deg)
+	}
+	sort.SliceStable(ds, func(i, j int) bool { return math.Abs(ds[i].points) > math.Abs(ds[j].points) })
+	return ds
+}
+
+// change is eg "precip probability dropped from 80% to 20%"
+func change(what string, a, b float64, format func(float64) string) string {
+	verb := "rose"
+	if b < a {
+		verb = "dropped"
+	}
+	return fmt.Sprintf("%s %s from %s to %s", what, verb, format(a), format(b))
+}
+
+// a factor has to move the score this much to be named
+const explainMin = 0.5
+
+// explainMost is how many factors a location's line names
+const explainMost = 2
+
+// explainDiff says why each location that's in both runs moved, in b's
+// ranking order, eg "Dublin rose 3 places (+85): precip probability
+// dropped from 80% to 20% (+60), cloud cover dropped from 90% to 50%
+// (+40)". What the factors don't account for, like -adjust or the
+// surprise bonus, is put down to other.
+func explainDiff(a, b []locScore) []string {
+	ra, rb := ranks(a), ranks(b)
+	byName := make(map[string]locScore)
+	for _, v := range a {
+		byName[v.Location] = v
+	}
+	sorted := append([]locScore(nil), b...)
+	sort.SliceStable(sorted, func(i, j int) bool { return rb[sorted[i].Location] < rb[sorted[j].Location] })
+	var lines []string
+	recomputed := false
+	for _, v := range sorted {
+		was, ok := byName[v.Location]
+		if !ok {
+			continue
+		}
+		delta := v.Score - was.Score
+		move := "kept its place"
+		switch n := ra[v.Location] - rb[v.Location]; {
+		case n == 1:
+			move = "rose a place"
+		case n > 1:
+			move = fmt.Sprintf("rose %d places", n)
+		case n == -1:
+			move = "fell a place"
+		case n < -1:
+			move = fmt.Sprintf("fell %d places", -n)
+		}
+		if math.Abs(delta) < explainMin {
+			lines = append(lines, fmt.Sprintf("%s %s, its score held steady (%s)", v.Location, move, formatNum(v.Score)))
+			continue
+		}
+		var why []string
+		var explained float64
+		for _, d := range factorDeltas(was, v) {
+			explained += d.points
+			if len(why) < explainMost && math.Abs(d.points) >= explainMin {
+				why = append(why, fmt.Sprintf("%s (%s)", d.why, formatSigned(d.points)))
+			}
+		}
+		if other := delta - explained; math.Abs(other) >= explainMin {
+			why = append(why, fmt.Sprintf("other (%s)", formatSigned(other)))
+		}
+		if _, ok := sharesOf(was); !ok {
+			recomputed = true
+		} else if _, ok := sharesOf(v); !ok {
+			recomputed = true
+		}
+		lines = append(lines, fmt.Sprintf("%s %s (%s): %s", v.Location, move, formatSigned(delta), strings.Join(why, ", ")))
+	}
+	if recomputed {
+		lines = append(lines, "(some runs were saved without -factors, their factors were worked out again with the default scoring)")
+	}
+	return lines
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// captureFixtures is -capture-fixtures: it fetches every location live,
+// like -warm-cache, and copies each raw response out of the cache into
+// dir as <location>.json (<location>-<provider>.json with more than one
+// provider), ready to be served by a mock or a test server
+func captureFixtures(dir string, provs []provider, fo fetchOpts, names []string, failFast bool) error {
+	if err := warmCache(provs, names, failFast); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	now := time.Now()
+	n := 0
+	for _, k := range names {
+		for _, p := range provs {
+			key := cacheKey(p.name(), fo.round(locations[k]), fo.units, now)
+			buf, _, ok := cache.load(key)
+			if !ok {
+				return fmt.Errorf("%s: %s: fetched but not in the cache (key %s)", k, p.name(), key)
+			}
+			fn := fixtureName(k)
+			if len(provs) > 1 {
+				fn += "-" + p.name()
+			}
+			if err := writeFileAtomic(filepath.Join(dir, fn+".json"), buf, 0640); err != nil {
+				return err
+			}
+			n++
+		}
+	}
+	vlog("captured %d fixtures in %s", n, dir)
+	return nil
+}
+
+// fixtureName is a location's name as a file name, eg "Ann Arbor" is
+// ann-arbor
+func fixtureName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "location"
+	}
+	return b.String()
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// Struct to unmarshal json from forcast.io
+// Only the stuff I'm interested in atm
+type fioResp struct {
+	Timezone string
+	Daily    struct {
+		Data []fioDay
+	}
+	Hourly struct {
+		Data []fioHour
+	}
+	Minutely struct {
+		Summary string
+		Data    []struct {
+			Time              float64
+			PrecipIntensity   float64
+			PrecipProbability float64
+		}
+	}
+	Flags struct {
+		Units string
+	}
+	Alerts []struct {
+		Title    string
+		Regions  []string
+		Severity string
+		Time     float64
+		Expires  float64
+		URI      string
+	}
+}
+
+type fioDay struct {
+	Humidity          float64
+	CloudCover        float64
+	PrecipProbability float64
+	Pressure          float64
+	Summary           string
+	TemperatureMax    float64
+	TemperatureMin    float64
+	Time              float64
+	Icon              string
+	WindSpeed         float64
+	DewPoint          float64
+	// only there when snow is expected
+	PrecipAccumulation float64
+	Visibility         float64
+	SunriseTime        float64
+	SunsetTime         float64
+	WindBearing        float64
+	// the rest of the documented daily fields, not scored by default but
+	// there for -score-expr
+	WindGust               float64
+	PrecipIntensity        float64
+	PrecipIntensityMax     float64
+	PrecipType             string
+	ApparentTemperatureMax float64
+	ApparentTemperatureMin float64
+	Ozone                  float64
+	UVIndex                float64
+	MoonPhase              float64
+
+	// missing are the scored fields the response left out, which are
+	// zeros above
+	missing weather.Metric
+}
+
+// UnmarshalJSON decodes a day leniently, numbers can come as strings
+// (some proxies and mirrors do that) and missing fields are noted
+// rather than silently scored as zero.
+func (d *fioDay) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	nums := []struct {
+		name   string
+		v      *float64
+		scored bool
+	}{
+		{"time", &d.Time, false},
+		{"temperatureMax", &d.TemperatureMax, true},
+		{"temperatureMin", &d.TemperatureMin, true},
+		{"humidity", &d.Humidity, true},
+		{"cloudCover", &d.CloudCover, true},
+		{"precipProbability", &d.PrecipProbability, true},
+		{"pressure", &d.Pressure, false},
+		{"windSpeed", &d.WindSpeed, false},
+		{"dewPoint", &d.DewPoint, false},
+		{"precipAccumulation", &d.PrecipAccumulation, false},
+		{"visibility", &d.Visibility, false},
+		{"sunriseTime", &d.SunriseTime, false},
+		{"sunsetTime", &d.SunsetTime, false},
+		{"windBearing", &d.WindBearing, false},
+		{"windGust", &d.WindGust, false},
+		{"precipIntensity", &d.PrecipIntensity, false},
+		{"precipIntensityMax", &d.PrecipIntensityMax, false},
+		{"apparentTemperatureMax", &d.ApparentTemperatureMax, false},
+		{"apparentTemperatureMin", &d.ApparentTemperatureMin, false},
+		{"ozone", &d.Ozone, false},
+		{"uvIndex", &d.UVIndex, false},
+		{"moonPhase", &d.MoonPhase, false},
+	}
+	for _, n := range nums {
+		r, ok := raw[n.name]
+		if !ok || string(r) == "null" {
+			if n.scored {
+				d.missing |= weather.MetricNamed(n.name)
+			}
+			continue
+		}
+		v, err := flexFloat(r)
+		if err != nil {
+			return fmt.Errorf("%s: %v", n.name, err)
+		}
+		*n.v = v
+	}
+	for k, p := range map[string]*string{"summary": &d.Summary, "icon": &d.Icon, "precipType": &d.PrecipType} {
+		if r, ok := raw[k]; ok {
+			// a summary that isn't a string isn't worth failing over
+			json.Unmarshal(r, p)
+		}
+	}
+	return nil
+}
+
+// flexFloat is a json number, or a string with a number in it
+func flexFloat(r json.RawMessage) (float64, error) {
+	var f float64
+	if err := json.Unmarshal(r, &f); err == nil {
+		return f, nil
+	}
+	var s string
+	if err := json.Unmarshal(r, &s); err != nil {
+		return 0, fmt.Errorf("%s isn't a number", r)
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q isn't a number", s)
+	}
+	return f, nil
+}
+
+// unixOrZero is the time of a unix timestamp, leaving 0 (missing) as the
+// zero time
+func unixOrZero(t float64) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(t), 0)
+}
+
+type fioHour struct {
+	Time              float64
+	Summary           string
+	Icon              string
+	Temperature       float64
+	Humidity          float64
+	CloudCover        float64
+	PrecipProbability float64
+	Pressure          float64
+	WindSpeed         float64
+	DewPoint          float64
+}
+
+const (
+	defaultFIOKey  = "52d39c0c95e7f6f475e316c6c516b5e7"
+	defaultFIOBase = "https://api.forecast.io"
+)
+
+type forecastIO struct {
+	fetchOpts
+}
+
+func (p forecastIO) name() string { return "forecastio" }
+
+func (p forecastIO) fetch(name string, l loc) (*forecast, error) {
+	l = p.round(l)
+	u := fmt.Sprintf("%s/forecast/%s/%f,%f?units=%s", strings.TrimSuffix(p.fioBase, "/"), p.key(), l.lat, l.lng, p.units)
+	d, fetched, err := get(u, cacheKey(p.name(), l, p.units, time.Now()), p.useCache)
+	if err != nil {
+		return nil, err
+	}
+	fc, err := p.parse(name, d)
+	if err != nil {
+		return nil, err
+	}
+	fc.Fetched = fetched
+	return fc, nil
+}
+
+func (p forecastIO) parse(name string, buf []byte) (*forecast, error) {
+	return parseFIO(name, buf, p.units)
+}
+
+// key is the api key for the next request, from the -api-keys pool if
+// there is one
+func (p forecastIO) key() string {
+	if pool := keyPools[p.name()]; pool != nil {
+		return pool.pick()
+	}
+	return p.fioKey
+}
+
+// parseFIO turns a forecast.io response into a forecast in the wanted
+// units. Whatever the provider sends back it returns an error rather
+// than a forecast score can't handle.
+func parseFIO(name string, buf []byte, units string) (*forecast, error) {
+	var f fioResp
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return nil, err
+	}
+	if len(f.Daily.Data) == 0 {
+		return nil, fmt.Errorf("%w, no daily data", ErrNoData)
+	}
+	checkUnits(name, &f, units)
+	fc := &forecast{Units: f.Flags.Units, Timezone: f.Timezone}
+	for _, d := range f.Daily.Data {
+		fc.Daily = append(fc.Daily, day{
+			Time:              time.Unix(int64(d.Time), 0),
+			Summary:           d.Summary,
+			Icon:              d.Icon,
+			Condition:         fioCondition(d.Icon),
+			TemperatureMax:    d.TemperatureMax,
+			TemperatureMin:    d.TemperatureMin,
+			Humidity:          d.Humidity,
+			CloudCover:        d.CloudCover,
+			PrecipProbability: d.PrecipProbability,
+			Pressure:          d.Pressure,
+			WindSpeed:         d.WindSpeed,
+			DewPoint:          d.DewPoint,
+			// zero when there's no snow
+			PrecipAccumulation: d.PrecipAccumulation,
+			Visibility:         d.Visibility,
+			Sunrise:            unixOrZero(d.SunriseTime),
+			Sunset:             unixOrZero(d.SunsetTime),
+			WindBearing:        d.WindBearing,
+			Missing:            d.missing,
+			Extra: dayExtra{
+				WindGust:               d.WindGust,
+				PrecipIntensity:        d.PrecipIntensity,
+				PrecipIntensityMax:     d.PrecipIntensityMax,
+				PrecipType:             d.PrecipType,
+				ApparentTemperatureMax: d.ApparentTemperatureMax,
+				ApparentTemperatureMin: d.ApparentTemperatureMin,
+				Ozone:                  d.Ozone,
+				UVIndex:                d.UVIndex,
+				MoonPhase:              d.MoonPhase,
+			},
+		})
+	}
+	for _, h := range f.Hourly.Data {
+		fc.Hourly = append(fc.Hourly, hour{
+			Time:              time.Unix(int64(h.Time), 0),
+			Summary:           h.Summary,
+			Icon:              h.Icon,
+			Condition:         fioCondition(h.Icon),
+			Temperature:       h.Temperature,
+			Humidity:          h.Humidity,
+			CloudCover:        h.CloudCover,
+			PrecipProbability: h.PrecipProbability,
+			Pressure:          h.Pressure,
+			WindSpeed:         h.WindSpeed,
+			DewPoint:          h.DewPoint,
+		})
+	}
+	for _, a := range f.Alerts {
+		fc.Alerts = append(fc.Alerts, weather.Alert{Title: a.Title, Regions: a.Regions, Severity: a.Severity,
+			Time: unixOrZero(a.Time), Expires: unixOrZero(a.Expires), URI: a.URI})
+	}
+	fc.MinutelySummary = f.Minutely.Summary
+	for _, m := range f.Minutely.Data {
+		fc.Minutely = append(fc.Minutely, minute{
+			Time:              time.Unix(int64(m.Time), 0),
+			PrecipIntensity:   m.PrecipIntensity,
+			PrecipProbability: m.PrecipProbability,
+		})
+	}
+	return fc, nil
+}
+
+// forecast.io echoes the units it actually used in flags.units. If that
+// isn't what we asked for warn and convert the temps and wind so the
+// rest of the program can trust the requested units.
+func checkUnits(name string, f *fioResp, want string) {
+	got := f.Flags.Units
+	if got == "" || want == "auto" || got == want {
+		if got == "" {
+			f.Flags.Units = want
+		}
+		return
+	}
+	warn(name, nil, fmt.Sprintf("requested units %q but forecast.io returned %q, converting", want, got))
+	for i := range f.Daily.Data {
+		d := &f.Daily.Data[i]
+		d.TemperatureMax = weather.ConvertTemp(d.TemperatureMax, got, want)
+		d.TemperatureMin = weather.ConvertTemp(d.TemperatureMin, got, want)
+		d.WindSpeed = weather.ConvertSpeed(d.WindSpeed, got, want)
+		d.DewPoint = weather.ConvertTemp(d.DewPoint, got, want)
+		d.PrecipAccumulation = weather.ConvertDepth(d.PrecipAccumulation, got, want)
+		d.Visibility = weather.ConvertDistance(d.Visibility, got, want)
+		d.WindGust = weather.ConvertSpeed(d.WindGust, got, want)
+		d.PrecipIntensity = weather.ConvertIntensity(d.PrecipIntensity, got, want)
+		d.PrecipIntensityMax = weather.ConvertIntensity(d.PrecipIntensityMax, got, want)
+		d.ApparentTemperatureMax = weather.ConvertTemp(d.ApparentTemperatureMax, got, want)
+		d.ApparentTemperatureMin = weather.ConvertTemp(d.ApparentTemperatureMin, got, want)
+	}
+	for i := range f.Hourly.Data {
+		h := &f.Hourly.Data[i]
+		h.Temperature = weather.ConvertTemp(h.Temperature, got, want)
+		h.WindSpeed = weather.ConvertSpeed(h.WindSpeed, got, want)
+		h.DewPoint = weather.ConvertTemp(h.DewPoint, got, want)
+	}
+	for i := range f.Minutely.Data {
+		m := &f.Minutely.Data[i]
+		m.PrecipIntensity = weather.ConvertIntensity(m.PrecipIntensity, got, want)
+	}
+	f.Flags.Units = want
+}
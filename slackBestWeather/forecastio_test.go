@@ -0,0 +1,66 @@
+package main
+
+import (
+	"math"
+	"os"
+	"testing"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// FuzzParseFIO feeds parseFIO whatever a provider might send back, and
+// scores every forecast it accepts under every mode. Neither should
+// panic, and a score should never be NaN.
+func FuzzParseFIO(f *testing.F) {
+	seed, err := os.ReadFile("testdata/forecastio.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(seed)
+	// cut off mid-stream
+	f.Add(seed[:len(seed)/2])
+	f.Add([]byte(`{"daily":{"data":[]}}`))
+	f.Add([]byte(`{"daily":{"data":[{}]},"flags":{"units":"si"}}`))
+	f.Add([]byte(`{"daily":{"data":[{"temperatureMax":"80","temperatureMin":" 60 ","humidity":null,"cloudCover":1e308}]}}`))
+	f.Add([]byte(`{"daily":{"data":[{"time":1476417600,"temperatureMax":-1e308,"windGust":1e308,"dewPoint":1e308}]},"minutely":{"data":[{"precipIntensity":1e308}]}}`))
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		for _, units := range []string{"us", "si"} {
+			fc, err := parseFIO("fuzz", buf, units)
+			if err != nil {
+				continue
+			}
+			for _, m := range scoringModes {
+				c := weather.Config{Mode: m, Days: 7, GustLimit: 30}
+				if s := weather.Score(fc, weather.Location{}, c); math.IsNaN(s) {
+					t.Fatalf("%s mode scored NaN (%s units)", m, units)
+				}
+			}
+		}
+	})
+}
+
+// TestCheckUnits is a forecast that came back in the wrong units brought
+// round to the ones asked for, snow included so -mode ski sees inches
+func TestCheckUnits(t *testing.T) {
+	f := &fioResp{}
+	f.Flags.Units = "si"
+	f.Daily.Data = []fioDay{{TemperatureMax: 20, TemperatureMin: 0, PrecipAccumulation: 25.4, WindSpeed: 10}}
+	checkUnits("here", f, "us")
+	d := f.Daily.Data[0]
+	for _, c := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"high", d.TemperatureMax, 68},
+		{"low", d.TemperatureMin, 32},
+		{"snow", d.PrecipAccumulation, 10},
+		{"wind", d.WindSpeed, 22.37},
+	} {
+		if math.Abs(c.got-c.want) > .01 {
+			t.Errorf("%s is %g, want %g", c.name, c.got, c.want)
+		}
+	}
+	if f.Flags.Units != "us" {
+		t.Errorf("units are %q, want us", f.Flags.Units)
+	}
+}
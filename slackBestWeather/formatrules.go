@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// formatRule is one -format-rule, a condition written like -score-expr
+// and what to do to a location's row when it holds
+type formatRule struct {
+	cond *weather.Expr
+	rowStyle
+}
+
+// rowStyle is how the format rules say to show a location. Every
+// output that names or colors a location goes through displayName and
+// slackOpts.color, so that's where it's applied.
+type rowStyle struct {
+	prefix, suffix string
+	// color replaces the gradient's, dim fades whatever the color is
+	// halfway to grey
+	color string
+	dim   bool
+}
+
+// formatRules is the repeatable -format-rule flag, eg
+//
+//	precipProb > 0.5 => prefix=🌧️,dim
+//
+// The effects are prefix=TEXT, suffix=TEXT, color=#rrggbb and dim.
+// Every rule that holds applies, in order, a later color winning.
+type formatRules []formatRule
+
+func (r *formatRules) String() string {
+	if r == nil {
+		return ""
+	}
+	var s []string
+	for _, f := range *r {
+		s = append(s, f.cond.String()+" => "+f.effects())
+	}
+	return strings.Join(s, "; ")
+}
+
+func (r *formatRules) Set(s string) error {
+	cond, effects, ok := strings.Cut(s, "=>")
+	if !ok {
+		return fmt.Errorf("format rule %q should be condition => effects, eg \"precipProb > 0.5 => prefix=🌧️,dim\"", s)
+	}
+	x, err := weather.ParseExpr(strings.TrimSpace(cond))
+	if err != nil {
+		return fmt.Errorf("format rule %q: %v", s, err)
+	}
+	f := formatRule{cond: x}
+	for _, e := range strings.Split(effects, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(e), "=")
+		switch k {
+		case "prefix":
+			f.prefix = v
+		case "suffix":
+			f.suffix = v
+		case "color":
+			var c rgb
+			if err := c.Set(v); err != nil {
+				return fmt.Errorf("format rule %q: %v", s, err)
+			}
+			f.color = c.String()
+		case "dim":
+			f.dim = true
+		default:
+			return fmt.Errorf("format rule %q: unknown effect %q, it should be prefix=, suffix=, color= or dim", s, k)
+		}
+	}
+	*r = append(*r, f)
+	return nil
+}
+
+func (f formatRule) effects() string {
+	var s []string
+	if f.prefix != "" {
+		s = append(s, "prefix="+f.prefix)
+	}
+	if f.suffix != "" {
+		s = append(s, "suffix="+f.suffix)
+	}
+	if f.color != "" {
+		s = append(s, "color="+f.color)
+	}
+	if f.dim {
+		s = append(s, "dim")
+	}
+	return strings.Join(s, ",")
+}
+
+// apply styles each of res by the rules that hold for it. The
+// condition sees the day the location was scored on, with builtin its
+// final score.
+func (r formatRules) apply(res []locScore) {
+	for i, v := range res {
+		d := weather.Day{
+			TemperatureMax:    v.TemperatureMax,
+			TemperatureMin:    v.TemperatureMin,
+			Humidity:          v.Humidity,
+			CloudCover:        v.CloudCover,
+			PrecipProbability: v.PrecipProbability,
+			WindSpeed:         v.WindSpeed,
+			WindBearing:       v.WindBearing,
+			Extra:             v.Extra,
+		}
+		vars := weather.ExprVars(d, v.Units, v.Score)
+		var st rowStyle
+		for _, f := range r {
+			if ok, _ := f.cond.Eval(vars); ok == 0 {
+				continue
+			}
+			st.prefix += f.prefix
+			st.suffix += f.suffix
+			if f.color != "" {
+				st.color = f.color
+			}
+			st.dim = st.dim || f.dim
+		}
+		res[i].style = st
+	}
+}
+
+// dimmed is the hex color c faded halfway to grey
+func dimmed(c string) string {
+	var v rgb
+	if err := v.Set(c); err != nil {
+		return c
+	}
+	return weather.Between(v, rgb{R: 128, G: 128, B: 128}, .5)
+}
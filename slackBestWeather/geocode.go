@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// coordinates don't move, so geocoding results are kept for a long time
+const geocodeTTL = 365 * 24 * time.Hour
+
+type geoEntry struct {
+	Lat, Lng float64
+	Time     time.Time
+}
+
+// geocoder resolves place names to coordinates with the open-meteo
+// geocoding api, remembering the answers in a cache file separate from
+// the forecasts.
+type geocoder struct {
+	file    string
+	refresh bool
+	cache   map[string]geoEntry
+	dirty   bool
+}
+
+func normalizePlace(place string) string {
+	return strings.Join(strings.Fields(strings.ToLower(place)), " ")
+}
+
+func (g *geocoder) load() {
+	if g.cache != nil {
+		return
+	}
+	g.cache = make(map[string]geoEntry)
+	if g.refresh {
+		return
+	}
+	buf, err := ioutil.ReadFile(g.file)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(buf, &g.cache)
+}
+
+func (g *geocoder) lookup(place string) (float64, float64, error) {
+	g.load()
+	key := normalizePlace(place)
+	if e, ok := g.cache[key]; ok && time.Since(e.Time) < geocodeTTL {
+		return e.Lat, e.Lng, nil
+	}
+	if offline {
+		return 0, 0, fmt.Errorf("geocoding %q: %w, offline", place, ErrNotCached)
+	}
+	u := "https://geocoding-api.open-meteo.com/v1/search?count=1&name=" + url.QueryEscape(place)
+	req, err := newRequest("GET", u, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, 0, fmt.Errorf("geocoding %q: bad http response %s", place, resp.Status)
+	}
+	var r struct {
+		Results []struct {
+			Latitude, Longitude float64
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return 0, 0, err
+	}
+	if len(r.Results) == 0 {
+		return 0, 0, fmt.Errorf("geocoding %q: no match", place)
+	}
+	e := geoEntry{Lat: r.Results[0].Latitude, Lng: r.Results[0].Longitude, Time: time.Now()}
+	g.cache[key] = e
+	g.dirty = true
+	return e.Lat, e.Lng, nil
+}
+
+// save writes the cache file if anything new was looked up
+func (g *geocoder) save() error {
+	if !g.dirty {
+		return nil
+	}
+	buf, err := json.MarshalIndent(g.cache, "", " ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(g.file, buf, 0640); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	g.dirty = false
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// centerName is the grid's middle point
+const centerName = "Center"
+
+// gridPoints samples a grid points across, radius miles either side of
+// the center, keeping the ones within radius so it's a circle rather than
+// a square. They're named by how far and which way they are from the
+// center, eg 2.5 mi NE. Each point is that far along that bearing on the
+// globe (see destination), so a grid can cross the antimeridian or get
+// near a pole without the points bunching up or running off the map.
+func gridPoints(lat, lng, radius float64, n int) pointFlag {
+	var ps pointFlag
+	seen := make(map[string]int)
+	step := 2 * radius / float64(n-1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			north, east := -radius+float64(i)*step, -radius+float64(j)*step
+			dist := math.Hypot(north, east)
+			// a little slack so the points on the axes at the edge stay in
+			if dist > radius*1.0001 {
+				continue
+			}
+			name := centerName
+			if dist > radius*1e-6 {
+				bearing := math.Mod(math.Atan2(east, north)*180/math.Pi+360, 360)
+				name = fmt.Sprintf("%.1f mi %s", dist, compass(bearing))
+			}
+			if seen[name]++; seen[name] > 1 {
+				name = fmt.Sprintf("%s #%d", name, seen[name])
+			}
+			plat, plng := destination(lat, lng, math.Atan2(east, north), dist)
+			ps = append(ps, point{name, loc{lat: plat, lng: plng}})
+		}
+	}
+	return ps
+}
+
+// destination is the point miles from lat,lng heading bearing (radians
+// clockwise from north) along a great circle. Flat offsets of miles per
+// degree go wrong far from the equator, where a degree of longitude
+// shrinks to nothing, and past 180 where longitude wraps.
+func destination(lat, lng, bearing, miles float64) (float64, float64) {
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+	phi, d := rad(lat), miles/earthRadiusMiles
+	phi2 := math.Asin(math.Sin(phi)*math.Cos(d) + math.Cos(phi)*math.Sin(d)*math.Cos(bearing))
+	dlng := math.Atan2(math.Sin(bearing)*math.Sin(d)*math.Cos(phi), math.Cos(d)-math.Sin(phi)*math.Sin(phi2))
+	return phi2 * 180 / math.Pi, wrapLng(lng + dlng*180/math.Pi)
+}
+
+// wrapLng brings a longitude into -180 up to (not including) 180, so
+// 181 is -179 and the same place always has the same cache key
+func wrapLng(lng float64) float64 {
+	lng = math.Mod(lng+180, 360)
+	if lng < 0 {
+		lng += 360
+	}
+	return lng - 180
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// checkGrid is each of a grid's points on the map and as far from the
+// center as its name says, within the radius
+func checkGrid(t *testing.T, lat, lng, radius float64, n int) pointFlag {
+	t.Helper()
+	ps := gridPoints(lat, lng, radius, n)
+	if len(ps) == 0 {
+		t.Fatalf("%g,%g: no points", lat, lng)
+	}
+	center := loc{lat: lat, lng: lng}
+	for _, p := range ps {
+		if p.lat < -90 || p.lat > 90 || p.lng < -180 || p.lng >= 180 {
+			t.Errorf("%g,%g: %s is off the map at %g,%g", lat, lng, p.name, p.lat, p.lng)
+		}
+		got := milesBetween(center, p.loc)
+		want := 0.0
+		if p.name != centerName {
+			if _, err := fmt.Sscanf(p.name, "%g mi", &want); err != nil {
+				t.Fatalf("%g,%g: point name %q: %v", lat, lng, p.name, err)
+			}
+		}
+		// the names are to a tenth of a mile
+		if math.Abs(got-want) > .051 || got > radius*1.0001 {
+			t.Errorf("%g,%g: %s is %.2f mi from the center", lat, lng, p.name, got)
+		}
+	}
+	return ps
+}
+
+// TestGridAntimeridian is a grid at 179°E wrapping onto the western
+// hemisphere rather than running past 180
+func TestGridAntimeridian(t *testing.T) {
+	ps := checkGrid(t, 40, 179, 100, 5)
+	var east, west int
+	for _, p := range ps {
+		switch p.name {
+		case "100.0 mi E":
+			// 100 mi is about 1.9° of longitude at 40°N
+			if p.lng > -179 || p.lng < -179.2 {
+				t.Errorf("%s is at %g, want it wrapped to about -179.1", p.name, p.lng)
+			}
+			east++
+		case "100.0 mi W":
+			if p.lng < 176.9 || p.lng > 177.2 {
+				t.Errorf("%s is at %g, want about 177.1", p.name, p.lng)
+			}
+			west++
+		}
+	}
+	if east != 1 || west != 1 {
+		t.Errorf("want a point at the edge due east and west of the center, got %d and %d", east, west)
+	}
+	// and from the other side
+	checkGrid(t, -10, -179.9, 50, 3)
+}
+
+// TestGridBounds is a grid's points staying on the map around the poles
+// and the antimeridian at once
+func TestGridBounds(t *testing.T) {
+	for _, c := range []struct{ lat, lng float64 }{
+		{89.9, 0}, {-89.9, 0}, {89.5, 179.9}, {-89.5, -180}, {0, 180}, {90, 0},
+	} {
+		checkGrid(t, c.lat, c.lng, 50, 7)
+	}
+}
+
+// TestGridSizes is every -grid size having points within the radius,
+// and the odd ones the center and the points along the axes
+func TestGridSizes(t *testing.T) {
+	for n := 3; n <= 15; n++ {
+		ps := checkGrid(t, 40, -75, 5, n)
+		if n%2 == 0 {
+			continue
+		}
+		if ps[len(ps)/2].name != centerName {
+			t.Errorf("-grid %d: the middle point is %s", n, ps[len(ps)/2].name)
+		}
+		if len(ps) < 2*n-1 {
+			t.Errorf("-grid %d has %d points, want at least the %d on the axes", n, len(ps), 2*n-1)
+		}
+	}
+	if ps := gridPoints(40, -75, 5, 2); len(ps) != 0 {
+		t.Errorf("-grid 2 has %d points, only its corners, which are past the radius", len(ps))
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// healthcheckURL is pinged at the end of a run, healthchecks.io style: a
+// GET to the url when it worked, and to url/fail when it didn't
+var healthcheckURL string
+
+func pingHealthcheck(ok bool) {
+	if healthcheckURL == "" {
+		return
+	}
+	u := healthcheckURL
+	if !ok {
+		u = strings.TrimSuffix(u, "/") + "/fail"
+	}
+	req, err := newRequest("GET", u, nil)
+	if err != nil {
+		warn("", err, "healthcheck ping failed")
+		return
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		warn("", err, "healthcheck ping failed")
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		warn("", fmt.Errorf("bad http response %s", resp.Status), "healthcheck ping failed")
+	}
+}
+
+// fatal is log.Fatal that tells the healthcheck first
+func fatal(v ...interface{}) {
+	pingHealthcheck(false)
+	log.Fatal(v...)
+}
+
+func fatalf(format string, v ...interface{}) {
+	pingHealthcheck(false)
+	log.Fatalf(format, v...)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// hereName is what -include-here calls the runner's own location
+const hereName = "Here"
+
+// defaultHereURL is an ip geolocation service, it answers with the
+// caller's approximate latitude and longitude
+const defaultHereURL = "https://ipapi.co/json/"
+
+// locateHere looks up roughly where this machine is from its ip address.
+// Services name the fields differently, latitude/longitude and lat/lon
+// are both understood.
+func locateHere(u string) (loc, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := newRequest("GET", u, nil)
+	if err != nil {
+		return loc{}, err
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return loc{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return loc{}, fmt.Errorf("bad http response %s", resp.Status)
+	}
+	var r struct {
+		Latitude, Longitude *float64
+		Lat, Lon            *float64
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return loc{}, err
+	}
+	if r.Latitude == nil {
+		r.Latitude, r.Longitude = r.Lat, r.Lon
+	}
+	if r.Latitude == nil || r.Longitude == nil {
+		return loc{}, fmt.Errorf("%w, no coordinates in the response", ErrNoData)
+	}
+	return loc{lat: *r.Latitude, lng: *r.Longitude, here: true}, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// appendHistory adds a run to the jsonl history file. The whole run goes
+// out in a single O_APPEND write so runs from overlapping cron jobs land
+// one after the other instead of interleaving.
+func appendHistory(fn string, r *report) error {
+	var buf bytes.Buffer
+	if err := writeJSONL(&buf, r, nil); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("history %s: %v", fn, err)
+	}
+	return f.Close()
+}
+
+// readHistory returns the history lines newer than since. A partial last
+// line, eg from a full disk, is skipped rather than failing the read.
+func readHistory(fn string, since time.Time) ([]jsonlLine, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []jsonlLine
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; s.Scan(); n++ {
+		var l jsonlLine
+		if err := json.Unmarshal(s.Bytes(), &l); err != nil {
+			warn("", err, fmt.Sprintf("skipping bad line %d in %s", n, fn))
+			continue
+		}
+		if l.Time.Before(since) {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	return lines, s.Err()
+}
+
+// lastRun is each location's score in the newest run in the history
+// file, and the scoring version it was run with. A missing file is an
+// empty history.
+func lastRun(fn string) (map[string]float64, string, error) {
+	lines, err := readHistory(fn, time.Time{})
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	scores := make(map[string]float64)
+	last, version := "", ""
+	for _, l := range lines {
+		if l.RunID != last {
+			scores = make(map[string]float64)
+			last = l.RunID
+		}
+		scores[l.Location] = l.Score
+		version = l.ScoringVersion
+	}
+	return scores, version, nil
+}
+
+// summarizeHistory prints each location's runs, wins and average score
+// over the last days of history, best average first
+func summarizeHistory(w io.Writer, fn string, days int) error {
+	lines, err := readHistory(fn, time.Now().AddDate(0, 0, -days))
+	if err != nil {
+		return err
+	}
+	type sum struct {
+		name        string
+		runs, wins  int
+		total, best float64
+	}
+	byLoc := make(map[string]*sum)
+	runs := make(map[string]bool)
+	versions := make(map[string]bool)
+	for _, l := range lines {
+		runs[l.RunID] = true
+		versions[l.ScoringVersion] = true
+		s := byLoc[l.Location]
+		if s == nil {
+			s = &sum{name: l.Location, best: l.Score}
+			byLoc[l.Location] = s
+		}
+		s.runs++
+		s.total += l.Score
+		if l.Score > s.best {
+			s.best = l.Score
+		}
+		if l.Rank == 1 {
+			s.wins++
+		}
+	}
+	sums := make([]*sum, 0, len(byLoc))
+	for _, s := range byLoc {
+		sums = append(sums, s)
+	}
+	sort.Slice(sums, func(i, j int) bool {
+		ai, aj := sums[i].total/float64(sums[i].runs), sums[j].total/float64(sums[j].runs)
+		if ai != aj {
+			return ai > aj
+		}
+		return sums[i].name < sums[j].name
+	})
+	fmt.Fprintf(w, "%d runs in the last %d days\n", len(runs), days)
+	if len(versions) > 1 {
+		var vs []string
+		for _, v := range sortedKeys(versions) {
+			// runs saved before there were versions have none
+			vs = append(vs, orUnknown(v))
+		}
+		fmt.Fprintf(w, "warning: the runs weren't all scored the same way (versions %s), so the averages mix scores that aren't comparable\n",
+			strings.Join(vs, ", "))
+	}
+	for _, s := range sums {
+		fmt.Fprintf(w, "%-20s %4d wins  avg %s  best %s\n", s.name, s.wins,
+			formatNum(s.total/float64(s.runs)), formatNum(s.best))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// homeFlag is -home lat,lng, where the distances are measured from
+type homeFlag struct {
+	set      bool
+	lat, lng float64
+}
+
+func (h *homeFlag) String() string {
+	if !h.set {
+		return ""
+	}
+	return fmt.Sprintf("%g,%g", h.lat, h.lng)
+}
+
+func (h *homeFlag) Set(s string) error {
+	lat, lng, _, err := parseLatLng(s)
+	if err != nil {
+		return fmt.Errorf("-home %v", err)
+	}
+	h.set, h.lat, h.lng = true, lat, lng
+	return nil
+}
+
+// the mean radius of the earth
+const earthRadiusMiles = 3958.8
+
+// miles is the great circle distance from home to l
+func (h homeFlag) miles(l loc) float64 {
+	return milesBetween(loc{lat: h.lat, lng: h.lng}, l)
+}
+
+// milesBetween is the great circle distance from a to b, by the
+// haversine formula. The earth isn't quite a sphere but it's well within
+// what matters for deciding where to go.
+func milesBetween(a, b loc) float64 {
+	rad := func(d float64) float64 { return d * math.Pi / 180 }
+	dlat, dlng := rad(b.lat-a.lat), rad(b.lng-a.lng)
+	h := math.Pow(math.Sin(dlat/2), 2) + math.Cos(rad(a.lat))*math.Cos(rad(b.lat))*math.Pow(math.Sin(dlng/2), 2)
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(h))
+}
+
+// distancePenalty takes perHundred points off n for every 100 miles l is
+// from home, the -distance-penalty score for somewhere you'd have to get to
+func distancePenalty(name string, miles, perHundred, n float64) float64 {
+	if perHundred == 0 {
+		return n
+	}
+	p := perHundred * miles / 100
+	vlog("%s: score %s less %s for being %.0f miles away", name, formatNum(n), formatNum(p), miles)
+	return n - p
+}
+
+// distanceNote is how far from home v is, in its units
+func distanceNote(v locScore) string {
+	unit := "mi"
+	if weather.ConvertDistance(1, "us", v.Units) != 1 {
+		unit = "km"
+	}
+	return localize(strconv.FormatFloat(math.Round(*v.Distance), 'f', 0, 64)) + " " + unit + " from home"
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpClient is used for every outgoing request. Like the default client
+// it honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY, unless setProxy is
+// given an explicit proxy.
+var httpClient = &http.Client{
+	Timeout:   30 * time.Second,
+	Transport: newTransport(),
+}
+
+func newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	return t
+}
+
+// setProxy sends all requests through the proxy at u, ignoring the
+// environment
+func setProxy(u string) error {
+	pu, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if pu.Scheme == "" || pu.Host == "" {
+		return fmt.Errorf("proxy %q should be a url like http://host:port", u)
+	}
+	httpClient.Transport.(*http.Transport).Proxy = http.ProxyURL(pu)
+	return nil
+}
+
+// skipVerify turns off TLS certificate checks for every request. This is
+// DANGEROUS, it's only for testing against local mock servers with self
+// signed certificates.
+func skipVerify() {
+	t := httpClient.Transport.(*http.Transport)
+	t.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+}
+
+// providerHeaders are extra headers sent with every weather provider
+// request, for providers or proxies that want auth in a header.
+var providerHeaders = make(headerFlag)
+
+// headerFlag is a repeatable "Name: value" flag
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	var s []string
+	for k, vs := range h {
+		for _, v := range vs {
+			s = append(s, k+": "+v)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+func (h headerFlag) Set(s string) error {
+	i := strings.Index(s, ":")
+	if i <= 0 {
+		return fmt.Errorf("header %q should look like Name: value", s)
+	}
+	http.Header(h).Add(strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:]))
+	return nil
+}
+
+func userAgent() string {
+	return "slackBestWeather/" + version + " (+https://github.com/reds/cmds)"
+}
+
+// newRequest is http.NewRequest with our User-Agent set
+func newRequest(method, u string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent())
+	return req, nil
+}
+
+// providerGet is a GET to a weather provider, with the extra headers.
+// It asks for gzip itself, so the transport won't decompress for us, use
+// readBody on the response.
+func providerGet(u string) (*http.Response, error) {
+	req, err := newRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	for k, vs := range providerHeaders {
+		req.Header[k] = vs
+	}
+	return httpClient.Do(req)
+}
+
+// readBody reads a response body, gunzipping it if needed
+func readBody(resp *http.Response) ([]byte, error) {
+	var r io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("gzip response from %s: %w", resp.Request.URL.Host, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// scoredDay is one day of a location's forecast scored on its own, for
+// -format ics
+type scoredDay struct {
+	date    time.Time
+	score   float64
+	comfort float64
+	summary string
+}
+
+// icsOpts are the -format and -output ics settings
+type icsOpts struct {
+	// above is the comfort index (0-100) a day needs to be an event
+	above  float64
+	labels bands
+}
+
+// writeICS is -format ics: an iCalendar file with an all day event for
+// each location and scored day at or over the -ics-above comfort index,
+// eg "Gorgeous in Greenville", to import into a calendar. Each event's UID
+// is the location and date, so importing the next run's file updates
+// the events rather than doubling them.
+func writeICS(w io.Writer, r *report, o icsOpts) error {
+	var b strings.Builder
+	line := func(s string) { b.WriteString(foldICS(s) + "\r\n") }
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//reds//slackBestWeather//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:Best weather")
+	stamp := r.Time.UTC().Format("20060102T150405Z")
+	for _, v := range r.Results {
+		l := locations[v.Location]
+		for _, d := range v.days {
+			if d.comfort < o.above {
+				continue
+			}
+			what := o.labels.label(d.comfort)
+			if what == "" {
+				what = "Nice"
+			}
+			line("BEGIN:VEVENT")
+			line(fmt.Sprintf("UID:%s-%s@slackBestWeather", d.date.Format("20060102"), icsSlug(v.Location)))
+			line("DTSTAMP:" + stamp)
+			line("DTSTART;VALUE=DATE:" + d.date.Format("20060102"))
+			line("DTEND;VALUE=DATE:" + d.date.AddDate(0, 0, 1).Format("20060102"))
+			line("SUMMARY:" + escapeICS(fmt.Sprintf("%s in %s", what, v.Location)))
+			line("DESCRIPTION:" + escapeICS(fmt.Sprintf("%s. Score %s, comfort %.0f.", strings.TrimSuffix(d.summary, "."), formatScore(d.score), d.comfort)))
+			line("LOCATION:" + escapeICS(v.Location))
+			line(fmt.Sprintf("GEO:%f;%f", l.lat, l.lng))
+			line("TRANSP:TRANSPARENT")
+			line("END:VEVENT")
+		}
+	}
+	line("END:VCALENDAR")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeICS escapes a TEXT value, RFC 5545 3.3.11
+func escapeICS(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
+
+// foldICS breaks a content line longer than 75 octets onto continuation
+// lines, which start with a space, without splitting a utf-8 character
+func foldICS(s string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range s {
+		size := len(string(r))
+		if n+size > 75 {
+			b.WriteString("\r\n ")
+			n = 1
+		}
+		b.WriteRune(r)
+		n += size
+	}
+	return b.String()
+}
+
+// icsSlug is name made safe for a UID, eg anna-maria
+func icsSlug(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, name)
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// refreshAction is the action_id of the -refresh-button
+const refreshAction = "refresh"
+
+// blockButton is a Block Kit button, in an actions block
+type blockButton struct {
+	Type     string    `json:"type"`
+	Text     blockText `json:"text"`
+	ActionID string    `json:"action_id"`
+}
+
+// refreshBlock is the -refresh-button at the bottom of the blocks
+func refreshBlock() block {
+	return block{Type: "actions", Elements: []interface{}{
+		blockButton{Type: "button", Text: blockText{Type: "plain_text", Text: "Refresh"}, ActionID: refreshAction},
+	}}
+}
+
+// slack signs a request within this long of sending it, an older one is
+// someone replaying it
+const slackSigMaxAge = 5 * time.Minute
+
+// verifySlack checks body came from slack, signed with the app's signing
+// secret as slack documents: v0= and the hex hmac-sha256 of
+// "v0:timestamp:body".
+func verifySlack(secret string, h http.Header, body []byte, now time.Time) error {
+	ts := h.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("no request timestamp")
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > slackSigMaxAge || d < -slackSigMaxAge {
+		return fmt.Errorf("the request timestamp is %s off", d.Round(time.Second))
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(want), []byte(h.Get("X-Slack-Signature"))) {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+// interactive is -interactive-addr, the endpoint slack sends a press of
+// the -refresh-button to. Each refresh is a child run with the same
+// flags, asked for the slack message rather than posting it, and the
+// message goes back in place of the old one through the response_url.
+type interactive struct {
+	so     slackOpts
+	secret string
+	// args are the child's, see refreshArgs
+	args []string
+	// one refresh at a time, a second press waits its turn
+	mu sync.Mutex
+}
+
+// refreshArgs are the flags for a refresh: the serving, scheduling and
+// posting ones dropped, and the message printed as blocks with the
+// button so the refreshed one can be refreshed too
+func refreshArgs(args []string) []string {
+	args = withoutFlags(args, "interactive-addr", "signing-secret-file", "serve-addr", "serve-every", "watch-leader", "at", "at-tz",
+		"format", "output", "require-outputs", "stagger", "webhook", "webhook-file", "webhook-fd", "socket", "thread-ts", "channel",
+		"post-as-snippet", "post-card", "alert-crossing", "share", "quiet")
+	return append([]string{"-format", "slack", "-slack-blocks", "-refresh-button"}, args...)
+}
+
+type interaction struct {
+	Type        string `json:"type"`
+	ResponseURL string `json:"response_url"`
+	User        struct {
+		Name string `json:"name"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+	} `json:"actions"`
+}
+
+func (in *interactive) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(req.Body, 1<<20))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := verifySlack(in.secret, req.Header, body, time.Now()); err != nil {
+		warn("", err, "ignoring an interaction that isn't from slack")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var ia interaction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &ia); err != nil {
+		http.Error(w, "bad payload", http.StatusBadRequest)
+		return
+	}
+	// slack wants an answer within 3 seconds, the report takes longer
+	w.WriteHeader(http.StatusOK)
+	for _, a := range ia.Actions {
+		if a.ActionID == refreshAction && ia.ResponseURL != "" {
+			slog.Info("refresh asked for by " + ia.User.Name)
+			go in.refresh(ia.ResponseURL)
+			return
+		}
+	}
+}
+
+// refresh runs a report and replaces the message with it, or tells
+// whoever pressed the button that it didn't work
+func (in *interactive) refresh(responseURL string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	cmd := exec.Command(os.Args[0], in.args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	var sm slackMsg
+	if err == nil {
+		if err = json.Unmarshal(out, &sm); err != nil {
+			err = fmt.Errorf("reading the report: %v", err)
+		}
+	}
+	if err != nil {
+		warn("", err, "refresh failed")
+		sm = slackMsg{Text: fmt.Sprintf(":warning: The refresh failed (%v), the report above is the last good one.", err), Response_Type: "ephemeral"}
+	} else {
+		sm.Replace_Original = true
+	}
+	buf, err := json.Marshal(sm)
+	if err != nil {
+		warn("", err, "refresh failed")
+		return
+	}
+	if err := postWithRetry(responseURL, "application/json", buf, in.so.retries); err != nil {
+		warn("", err, "updating the message failed")
+	}
+}
+
+// serveInteractive listens on addr for the -refresh-button until the
+// program's stopped
+func serveInteractive(addr, secret string, so slackOpts) error {
+	mux := http.NewServeMux()
+	mux.Handle("/slack/interactive", &interactive{so: so, secret: secret, args: refreshArgs(os.Args[1:])})
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	slog.Info("taking slack interactions on " + addr + "/slack/interactive")
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case <-time.After(time.Second):
+		}
+		if stopping() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			return srv.Shutdown(ctx)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// keyRenames is the -json-keys flag, old=new pairs that rename the keys
+// of the json and jsonl output for tools that want their own names
+type keyRenames map[string]string
+
+func (k keyRenames) String() string {
+	var s []string
+	for o, n := range k {
+		s = append(s, o+"="+n)
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+func (k keyRenames) Set(s string) error {
+	for _, p := range strings.Split(s, ",") {
+		o, n, ok := strings.Cut(strings.TrimSpace(p), "=")
+		if !ok || o == "" || n == "" {
+			return fmt.Errorf("%q should look like location=name", p)
+		}
+		k[o] = n
+	}
+	return nil
+}
+
+// outputKeys are the keys a json or jsonl result line can have
+func outputKeys() map[string]bool {
+	keys := make(map[string]bool)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.Anonymous {
+				walk(f.Type)
+				continue
+			}
+			if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+				keys[name] = true
+			}
+		}
+	}
+	walk(reflect.TypeOf(jsonlLine{}))
+	return keys
+}
+
+// check makes sure every renamed key exists and no two keys end up with
+// the same name
+func (k keyRenames) check() error {
+	keys := outputKeys()
+	final := make(map[string]string)
+	for key := range keys {
+		final[key] = key
+	}
+	for o, n := range k {
+		if !keys[o] {
+			return fmt.Errorf("-json-keys: there's no %q key to rename", o)
+		}
+		final[o] = n
+	}
+	seen := make(map[string]string)
+	for _, key := range sortedKeys(keys) {
+		n := final[key]
+		if other, ok := seen[n]; ok {
+			return fmt.Errorf("-json-keys: %s and %s would both be called %q", other, key, n)
+		}
+		seen[n] = key
+	}
+	return nil
+}
+
+// rename marshals v, an object or a list of them, with the keys renamed.
+// Renamed objects come out with their keys in alphabetical order.
+func (k keyRenames) rename(v interface{}, pretty bool) ([]byte, error) {
+	if len(k) == 0 {
+		return marshal(v, pretty)
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	re := func(m map[string]json.RawMessage) map[string]json.RawMessage {
+		out := make(map[string]json.RawMessage, len(m))
+		for key, val := range m {
+			if n, ok := k[key]; ok {
+				key = n
+			}
+			out[key] = val
+		}
+		return out
+	}
+	var list []map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &list); err == nil {
+		for i := range list {
+			list[i] = re(list[i])
+		}
+		return marshal(list, pretty)
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &obj); err != nil {
+		return nil, err
+	}
+	return marshal(re(obj), pretty)
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// keyPool is -api-keys, several keys for one provider used in turn. A
+// key that gets a 429 is retired for the rest of the run and the request
+// goes again with the next one, until they've all run out.
+type keyPool struct {
+	mu      sync.Mutex
+	keys    []string
+	calls   []int
+	retired []bool
+	next    int
+}
+
+// keyPools are the pools by provider name
+var keyPools = make(map[string]*keyPool)
+
+func newKeyPool(list string) (*keyPool, error) {
+	p := &keyPool{}
+	for _, k := range strings.Split(list, ",") {
+		if k = strings.TrimSpace(k); k != "" && !contains(p.keys, k) {
+			p.keys = append(p.keys, k)
+		}
+	}
+	if len(p.keys) == 0 {
+		return nil, fmt.Errorf("-api-keys has no keys in it")
+	}
+	p.calls = make([]int, len(p.keys))
+	p.retired = make([]bool, len(p.keys))
+	return p, nil
+}
+
+// poolFor is the pool for a cache key's provider, which may have a
+// suffix like forecastio-history
+func poolFor(prov string) *keyPool {
+	name, _, _ := strings.Cut(prov, "-")
+	return keyPools[name]
+}
+
+// pick is the next key that isn't retired, round robin. When they all
+// are it's the first, the provider's rate limit stops it going out.
+func (p *keyPool) pick() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for range p.keys {
+		i := p.next
+		p.next = (p.next + 1) % len(p.keys)
+		if !p.retired[i] {
+			return p.keys[i]
+		}
+	}
+	return p.keys[0]
+}
+
+// index is which key u was made with, -1 for none
+func (p *keyPool) index(u string) int {
+	for i, k := range p.keys {
+		if strings.Contains(u, k) {
+			return i
+		}
+	}
+	return -1
+}
+
+// count notes a request to u
+func (p *keyPool) count(u string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if i := p.index(u); i >= 0 {
+		p.calls[i]++
+	}
+}
+
+// retire takes u's key out of the pool and returns u with a live key
+// in its place, or false when there's none left
+func (p *keyPool) retire(u string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	i := p.index(u)
+	if i < 0 {
+		return u, false
+	}
+	if !p.retired[i] {
+		p.retired[i] = true
+		vlog("key %d of %d rate limited after %d calls, retiring it", i+1, len(p.keys), p.calls[i])
+	}
+	for j, k := range p.keys {
+		if !p.retired[j] {
+			return strings.Replace(u, p.keys[i], k, 1), true
+		}
+	}
+	return u, false
+}
+
+// report logs each key's calls, with -v. The keys go by number so they
+// stay out of the logs.
+func (p *keyPool) report(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.keys {
+		state := ""
+		if p.retired[i] {
+			state = ", retired"
+		}
+		vlog("%s key %d: %d calls%s", name, i+1, p.calls[i], state)
+	}
+}
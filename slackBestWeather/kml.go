@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// kmlFolder is a KML Document or Folder, or the kml element itself. A
+// Google My Maps export has a Folder for each layer.
+type kmlFolder struct {
+	Name       string         `xml:"name"`
+	Documents  []kmlFolder    `xml:"Document"`
+	Folders    []kmlFolder    `xml:"Folder"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name  string `xml:"name"`
+	Point *struct {
+		// lng,lat with an optional altitude
+		Coordinates string `xml:"coordinates"`
+	} `xml:"Point"`
+}
+
+// parseLocationsKML reads locations from a KML file, eg a Google My Maps
+// export: each Placemark's name and point, with the layer (folder) it's
+// in as its region. Lines and areas aren't places to forecast, they're
+// skipped with a warning.
+func parseLocationsKML(fn string, buf []byte) ([]locConfig, error) {
+	var root kmlFolder
+	if err := xml.Unmarshal(buf, &root); err != nil {
+		return nil, fmt.Errorf("%s: %v", fn, err)
+	}
+	var lcs []locConfig
+	var walk func(f kmlFolder, region string) error
+	walk = func(f kmlFolder, region string) error {
+		for _, p := range f.Placemarks {
+			name := strings.TrimSpace(p.Name)
+			if p.Point == nil {
+				warn(name, nil, fmt.Sprintf("%s: not a point (a line or an area?), skipping it", fn))
+				continue
+			}
+			lat, lng, err := kmlCoordinates(p.Point.Coordinates)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %v", fn, name, err)
+			}
+			lcs = append(lcs, locConfig{Name: name, Lat: lat, Lng: lng, Region: region})
+		}
+		for _, d := range f.Documents {
+			// a document is the whole map, not a layer
+			if err := walk(d, region); err != nil {
+				return err
+			}
+		}
+		for _, sub := range f.Folders {
+			if err := walk(sub, strings.TrimSpace(sub.Name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(root, ""); err != nil {
+		return nil, err
+	}
+	if len(lcs) == 0 {
+		return nil, fmt.Errorf("%s: no placemarks with a point", fn)
+	}
+	return lcs, nil
+}
+
+// kmlCoordinates reads a point's "lng,lat[,alt]"
+func kmlCoordinates(s string) (lat, lng float64, err error) {
+	parts := strings.Split(strings.TrimSpace(s), ",")
+	if len(parts) < 2 {
+		return 0, 0, fmt.Errorf("coordinates %q should be lng,lat", s)
+	}
+	if lng, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64); err != nil {
+		return 0, 0, fmt.Errorf("coordinates %q: bad longitude", s)
+	}
+	if lat, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64); err != nil {
+		return 0, 0, fmt.Errorf("coordinates %q: bad latitude", s)
+	}
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return 0, 0, fmt.Errorf("coordinates %q are off the map", s)
+	}
+	return lat, lng, nil
+}
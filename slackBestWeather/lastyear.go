@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// a historian can fetch what the weather was on a past day
+type historian interface {
+	provider
+	history(name string, l loc, t time.Time) (*forecast, error)
+}
+
+// history is a forecast.io time machine request for the day of t. The
+// past doesn't change so it's always served from the cache once fetched,
+// whatever -c says.
+func (p forecastIO) history(name string, l loc, t time.Time) (*forecast, error) {
+	l = p.round(l)
+	u := fmt.Sprintf("%s/forecast/%s/%f,%f,%d?units=%s&exclude=currently,minutely,hourly",
+		strings.TrimSuffix(p.fioBase, "/"), p.key(), l.lat, l.lng, t.Unix(), p.units)
+	d, fetched, err := get(u, cacheKey(p.name()+"-history", l, p.units, t), true)
+	if err != nil {
+		return nil, err
+	}
+	fc, err := parseFIO(name, d, p.units)
+	if err != nil {
+		return nil, err
+	}
+	fc.Fetched = fetched
+	return fc, nil
+}
+
+// findHistorian is the first provider that can look up past weather
+func findHistorian(provs []provider) (historian, bool) {
+	for _, p := range provs {
+		if h, ok := p.(historian); ok {
+			return h, true
+		}
+	}
+	return nil, false
+}
+
+// lastYearDays are the days the run scored that lastYear compares with a
+// year before: the first of them (today, or the -day-offset day), every
+// day of the -weekend, or today for the modes that pick their own day.
+func lastYearDays(f *forecast, sc scoreConfig, now time.Time) []time.Time {
+	if sc.Mode == "peak" || sc.Mode == "now" {
+		return []time.Time{now}
+	}
+	days := weather.ScoredDays(f, sc)
+	if len(days) == 0 {
+		return []time.Time{now}
+	}
+	if !sc.Weekend {
+		days = days[:1]
+	}
+	var ts []time.Time
+	for _, d := range days {
+		ts = append(ts, d.Time)
+	}
+	return ts
+}
+
+// lastYear scores l on the same days a year ago the same way they were
+// scored this year, weight and adjustment included, so the difference is
+// down to the weather. Each day is a time machine request of its own,
+// averaged like Score averages the days.
+func lastYear(h historian, name string, l loc, sc scoreConfig, adj adjustFlag, days []time.Time) (float64, error) {
+	// each response is the one day
+	sc.Days, sc.DayOffset = 1, 0
+	sc.Weekend, sc.NextWeekend = false, false
+	if sc.Mode == "peak" {
+		sc.Mode = "daily"
+	}
+	var total, weights float64
+	for i, t := range days {
+		f, err := h.history(name, l, t.AddDate(-1, 0, 0))
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s: %w", name, h.name(), redactedError{err})
+		}
+		w := sc.DayWeight(i)
+		total += w * weather.Score(f, l.scoring(), sc)
+		weights += w
+	}
+	return adj.adjust(name, l, weigh(name, l, total/weights)), nil
+}
+
+// lastYearNote compares the score to the same day last year
+func (l locScore) lastYearNote() string {
+	if l.LastYear == nil {
+		return ""
+	}
+	d := l.Score - *l.LastYear
+	if formatNum(math.Abs(d)) == formatNum(0) {
+		return fmt.Sprintf("Last year: %s, about the same", formatNum(*l.LastYear))
+	}
+	how := "nicer"
+	if d < 0 {
+		how = "worse"
+	}
+	return fmt.Sprintf("Last year: %s (%s%s, %s this year)", formatNum(*l.LastYear), sign(d), formatNum(d), how)
+}
+
+func sign(v float64) string {
+	if v >= 0 {
+		return "+"
+	}
+	return ""
+}
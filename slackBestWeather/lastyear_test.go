@@ -0,0 +1,72 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// pastWeather is a historian with a made up day for any date: perfect
+// on weekends, wet and cold otherwise, so it's plain which days were
+// looked up
+type pastWeather struct {
+	memProvider
+	asked []time.Time
+}
+
+func (p *pastWeather) history(name string, l loc, t time.Time) (*forecast, error) {
+	p.asked = append(p.asked, t)
+	return &forecast{Units: "us", Timezone: "UTC", Daily: []day{pastDay(t)}}, nil
+}
+
+func pastDay(t time.Time) day {
+	if w := t.Weekday(); w == time.Saturday || w == time.Sunday {
+		return day{Time: t, TemperatureMax: 80, TemperatureMin: 60, Humidity: .6}
+	}
+	return day{Time: t, TemperatureMax: 45, TemperatureMin: 30, Humidity: .9, CloudCover: 1, PrecipProbability: 1}
+}
+
+// TestLastYearDays is -compare-last-year looking up last year's version
+// of the days that were scored, with -weekend and -day-offset
+func TestLastYearDays(t *testing.T) {
+	// a Wednesday
+	now := time.Date(2016, 10, 12, 15, 0, 0, 0, time.UTC)
+	f := &forecast{Units: "us", Timezone: "UTC"}
+	for i := 0; i < 7; i++ {
+		f.Daily = append(f.Daily, day{Time: now.Truncate(24*time.Hour).AddDate(0, 0, i)})
+	}
+	date := func(y, m, d int) time.Time { return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC) }
+	l := loc{lat: 40, lng: -75}
+	for _, c := range []struct {
+		name  string
+		sc    scoreConfig
+		asked []time.Time
+	}{
+		{"today", scoreConfig{Days: 1}, []time.Time{date(2015, 10, 12)}},
+		{"day offset", scoreConfig{Days: 1, DayOffset: 2}, []time.Time{date(2015, 10, 14)}},
+		// this year's Saturday and Sunday were the 15th and 16th, last
+		// year's the same dates fell on a Thursday and Friday
+		{"weekend", scoreConfig{Weekend: true}, []time.Time{date(2015, 10, 15), date(2015, 10, 16)}},
+		{"peak", scoreConfig{Mode: "peak"}, []time.Time{now.AddDate(-1, 0, 0)}},
+	} {
+		h := &pastWeather{}
+		got, err := lastYear(h, "here", l, c.sc, nil, lastYearDays(f, c.sc, now))
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if len(h.asked) != len(c.asked) {
+			t.Fatalf("%s: looked up %v, want %v", c.name, h.asked, c.asked)
+		}
+		var want float64
+		for i, d := range c.asked {
+			if !h.asked[i].Equal(d) {
+				t.Errorf("%s: looked up %s, want %s", c.name, h.asked[i], d)
+			}
+			want += scoreConfig{}.Day(pastDay(d), "us", l.scoring())
+		}
+		want /= float64(len(c.asked))
+		if math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: scored %g, want %g", c.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// providerLimit is how many requests a provider gets at once, set with
+// name:n in -providers, eg forecastio:2,openmeteo:8. Providers that don't
+// say get one, so a strict one is never hammered by default.
+type providerLimit struct {
+	sem chan struct{}
+	// limited is set once the provider rate limits us, after that get
+	// only reads the cache for it rather than make it worse
+	mu      sync.Mutex
+	limited error
+	// cached counts the forecasts that came from the cache instead
+	cached int
+	// usage is the quota as the responses' headers tell it, see noteUsage
+	usage usage
+}
+
+var providerLimits = make(map[string]*providerLimit)
+
+// parseProvider splits a -providers entry into its name and limit
+func parseProvider(s string) (string, int, error) {
+	name, n, ok := strings.Cut(strings.TrimSpace(s), ":")
+	if !ok {
+		return name, 1, nil
+	}
+	c, err := strconv.Atoi(n)
+	if err != nil || c < 1 {
+		return "", 0, fmt.Errorf("provider %q: the concurrency after the : should be a number, 1 or more", s)
+	}
+	return name, c, nil
+}
+
+func setProviderLimit(name string, n int) {
+	providerLimits[name] = &providerLimit{sem: make(chan struct{}, n), usage: usage{calls: -1, limit: -1, remaining: -1}}
+	vlog("%s: up to %d requests at a time", name, n)
+}
+
+// acquire waits for a slot with the provider and returns its release
+func acquire(name string) func() {
+	pl, ok := providerLimits[name]
+	if !ok {
+		return func() {}
+	}
+	pl.sem <- struct{}{}
+	return func() { <-pl.sem }
+}
+
+// noteLimit remembers err if it's the provider rate limiting us
+func noteLimit(name string, err error) {
+	pl, ok := providerLimits[name]
+	if !ok || !errors.Is(err, ErrRateLimited) {
+		return
+	}
+	pl.mu.Lock()
+	if pl.limited == nil {
+		pl.limited = err
+	}
+	pl.mu.Unlock()
+}
+
+// limitedBy is the error the provider rate limited us with, nil if it
+// hasn't
+func limitedBy(name string) error {
+	pl, ok := providerLimits[name]
+	if !ok {
+		return nil
+	}
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.limited
+}
+
+// noteCached counts a forecast read from the cache because the provider
+// rate limited us
+func noteCached(name string) {
+	if pl, ok := providerLimits[name]; ok {
+		pl.mu.Lock()
+		pl.cached++
+		pl.mu.Unlock()
+	}
+}
+
+// rateLimits describes each provider that rate limited the run, eg
+// "forecastio's quota ran out (429 Too Many Requests), 3 forecasts are
+// from the cache"
+func rateLimits() []string {
+	var s []string
+	for _, name := range sortedLimitNames() {
+		pl := providerLimits[name]
+		pl.mu.Lock()
+		switch {
+		case pl.limited != nil && pl.cached > 0:
+			s = append(s, fmt.Sprintf("%s's quota ran out (%v), %d forecasts are from the cache", name, pl.limited, pl.cached))
+		case pl.limited != nil:
+			s = append(s, fmt.Sprintf("%s's quota ran out (%v) with nothing in the cache to fall back on", name, pl.limited))
+		}
+		pl.mu.Unlock()
+	}
+	return s
+}
+
+func sortedLimitNames() []string {
+	names := make([]string, 0, len(providerLimits))
+	for n := range providerLimits {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fetched is a location's fetchAll result
+type fetched struct {
+	f     *forecast
+	notes []string
+	err   error
+}
+
+// fetchEach runs fetchAll for every name at once, leaving the provider
+// limits to decide how many requests are really in flight. When no
+// provider allows more than one (the default), or with -deterministic,
+// it goes through the names in order like it always did. Names at the
+// same point are fetched once, see samePoints.
+func fetchEach(provs []provider, names []string) map[string]fetched {
+	names, same := samePoints(names)
+	res := fetchNames(provs, names)
+	for k, first := range same {
+		r := res[first]
+		if r.f != nil {
+			r.f = cloneForecast(r.f)
+		}
+		res[k] = r
+	}
+	return res
+}
+
+// samePoints picks one name for each point in names to be fetched, the
+// first, and maps the others (aliases, or two lists naming one place
+// differently) to it
+func samePoints(names []string) ([]string, map[string]string) {
+	type point struct{ lat, lng float64 }
+	firsts := make(map[point]string)
+	same := make(map[string]string)
+	var fetch []string
+	for _, k := range names {
+		l := locations[k]
+		p := point{l.lat, l.lng}
+		if first, ok := firsts[p]; ok {
+			vlog("%s is the same point as %s, fetching it once", k, first)
+			same[k] = first
+			continue
+		}
+		firsts[p] = k
+		fetch = append(fetch, k)
+	}
+	return fetch, same
+}
+
+// cloneForecast copies f deep enough that scoring one name's copy (which
+// can fill in days, say) leaves the others alone
+func cloneForecast(f *forecast) *forecast {
+	c := *f
+	c.Daily = append([]day(nil), f.Daily...)
+	c.Hourly = append([]hour(nil), f.Hourly...)
+	c.Minutely = append([]minute(nil), f.Minutely...)
+	c.Alerts = append([]weather.Alert(nil), f.Alerts...)
+	return &c
+}
+
+// fetchNames is fetchEach for names that are all different points
+func fetchNames(provs []provider, names []string) map[string]fetched {
+	wide := false
+	for _, pl := range providerLimits {
+		wide = wide || cap(pl.sem) > 1
+	}
+	if !wide || deterministic {
+		res := make(map[string]fetched)
+		for _, k := range names {
+			if stopping() {
+				break
+			}
+			f, notes, err := fetchAll(provs, k, locations[k])
+			res[k] = fetched{f, notes, err}
+		}
+		return res
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	res := make(map[string]fetched)
+	for _, k := range names {
+		wg.Add(1)
+		go func(k string) {
+			defer wg.Done()
+			r := fetched{err: errInterrupted}
+			if !stopping() {
+				f, notes, err := fetchAll(provs, k, locations[k])
+				r = fetched{f, notes, err}
+			}
+			mu.Lock()
+			res[k] = r
+			mu.Unlock()
+		}(k)
+	}
+	wg.Wait()
+	return res
+}
+
+var errInterrupted = errors.New("interrupted")
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// numLocale is how a locale writes numbers: the decimal mark and what
+// goes between a number and its percent sign
+type numLocale struct {
+	decimal string
+	pct     string
+}
+
+// the locales -locale knows, en-US is how it's always been
+var numLocales = map[string]numLocale{
+	"en-US": {".", ""},
+	"en-GB": {".", ""},
+	"de-DE": {",", " "},
+	"fr-FR": {",", " "},
+	"es-ES": {",", " "},
+	"it-IT": {",", ""},
+	"nl-NL": {",", ""},
+	"pt-BR": {",", ""},
+	"sv-SE": {",", " "},
+}
+
+var curLocale = numLocales["en-US"]
+
+func setLocale(name string) error {
+	l, ok := numLocales[name]
+	if !ok {
+		var names []string
+		for n := range numLocales {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown -locale %q, try one of %s", name, strings.Join(names, ", "))
+	}
+	curLocale = l
+	return nil
+}
+
+// localize swaps the decimal point in a formatted number for the locale's
+func localize(s string) string {
+	return strings.Replace(s, ".", curLocale.decimal, 1)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestLocaleNotes is the notes that put numbers in a sentence writing
+// them all the -locale's way, not some of them en-US's
+func TestLocaleNotes(t *testing.T) {
+	savedLocale, savedPrecision := curLocale, precision
+	t.Cleanup(func() { curLocale, precision = savedLocale, savedPrecision })
+	curLocale, precision = numLocales["de-DE"], 1
+	// a decimal point, or a percent sign without the space before it
+	enUS := regexp.MustCompile(`\d\.\d|\d%`)
+	var notes []string
+	n, ok := closeCall([]locScore{{Location: "Islip", Score: 100}, {Location: "Dublin", Score: 99.5}}, 1)
+	if !ok {
+		t.Fatal("no close call half a point apart")
+	}
+	notes = append(notes, n)
+	p := &memProvider{}
+	fs := []*forecast{{Daily: []day{{PrecipProbability: .8}}}, {Daily: []day{{PrecipProbability: .2}}}}
+	notes = append(notes, disagreements([]provider{p, p}, fs)...)
+	was := []locScore{{Location: "Islip", Score: 100, Units: "us", TemperatureMax: 80, TemperatureMin: 60, Humidity: .6, PrecipProbability: .8}}
+	now := []locScore{{Location: "Islip", Score: 162.5, Units: "us", TemperatureMax: 80, TemperatureMin: 60, Humidity: .6, PrecipProbability: .2}}
+	notes = append(notes, explainDiff(was, now)...)
+	// and the note that the factors were worked out again
+	if len(notes) != 4 {
+		t.Fatalf("want a close call, a disagreement and an explanation, got %q", notes)
+	}
+	for _, n := range notes {
+		if enUS.MatchString(n) {
+			t.Errorf("%q has en-US numbers in it", n)
+		}
+	}
+	for _, want := range []string{"0,5 points", "80" + curLocale.pct + "% vs 20" + curLocale.pct + "%", "(+62,5)", "(+60,0)"} {
+		if !strings.Contains(strings.Join(notes, "\n"), want) {
+			t.Errorf("no %q in %q", want, notes)
+		}
+	}
+}
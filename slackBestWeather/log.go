@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// setLogFormat picks text (the log package's usual lines) or json, one
+// object per line with level, time, msg and any location or error.
+// Setting a slog default also sends plain log.Printf calls through it.
+// quiet drops everything below an error, log.Fatal still gets through.
+func setLogFormat(format string, quiet bool) error {
+	opts := &slog.HandlerOptions{}
+	if quiet {
+		opts.Level = slog.LevelError
+		slog.SetLogLoggerLevel(slog.LevelError)
+	}
+	switch format {
+	case "text":
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, opts)))
+	default:
+		return fmt.Errorf("unknown -log-format %q", format)
+	}
+	return nil
+}
+
+// warn logs a warning about a location, with the error if there is one
+func warn(location string, err error, msg string) {
+	var attrs []any
+	if location != "" {
+		attrs = append(attrs, "location", location)
+	}
+	if err != nil {
+		attrs = append(attrs, "error", redact(err.Error()))
+	}
+	slog.Warn(msg, attrs...)
+}
+
+// verbose turns on vlog output, quiet wins over it
+var verbose, quiet bool
+
+func vlog(format string, v ...interface{}) {
+	if verbose && !quiet {
+		log.Printf(format, v...)
+	}
+}
+
+// secrets are redacted from anything passed through redact
+var secrets []string
+
+func addSecret(s string) {
+	if s != "" {
+		secrets = append(secrets, s)
+	}
+}
+
+// redact masks the secrets in s so it's safe to share
+func redact(s string) string {
+	for _, k := range secrets {
+		s = strings.Replace(s, k, "****", -1)
+	}
+	return s
+}
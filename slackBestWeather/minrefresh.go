@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// minRefresh is -min-refresh, the least time between live fetches of
+// the same forecast. Where -stale-while-revalidate and -c are about how
+// old a forecast can be, this is a rail against a tight loop or a cron
+// typo burning the quota: a forecast fetched more recently than this
+// comes from the cache whatever else the flags say, unless -refetch.
+var minRefresh time.Duration
+
+// refetching is -refetch, which gets past minRefresh
+var refetching bool
+
+// held counts the fetches minRefresh turned into cache reads
+var held atomic.Int64
+
+// tooSoon is whether a forecast fetched at t is too recent to fetch
+// again
+func tooSoon(key string, t time.Time) bool {
+	if minRefresh <= 0 || refetching || time.Since(t) >= minRefresh {
+		return false
+	}
+	vlog("%s was fetched %s, under -min-refresh %s, using the cache", key, ago(t, time.Now()), minRefresh)
+	held.Add(1)
+	return true
+}
+
+// minRefreshNote is what minRefresh did this run, "" if nothing
+func minRefreshNote() string {
+	n := held.Load()
+	if n == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d forecasts were fetched less than -min-refresh %s ago, they're from the cache (-refetch to fetch anyway)", n, minRefresh)
+}
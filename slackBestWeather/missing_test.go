@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// partial are provider responses with some of the scored fields left
+// out, every scored one and only the sky ones
+var partial = []struct {
+	name, fio, om string
+	missing       weather.Metric
+}{
+	{
+		"everything",
+		`{"daily":{"data":[{"time":1476417600,"windSpeed":5,"summary":"?"}]}}`,
+		`{"daily":{"time":[1476417600],"temperature_2m_max":[null],"temperature_2m_min":[null],"relative_humidity_2m_mean":[null],"cloud_cover_mean":[null],"precipitation_probability_max":[null],"wind_speed_10m_max":[5]}}`,
+		weather.TempMax | weather.TempMin | weather.Humidity | weather.CloudCover | weather.PrecipProbability,
+	},
+	{
+		"sky",
+		`{"daily":{"data":[{"time":1476417600,"temperatureMax":80,"temperatureMin":60,"humidity":0.6}]}}`,
+		// a shorter list is missing its last days too
+		`{"daily":{"time":[1476417600],"temperature_2m_max":[80],"temperature_2m_min":[60],"relative_humidity_2m_mean":[60],"cloud_cover_mean":[]}}`,
+		weather.CloudCover | weather.PrecipProbability,
+	},
+}
+
+// TestPartialResponses is a response without some of the fields that are
+// scored noting them in Day.Missing, rather than scoring them as 0
+func TestPartialResponses(t *testing.T) {
+	for _, c := range partial {
+		fio, err := parseFIO("here", []byte(c.fio), "us")
+		if err != nil {
+			t.Fatalf("%s: forecast.io: %v", c.name, err)
+		}
+		om, err := openMeteo{fetchOpts{units: "us"}}.parse("here", []byte(c.om))
+		if err != nil {
+			t.Fatalf("%s: open-meteo: %v", c.name, err)
+		}
+		for prov, f := range map[string]*forecast{"forecast.io": fio, "open-meteo": om} {
+			if got := f.Daily[0].Missing; got != c.missing {
+				t.Errorf("%s: %s is missing %s, want %s", c.name, prov, got, c.missing)
+			}
+		}
+	}
+}
+
+// TestMissingModes is which -missing-sky modes leave a location with a
+// partial forecast out, and that the ones that don't still score it
+func TestMissingModes(t *testing.T) {
+	for _, c := range partial {
+		f, err := parseFIO("here", []byte(c.fio), "us")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, mode := range []string{"exclude", "neutral", "skip"} {
+			sc := scoreConfig{Days: 1}
+			sc.Missing.Set(mode)
+			sc.Missing.Value = weather.DefaultSkyValue
+			// a missing temperature leaves it out whatever the mode, the
+			// sky only with exclude
+			out := mode == "exclude" || c.missing&weather.TempMax != 0
+			m := weather.MissingIn(weather.ScoredDays(f, sc), sc.Needs())
+			if (m != 0) != out {
+				t.Errorf("%s missing with %s: left out for %q, want left out %v", c.name, mode, m, out)
+			}
+			if out {
+				continue
+			}
+			s := weather.Score(f, weather.Location{}, sc)
+			if math.IsNaN(s) || s <= 0 {
+				t.Errorf("%s missing with %s: scored %g", c.name, mode, s)
+			}
+			// not as though the sky was perfect
+			clear := *f
+			clear.Daily = []day{f.Daily[0]}
+			clear.Daily[0].Missing = 0
+			if best := weather.Score(&clear, weather.Location{}, sc); mode == "neutral" && s >= best {
+				t.Errorf("%s missing with neutral: scored %g, as good as a clear dry day's %g", c.name, s, best)
+			}
+		}
+	}
+}
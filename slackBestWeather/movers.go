@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// mover is a location's score change since the last run
+type mover struct {
+	location string
+	delta    float64
+}
+
+// topMovers are the n biggest risers, best first, and the n biggest
+// fallers, worst first, against prev (the last run's scores). Locations
+// new since then have nothing to move from and are left out.
+func topMovers(prev map[string]float64, res []locScore, n int) (up, down []mover) {
+	var all []mover
+	for _, v := range res {
+		if p, ok := prev[v.Location]; ok && v.Score != p {
+			all = append(all, mover{v.Location, v.Score - p})
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].delta > all[j].delta })
+	for i := 0; i < len(all) && len(up) < n && all[i].delta > 0; i++ {
+		up = append(up, all[i])
+	}
+	for i := len(all) - 1; i >= 0 && len(down) < n && all[i].delta < 0; i-- {
+		down = append(down, all[i])
+	}
+	return up, down
+}
+
+// moversAttachment is the -top-movers section, nil when nothing moved
+func moversAttachment(up, down []mover) *attachment {
+	if len(up) == 0 && len(down) == 0 {
+		return nil
+	}
+	line := func(emoji string, ms []mover) string {
+		var s []string
+		for _, m := range ms {
+			sign := ""
+			if m.delta > 0 {
+				sign = "+"
+			}
+			s = append(s, fmt.Sprintf("%s %s%s", m.location, sign, formatNum(m.delta)))
+		}
+		return emoji + " " + strings.Join(s, ", ")
+	}
+	var lines []string
+	if len(up) > 0 {
+		lines = append(lines, line(":chart_with_upwards_trend:", up))
+	}
+	if len(down) > 0 {
+		lines = append(lines, line(":chart_with_downwards_trend:", down))
+	}
+	return &attachment{
+		Title:     "Top movers since the last run",
+		Fallback:  strings.Join(lines, "\n"),
+		Text:      strings.Join(lines, "\n"),
+		Mrkdwn_In: []string{"text"},
+	}
+}
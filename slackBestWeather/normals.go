@@ -0,0 +1,21 @@
+package main
+
+// builtinNormals are rounded 1991-2020 NOAA / Met Éireann monthly normal
+// highs (fahrenheit) for the built in locations
+var builtinNormals = map[string][]float64{
+	"Islip":      {39, 41, 48, 58, 68, 77, 83, 82, 75, 64, 54, 44},
+	"Bryn Mawr":  {41, 44, 53, 64, 74, 83, 87, 85, 78, 67, 56, 45},
+	"Ann Arbor":  {31, 34, 45, 59, 70, 79, 83, 81, 74, 61, 48, 35},
+	"Dublin":     {47, 48, 51, 54, 59, 63, 66, 66, 62, 57, 51, 48},
+	"Greenville": {52, 56, 64, 72, 80, 87, 90, 89, 82, 73, 63, 54},
+	"Anna Maria": {72, 74, 78, 82, 87, 90, 91, 91, 90, 85, 79, 74},
+}
+
+func init() {
+	for k, n := range builtinNormals {
+		if l, ok := locations[k]; ok {
+			l.normals = n
+			locations[k] = l
+		}
+	}
+}
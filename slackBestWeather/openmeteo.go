@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// open-meteo.com, a free provider that doesn't need a key
+type openMeteo struct {
+	fetchOpts
+}
+
+type omResp struct {
+	// Timezone is the IANA zone, with timezone=auto
+	Timezone string
+	Daily    struct {
+		Time []int64
+		// the scored ones are pointers, open-meteo sends null for a day
+		// it has no value for
+		Temperature_2m_Max            []*float64
+		Temperature_2m_Min            []*float64
+		Relative_Humidity_2m_Mean     []*float64
+		Cloud_Cover_Mean              []*float64
+		Precipitation_Probability_Max []*float64
+		Pressure_Msl_Mean             []float64
+		Dew_Point_2m_Mean             []float64
+		Wind_Speed_10m_Max            []float64
+		Snowfall_Sum                  []float64
+		Weather_Code                  []int
+		Sunrise                       []int64
+		Sunset                        []int64
+		Wind_Direction_10m_Dominant   []float64
+		Wind_Gusts_10m_Max            []float64
+	}
+}
+
+func (p openMeteo) name() string { return "openmeteo" }
+
+// units are the units the forecast comes back in for -units and the
+// temperature_unit and wind_speed_unit to ask for them with
+func (p openMeteo) unitParams() (units, tu, wu string) {
+	units, tu, wu = "us", "fahrenheit", "mph"
+	switch p.units {
+	case "si":
+		units, tu, wu = p.units, "celsius", "ms"
+	case "ca":
+		units, tu, wu = p.units, "celsius", "kmh"
+	case "uk", "uk2":
+		units, tu = p.units, "celsius"
+	}
+	return units, tu, wu
+}
+
+func (p openMeteo) fetch(name string, l loc) (*forecast, error) {
+	l = p.round(l)
+	_, tu, wu := p.unitParams()
+	u := fmt.Sprintf("https://api.open-meteo.com/v1/forecast?latitude=%f&longitude=%f&timezone=auto&timeformat=unixtime&temperature_unit=%s&wind_speed_unit=%s"+
+		"&daily=temperature_2m_max,temperature_2m_min,relative_humidity_2m_mean,cloud_cover_mean,precipitation_probability_max,pressure_msl_mean,wind_speed_10m_max,dew_point_2m_mean,snowfall_sum,weather_code,sunrise,sunset,wind_direction_10m_dominant,wind_gusts_10m_max",
+		l.lat, l.lng, tu, wu)
+	buf, fetched, err := get(u, cacheKey(p.name(), l, p.units, time.Now()), p.useCache)
+	if err != nil {
+		return nil, err
+	}
+	fc, err := p.parse(name, buf)
+	if err != nil {
+		return nil, err
+	}
+	fc.Fetched = fetched
+	return fc, nil
+}
+
+func (p openMeteo) parse(name string, buf []byte) (*forecast, error) {
+	units, _, _ := p.unitParams()
+	var r omResp
+	if err := json.Unmarshal(buf, &r); err != nil {
+		return nil, err
+	}
+	dd := r.Daily
+	fc := &forecast{Units: units, Timezone: r.Timezone}
+	for i, t := range dd.Time {
+		at := func(v []float64) float64 {
+			if i < len(v) {
+				return v[i]
+			}
+			return 0
+		}
+		code := 0
+		if i < len(dd.Weather_Code) {
+			code = dd.Weather_Code[i]
+		}
+		var missing weather.Metric
+		scored := func(v []*float64, m weather.Metric) float64 {
+			if i < len(v) && v[i] != nil {
+				return *v[i]
+			}
+			missing |= m
+			return 0
+		}
+		cond, summary := wmoCondition(code)
+		sun := func(v []int64) time.Time {
+			if i < len(v) {
+				return time.Unix(v[i], 0)
+			}
+			return time.Time{}
+		}
+		fc.Daily = append(fc.Daily, day{
+			Time:              time.Unix(t, 0),
+			Summary:           summary,
+			Icon:              cond.String(),
+			Condition:         cond,
+			TemperatureMax:    scored(dd.Temperature_2m_Max, weather.TempMax),
+			TemperatureMin:    scored(dd.Temperature_2m_Min, weather.TempMin),
+			Humidity:          scored(dd.Relative_Humidity_2m_Mean, weather.Humidity) / 100,
+			CloudCover:        scored(dd.Cloud_Cover_Mean, weather.CloudCover) / 100,
+			PrecipProbability: scored(dd.Precipitation_Probability_Max, weather.PrecipProbability) / 100,
+			Pressure:          at(dd.Pressure_Msl_Mean),
+			WindSpeed:         at(dd.Wind_Speed_10m_Max),
+			DewPoint:          at(dd.Dew_Point_2m_Mean),
+			// snowfall_sum is always cm
+			PrecipAccumulation: weather.ConvertDepth(at(dd.Snowfall_Sum), "si", units),
+			Sunrise:            sun(dd.Sunrise),
+			Sunset:             sun(dd.Sunset),
+			WindBearing:        at(dd.Wind_Direction_10m_Dominant),
+			Missing:            missing,
+			Extra:              dayExtra{WindGust: at(dd.Wind_Gusts_10m_Max)},
+		})
+	}
+	return fc, nil
+}
+
+// wmoCondition maps a WMO weather code to a condition and a short summary
+func wmoCondition(code int) (condition, string) {
+	switch {
+	case code == 0:
+		return weather.CondClear, "Clear."
+	case code <= 2:
+		return weather.CondPartlyCloudy, "Partly cloudy."
+	case code == 3:
+		return weather.CondCloudy, "Overcast."
+	case code == 45 || code == 48:
+		return weather.CondFog, "Foggy."
+	case code == 66 || code == 67:
+		return weather.CondSleet, "Freezing rain."
+	case code >= 71 && code <= 77, code == 85, code == 86:
+		return weather.CondSnow, "Snow."
+	case code >= 95:
+		return weather.CondRain, "Thunderstorms."
+	case code >= 51:
+		return weather.CondRain, "Rain."
+	}
+	return weather.CondCloudy, ""
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// writeJSON writes the results as a json list, without the rest of the
+// report (see writeReport for that)
+func writeJSON(w io.Writer, r *report, pretty bool, keys keyRenames) error {
+	buf, err := keys.rename(r.Results, pretty)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(buf))
+	return err
+}
+
+// jsonlLine is one location's result in a jsonl run
+type jsonlLine struct {
+	RunID string    `json:"run_id"`
+	Time  time.Time `json:"time"`
+	Rank  int       `json:"rank"`
+	locScore
+}
+
+// jsonl writes one location per line, in rank order. Every line carries
+// the same run id and timestamp so a log pipeline can group a run back
+// together.
+func writeJSONL(w io.Writer, r *report, keys keyRenames) error {
+	for i, v := range r.Results {
+		buf, err := keys.rename(jsonlLine{RunID: r.RunID, Time: r.Time, Rank: i + 1, locScore: v}, false)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, string(buf)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGeoJSON writes a FeatureCollection with a point per location, for
+// dropping into geojson.io or a leaflet map. color is the slack color for
+// a location. The run's details go in foreign members next to the
+// features, which geojson readers ignore.
+func writeGeoJSON(w io.Writer, r *report, color func(locScore) string, pretty bool) error {
+	type geometry struct {
+		Type        string    `json:"type"`
+		Coordinates []float64 `json:"coordinates"`
+	}
+	type feature struct {
+		Type       string                 `json:"type"`
+		Geometry   geometry               `json:"geometry"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+	fc := struct {
+		Type      string    `json:"type"`
+		Features  []feature `json:"features"`
+		Time      time.Time `json:"time"`
+		Providers []string  `json:"providers"`
+		Mode      string    `json:"mode"`
+	}{Type: "FeatureCollection", Features: []feature{}, Time: r.Time, Providers: r.Providers, Mode: r.Mode}
+	for i, v := range r.Results {
+		l := locations[v.Location]
+		fc.Features = append(fc.Features, feature{
+			Type: "Feature",
+			// geojson is longitude first
+			Geometry: geometry{Type: "Point", Coordinates: []float64{l.lng, l.lat}},
+			Properties: map[string]interface{}{
+				"name":           v.Location,
+				"rank":           i + 1,
+				"score":          v.Score,
+				"label":          v.Label,
+				"summary":        v.Summary,
+				"temperatureMax": v.TemperatureMax,
+				"temperatureMin": v.TemperatureMin,
+				"units":          v.Units,
+				// simplestyle, which geojson.io and friends draw with
+				"marker-color": color(v),
+			},
+		})
+	}
+	buf, err := marshal(fc, pretty)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(buf))
+	return err
+}
+
+// marshal is json.Marshal, indented for people to read when pretty is set
+func marshal(v interface{}, pretty bool) ([]byte, error) {
+	if pretty {
+		return json.MarshalIndent(v, "", " ")
+	}
+	return json.Marshal(v)
+}
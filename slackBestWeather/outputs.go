@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// a notifier is somewhere a run's report goes, see -output
+type notifier interface {
+	name() string
+	notify(r *report) error
+}
+
+// outputSpec is one -output, a kind and where it goes
+type outputSpec struct {
+	kind, path string
+}
+
+// outputFlag is the repeatable -output kind[:path]
+type outputFlag []outputSpec
+
+func (o *outputFlag) String() string {
+	var s []string
+	for _, sp := range *o {
+		s = append(s, sp.String())
+	}
+	return strings.Join(s, ",")
+}
+
+func (sp outputSpec) String() string {
+	if sp.path == "" {
+		return sp.kind
+	}
+	return sp.kind + ":" + sp.path
+}
+
+func (o *outputFlag) Set(s string) error {
+	kind, path, _ := strings.Cut(s, ":")
+	switch kind {
+	case "slack":
+		if path != "" {
+			return fmt.Errorf("-output slack goes to -webhook, it doesn't take a path")
+		}
+	case "json", "jsonl", "geojson", "report", "png", "ics":
+		if path == "" {
+			path = "-"
+		}
+	case "csv", "history":
+		if path == "" {
+			return fmt.Errorf("-output %s needs a file, eg %s:runs.%s", kind, kind, map[string]string{"csv": "csv", "history": "jsonl"}[kind])
+		}
+	default:
+		return fmt.Errorf("unknown -output %q, it should be slack, json, jsonl, geojson, report, png, ics, csv or history", kind)
+	}
+	*o = append(*o, outputSpec{kind, path})
+	return nil
+}
+
+// has is whether one of the outputs is kind
+func (o outputFlag) has(kind string) bool {
+	for _, sp := range o {
+		if sp.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// notifiers builds the sinks for the outputs
+func (o outputFlag) notifiers(so slackOpts, keys keyRenames, ics icsOpts) []notifier {
+	var ns []notifier
+	for _, sp := range o {
+		sp := sp
+		switch sp.kind {
+		case "slack":
+			ns = append(ns, slackSink{so})
+		case "json":
+			ns = append(ns, fileSink{sp, func(w io.Writer, r *report) error { return writeJSON(w, r, so.pretty, keys) }})
+		case "jsonl":
+			ns = append(ns, fileSink{sp, func(w io.Writer, r *report) error { return writeJSONL(w, r, keys) }})
+		case "geojson":
+			ns = append(ns, fileSink{sp, func(w io.Writer, r *report) error {
+				return writeGeoJSON(w, r, func(v locScore) string { return so.color(v, r.Results) }, so.pretty)
+			}})
+		case "report":
+			ns = append(ns, fileSink{sp, func(w io.Writer, r *report) error { return writeReport(w, r, so.pretty, keys) }})
+		case "png":
+			ns = append(ns, fileSink{sp, func(w io.Writer, r *report) error { return writeCard(w, r, so) }})
+		case "ics":
+			ns = append(ns, fileSink{sp, func(w io.Writer, r *report) error { return writeICS(w, r, ics) }})
+		case "csv":
+			ns = append(ns, csvSink{sp.path})
+		case "history":
+			ns = append(ns, historySink{sp.path})
+		}
+	}
+	return ns
+}
+
+type slackSink struct{ so slackOpts }
+
+func (s slackSink) name() string           { return "slack" }
+func (s slackSink) notify(r *report) error { return sendToSlack(s.so, r) }
+
+// fileSink writes a format to a file, replacing it, or to stdout for -
+type fileSink struct {
+	outputSpec
+	write func(io.Writer, *report) error
+}
+
+func (s fileSink) name() string { return s.outputSpec.String() }
+
+func (s fileSink) notify(r *report) error {
+	if s.path == "-" {
+		return s.write(os.Stdout, r)
+	}
+	var b strings.Builder
+	if err := s.write(&b, r); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, []byte(b.String()), 0640)
+}
+
+// csvSink appends the results to a csv log, a row per location with a
+// header when the file is new
+type csvSink struct{ path string }
+
+func (s csvSink) name() string { return "csv:" + s.path }
+
+func (s csvSink) notify(r *report) error {
+	_, err := os.Stat(s.path)
+	isNew := os.IsNotExist(err)
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	if isNew {
+		w.Write([]string{"run_id", "time", "rank", "location", "score", "comfort", "summary",
+			"temperatureMax", "temperatureMin", "humidity", "cloudCover", "precipProbability", "units"})
+	}
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for i, v := range r.Results {
+		w.Write([]string{r.RunID, r.Time.Format("2006-01-02T15:04:05Z07:00"), strconv.Itoa(i + 1), v.Location,
+			num(v.Score), num(v.Comfort), v.Summary, num(v.TemperatureMax), num(v.TemperatureMin),
+			num(v.Humidity), num(v.CloudCover), num(v.PrecipProbability), v.Units})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		f.Close()
+		return fmt.Errorf("%s: %v", s.path, err)
+	}
+	return f.Close()
+}
+
+type historySink struct{ path string }
+
+func (s historySink) name() string           { return "history:" + s.path }
+func (s historySink) notify(r *report) error { return appendHistory(s.path, r) }
+
+// notifyAll sends r to every sink, even after one fails. It's an error
+// if a required one failed, the others only warn. With no required list
+// they all are.
+func notifyAll(ns []notifier, r *report, required []string) error {
+	var errs []error
+	for _, n := range ns {
+		err := n.notify(r)
+		if err == nil {
+			continue
+		}
+		kind, _, _ := strings.Cut(n.name(), ":")
+		if len(required) == 0 || contains(required, kind) || contains(required, n.name()) {
+			errs = append(errs, fmt.Errorf("%s: %w", n.name(), err))
+			continue
+		}
+		warn("", err, n.name()+" output failed, it isn't required")
+	}
+	return errors.Join(errs...)
+}
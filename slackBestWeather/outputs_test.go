@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestOutputICS is -output ics writing the same calendar -format ics
+// does, with only the days over -ics-above in it
+func TestOutputICS(t *testing.T) {
+	t.Chdir(t.TempDir())
+	var o outputFlag
+	if err := o.Set("ics:best.ics"); err != nil {
+		t.Fatal(err)
+	}
+	day := func(d int) time.Time { return time.Date(2016, 10, d, 0, 0, 0, 0, time.UTC) }
+	r := &report{Time: day(12), Results: []locScore{{Location: "Islip", days: []scoredDay{
+		{date: day(14), score: 430, comfort: 72, summary: "Clear."},
+		{date: day(15), score: 200, comfort: 40, summary: "Rain."},
+	}}}}
+	if err := notifyAll(o.notifiers(slackOpts{}, nil, icsOpts{above: 70}), r, nil); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := os.ReadFile("best.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	writeICS(&b, r, icsOpts{above: 70})
+	if string(buf) != b.String() {
+		t.Errorf("-output ics wrote\n%s\nwant\n%s", buf, b.String())
+	}
+	if n := strings.Count(string(buf), "BEGIN:VEVENT"); n != 1 {
+		t.Errorf("%d events, want the one day over 70", n)
+	}
+}
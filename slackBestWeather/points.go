@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// point is an ad hoc location from -point
+type point struct {
+	name string
+	loc
+}
+
+// pointFlag is the repeatable -point lat,lng[,label] flag. Points replace
+// the configured locations for a quick one off comparison.
+type pointFlag []point
+
+func (p *pointFlag) String() string {
+	if p == nil {
+		return ""
+	}
+	var s []string
+	for _, v := range *p {
+		s = append(s, fmt.Sprintf("%g,%g,%s", v.lat, v.lng, v.name))
+	}
+	return strings.Join(s, " ")
+}
+
+func (p *pointFlag) Set(s string) error {
+	lat, lng, label, err := parseLatLng(s)
+	if err != nil {
+		return fmt.Errorf("point %v", err)
+	}
+	name := fmt.Sprintf("Point %d", len(*p)+1)
+	if label != "" {
+		name = label
+	}
+	for _, v := range *p {
+		if v.name == name {
+			return fmt.Errorf("point %q: there's already a point called %s", s, name)
+		}
+	}
+	*p = append(*p, point{name, loc{lat: lat, lng: lng}})
+	return nil
+}
+
+// parseLatLng reads lat,lng with anything after a third comma as the label
+func parseLatLng(s string) (lat, lng float64, label string, err error) {
+	parts := strings.SplitN(s, ",", 3)
+	if len(parts) < 2 {
+		return 0, 0, "", fmt.Errorf("%q should be lat,lng or lat,lng,label", s)
+	}
+	lat, err = strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, "", fmt.Errorf("%q: latitude should be a number from -90 to 90", s)
+	}
+	lng, err = strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lng < -180 || lng > 180 {
+		return 0, 0, "", fmt.Errorf("%q: longitude should be a number from -180 to 180", s)
+	}
+	if len(parts) == 3 {
+		label = strings.TrimSpace(parts[2])
+	}
+	return lat, lng, label, nil
+}
+
+// use makes the points the only locations, in the order they were given
+func (p pointFlag) use() {
+	locations = make(map[string]loc)
+	disabled = make(map[string]bool)
+	configOrder = nil
+	for _, v := range p {
+		v.normals = builtinNormals[v.name]
+		locations[v.name] = v.loc
+		configOrder = append(configOrder, v.name)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// how long each -preflight probe gets
+const probeTimeout = 10 * time.Second
+
+// probeURL is a cheap url on p's server to check it's reachable
+func probeURL(p provider) string {
+	switch p := p.(type) {
+	case forecastIO:
+		return p.fioBase
+	case openMeteo:
+		return "https://api.open-meteo.com"
+	}
+	return ""
+}
+
+// probe sends a HEAD to u. Any http response at all means the server is
+// up, a webhook url for one answers HEAD with an error status.
+func probe(u string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	req, err := newRequest("HEAD", u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// preflight checks the providers and the webhook can be reached before
+// any work is done, so an outage is one clear error up front rather than
+// a skipped location per fetch and a failed post at the end.
+// slack is the webhook, or the web api url when posting through that.
+func preflight(provs []provider, slack string) error {
+	for _, p := range provs {
+		u := probeURL(p)
+		if u == "" {
+			continue
+		}
+		if err := probe(u); err != nil {
+			return fmt.Errorf("preflight: can't reach %s: %v", p.name(), redactedError{err})
+		}
+		vlog("preflight: %s is reachable", p.name())
+	}
+	if slack != "" {
+		if err := probe(slack); err != nil {
+			host := "the webhook"
+			if u, perr := url.Parse(slack); perr == nil {
+				host = u.Host
+			}
+			return fmt.Errorf("preflight: can't reach slack at %s: %v", host, redactedError{err})
+		}
+		vlog("preflight: slack is reachable")
+	}
+	return nil
+}
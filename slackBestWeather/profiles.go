@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// scoringModes are the -mode values, each its own idea of a good day
+var scoringModes = []string{"daily", "now", "ski", "photo", "sail", "beach", "event", "peak"}
+
+// parseModes checks a comma separated list of modes for -profile-compare
+func parseModes(s string) ([]string, error) {
+	var ms []string
+	for _, m := range strings.Split(s, ",") {
+		m = strings.TrimSpace(m)
+		if !contains(scoringModes, m) {
+			return nil, fmt.Errorf("unknown mode %q, it should be one of %s", m, strings.Join(scoringModes, ", "))
+		}
+		if !contains(ms, m) {
+			ms = append(ms, m)
+		}
+	}
+	return ms, nil
+}
+
+// profileOpts is what -profile-compare needs from main to score like a
+// normal run does
+type profileOpts struct {
+	fo             fetchOpts
+	adjust         adjustFlag
+	fallbackHourly bool
+	waterSource    string
+}
+
+// profileCompare scores every location under each of modes and tables
+// the scores and ranks side by side, a column per mode. The modes score
+// on different scales so the ranks are what to compare.
+func profileCompare(provs []provider, names []string, modes []string, sc scoreConfig, po profileOpts) (string, error) {
+	all := fetchEach(provs, names)
+	scores := make(map[string]map[string]float64)
+	var rows []string
+	for _, k := range names {
+		if stopping() {
+			return "", errInterrupted
+		}
+		f, err := all[k].f, all[k].err
+		if err != nil {
+			warn(k, err, "skipping location")
+			continue
+		}
+		v := locations[k]
+		f.EnsureDays(sc.Days, po.fallbackHourly)
+		if contains(modes, "beach") && v.beach {
+			if err := addWaterTemps(f, v, po.waterSource, po.fo); err != nil {
+				warn(k, redactedError{err}, "no water temperature, scoring beach without it")
+			}
+		}
+		scores[k] = make(map[string]float64)
+		for _, m := range modes {
+			c := sc
+			c.Mode = m
+			scores[k][m] = po.adjust.adjust(k, v, weigh(k, v, weather.Score(f, v.scoring(), c)))
+		}
+		rows = append(rows, k)
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("no locations could be scored")
+	}
+	rank := make(map[string]map[string]int)
+	for _, m := range modes {
+		byMode := append([]string(nil), rows...)
+		sort.SliceStable(byMode, func(i, j int) bool { return scores[byMode[i]][m] > scores[byMode[j]][m] })
+		rank[m] = make(map[string]int)
+		for i, k := range byMode {
+			rank[m][k] = i + 1
+		}
+	}
+	// the rows in the first mode's order
+	sort.SliceStable(rows, func(i, j int) bool { return rank[modes[0]][rows[i]] < rank[modes[0]][rows[j]] })
+	var b strings.Builder
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Location\t%s\n", strings.Join(modes, "\t"))
+	for _, k := range rows {
+		cells := make([]string, len(modes))
+		for i, m := range modes {
+			cells[i] = fmt.Sprintf("%s (#%d)", formatNum(scores[k][m]), rank[m][k])
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", k, strings.Join(cells, "\t"))
+	}
+	tw.Flush()
+	return b.String(), nil
+}
+
+// sendProfileCompare prints the table, or posts it as a code block when
+// there's somewhere to post it
+func sendProfileCompare(so slackOpts, table string) error {
+	if so.webhook == "" && so.threadTS == "" && so.out == nil {
+		_, err := fmt.Fprint(os.Stdout, table)
+		return err
+	}
+	return sendText(so, "Scores under each mode (rank in brackets)\n```\n"+table+"```")
+}
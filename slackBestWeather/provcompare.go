@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// compareProviders is -compare-providers: name's forecast for today
+// from each provider side by side, in the first one's units, with how
+// far apart they are. It's for working out why switching providers
+// changes the winner.
+func compareProviders(w io.Writer, provs []provider, name string, sc scoreConfig) error {
+	l, ok := locations[name]
+	if !ok {
+		return fmt.Errorf("-compare-providers location %q isn't configured", name)
+	}
+	if len(provs) < 2 {
+		return fmt.Errorf("-compare-providers needs two or more -providers to compare")
+	}
+	var cols []string
+	var fs []*forecast
+	units := ""
+	for _, p := range provs {
+		release := acquire(p.name())
+		f, err := p.fetch(name, l)
+		release()
+		if err == nil && len(f.Daily) == 0 {
+			err = fmt.Errorf("%w, no daily data", ErrNoData)
+		}
+		if err != nil {
+			warn(name, redactedError{err}, p.name()+" failed, leaving it out of the comparison")
+			continue
+		}
+		if units == "" {
+			units = f.Units
+		}
+		f.Convert(units)
+		cols = append(cols, p.name())
+		fs = append(fs, f)
+	}
+	if len(fs) == 0 {
+		return fmt.Errorf("%s: no provider had a forecast", name)
+	}
+	rows := []struct {
+		name   string
+		metric weather.Metric
+		value  func(d day) float64
+		format func(float64) string
+	}{
+		{"high", weather.TempMax, func(d day) float64 { return d.TemperatureMax }, formatNum},
+		{"low", weather.TempMin, func(d day) float64 { return d.TemperatureMin }, formatNum},
+		{"humidity", weather.Humidity, func(d day) float64 { return d.Humidity }, formatPct},
+		{"clouds", weather.CloudCover, func(d day) float64 { return d.CloudCover }, formatPct},
+		{"precip", weather.PrecipProbability, func(d day) float64 { return d.PrecipProbability }, formatPct},
+		{"wind", 0, func(d day) float64 { return d.WindSpeed }, formatNum},
+		{"dew point", 0, func(d day) float64 { return d.DewPoint }, formatNum},
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s (%s)\t%s\tspread\n", name, units, strings.Join(cols, "\t"))
+	for _, r := range rows {
+		cells := make([]string, len(fs))
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for i, f := range fs {
+			d := f.Daily[0]
+			if r.metric != 0 && d.Missing&r.metric != 0 {
+				cells[i] = "-"
+				continue
+			}
+			v := r.value(d)
+			cells[i] = r.format(v)
+			lo, hi = math.Min(lo, v), math.Max(hi, v)
+		}
+		spread := "-"
+		if hi >= lo {
+			spread = r.format(hi - lo)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.name, strings.Join(cells, "\t"), spread)
+	}
+	conds := make([]string, len(fs))
+	scores := make([]string, len(fs))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, f := range fs {
+		conds[i] = f.Daily[0].Condition.String()
+		s := weather.Score(f, l.scoring(), sc)
+		scores[i] = formatNum(s)
+		lo, hi = math.Min(lo, s), math.Max(hi, s)
+	}
+	fmt.Fprintf(tw, "condition\t%s\t\n", strings.Join(conds, "\t"))
+	fmt.Fprintf(tw, "score\t%s\t%s\n", strings.Join(scores, "\t"), formatNum(hi-lo))
+	return tw.Flush()
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// a provider is a weather service that can produce a forecast for a location
+type provider interface {
+	name() string
+	fetch(name string, l loc) (*forecast, error)
+	// parse turns a raw response, as fetch got it, into a forecast
+	parse(name string, buf []byte) (*forecast, error)
+}
+
+// fetchOpts are the settings shared by all the providers
+type fetchOpts struct {
+	units    string
+	useCache bool
+	// forecast.io api key
+	fioKey string
+	// fioBase is the forecast.io api url, it can point at a mock server
+	fioBase string
+	// precision is how many decimal places of the coordinates are sent
+	// and cached, see round
+	precision int
+}
+
+// round cuts l's coordinates to fo.precision decimal places. Each place
+// is a factor of ten: 4 is about 11m, 3 about 110m and 2 about 1.1km.
+// Forecasts are on a grid kilometres wide so even 2 gives the same
+// weather, and points that round the same share a cache entry. Past 3 or
+// so it only costs cache hits.
+func (fo fetchOpts) round(l loc) loc {
+	p := math.Pow(10, float64(fo.precision))
+	l.lat = math.Round(l.lat*p) / p
+	l.lng = math.Round(l.lng*p) / p
+	return l
+}
+
+func newProviders(list string, fo fetchOpts) ([]provider, error) {
+	var provs []provider
+	for _, s := range strings.Split(list, ",") {
+		n, limit, err := parseProvider(s)
+		if err != nil {
+			return nil, err
+		}
+		p, err := providerNamed(n, fo)
+		if err != nil {
+			return nil, err
+		}
+		provs = append(provs, p)
+		setProviderLimit(n, limit)
+	}
+	return provs, nil
+}
+
+func providerNamed(name string, fo fetchOpts) (provider, error) {
+	switch name {
+	case "forecastio":
+		return forecastIO{fo}, nil
+	case "openmeteo":
+		return openMeteo{fo}, nil
+	}
+	return nil, fmt.Errorf("unknown provider %q", name)
+}
+
+// fetchAll gets the forecast for l from every provider. With more than
+// one provider the metrics are averaged and notes describe where they
+// disagree.
+func fetchAll(provs []provider, name string, l loc) (*forecast, []string, error) {
+	var fs []*forecast
+	for _, p := range provs {
+		release := acquire(p.name())
+		f, err := p.fetch(name, l)
+		release()
+		if err != nil {
+			// the error usually has the url in it, so keep the key out of logs
+			return nil, nil, fmt.Errorf("%s: %s: %w", name, p.name(), redactedError{err})
+		}
+		if len(f.Daily) == 0 {
+			return nil, nil, fmt.Errorf("%s: %s: %w, no daily data", name, p.name(), ErrNoData)
+		}
+		fs = append(fs, f)
+	}
+	if len(fs) == 1 {
+		return fs[0], nil, nil
+	}
+	notes := disagreements(provs, fs)
+	return average(fs), notes, nil
+}
+
+// how far apart (0-1) two providers can be on a fraction before it's
+// worth pointing out
+const disagreeThreshold = .2
+
+// disagreements compares today's forecast from each provider to the first
+func disagreements(provs []provider, fs []*forecast) []string {
+	var notes []string
+	a := fs[0].Daily[0]
+	for i := 1; i < len(fs); i++ {
+		b := fs[i].Daily[0]
+		check := func(what string, x, y float64) {
+			if math.Abs(x-y) > disagreeThreshold {
+				notes = append(notes, fmt.Sprintf("%s and %s disagree on %s (%s vs %s)",
+					provs[0].name(), provs[i].name(), what, formatPct(x), formatPct(y)))
+			}
+		}
+		check("precipitation", a.PrecipProbability, b.PrecipProbability)
+		check("cloud cover", a.CloudCover, b.CloudCover)
+	}
+	return notes
+}
+
+// average returns the day by day mean of fs, in the units of the first.
+// The text fields, hourly and minutely data come from the first forecast, the
+// fetch time from the oldest.
+func average(fs []*forecast) *forecast {
+	units := fs[0].Units
+	n := len(fs[0].Daily)
+	fetched := fs[0].Fetched
+	for _, f := range fs {
+		f.Convert(units)
+		if len(f.Daily) < n {
+			n = len(f.Daily)
+		}
+		if f.Fetched.Before(fetched) {
+			fetched = f.Fetched
+		}
+	}
+	avg := &forecast{Units: units, Timezone: fs[0].Timezone, Fetched: fetched, Daily: make([]day, n), Hourly: fs[0].Hourly,
+		Minutely: fs[0].Minutely, MinutelySummary: fs[0].MinutelySummary}
+	copy(avg.Daily, fs[0].Daily[:n])
+	for _, f := range fs {
+		avg.Alerts = append(avg.Alerts, f.Alerts...)
+	}
+	for i := range avg.Daily {
+		d := &avg.Daily[i]
+		var tmax, tmin, hum, cc, pp, pr, ws, dp float64
+		for _, f := range fs {
+			o := f.Daily[i]
+			tmax += o.TemperatureMax
+			tmin += o.TemperatureMin
+			hum += o.Humidity
+			cc += o.CloudCover
+			pp += o.PrecipProbability
+			pr += o.Pressure
+			ws += o.WindSpeed
+			dp += o.DewPoint
+			// a zero from one provider would drag the mean down
+			d.Missing |= o.Missing
+		}
+		c := float64(len(fs))
+		d.TemperatureMax, d.TemperatureMin = tmax/c, tmin/c
+		d.Humidity, d.CloudCover, d.PrecipProbability, d.Pressure = hum/c, cc/c, pp/c, pr/c
+		d.WindSpeed, d.DewPoint = ws/c, dp/c
+	}
+	return avg
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// rawRecord is a provider's raw response for a location, kept by -raw-dir
+// with what it scored so -rescore can score it again later
+type rawRecord struct {
+	Location       string          `json:"location"`
+	Provider       string          `json:"provider"`
+	Units          string          `json:"units"`
+	Lat            float64         `json:"lat"`
+	Lng            float64         `json:"lng"`
+	Score          float64         `json:"score"`
+	ScoringVersion string          `json:"scoring_version"`
+	Fetched        time.Time       `json:"fetched"`
+	Raw            json.RawMessage `json:"raw"`
+}
+
+// rawDay is the directory under -raw-dir a day's forecasts go in
+func rawDay(dir string, t time.Time) string {
+	return filepath.Join(dir, t.Format("2006-01-02"))
+}
+
+// saveRaw copies each scored location's responses out of the cache into
+// dir/<date>/<location>-<provider>.json, replacing the day's earlier copy
+func saveRaw(dir string, provs []provider, fo fetchOpts, res []locScore, now time.Time) error {
+	day := rawDay(dir, now)
+	if err := os.MkdirAll(day, 0750); err != nil {
+		return err
+	}
+	for _, v := range res {
+		l := locations[v.Location]
+		for _, p := range provs {
+			buf, _, ok := cache.load(cacheKey(p.name(), fo.round(l), fo.units, now))
+			if !ok {
+				vlog("%s: %s isn't in the cache, not saving it raw", v.Location, p.name())
+				continue
+			}
+			rec := rawRecord{Location: v.Location, Provider: p.name(), Units: fo.units, Lat: l.lat, Lng: l.lng,
+				Score: v.Score, ScoringVersion: v.ScoringVersion, Fetched: v.Fetched, Raw: buf}
+			out, err := json.Marshal(rec)
+			if err != nil {
+				return err
+			}
+			fn := filepath.Join(day, fixtureName(v.Location)+"-"+p.name()+".json")
+			if err := writeFileAtomic(fn, out, 0640); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rescore scores the raw forecasts saved for date (2006-01-02) again with
+// sc and the adjustments, and prints them against what they scored then
+func rescore(w io.Writer, dir, date string, fo fetchOpts, sc scoreConfig, adj adjustFlag) error {
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return fmt.Errorf("-rescore %q should be a date, eg 2016-10-14", date)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, date, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no raw forecasts saved for %s in %s", date, dir)
+	}
+	// a location's providers are averaged like fetchAll does
+	byLoc := make(map[string][]*forecast)
+	var recs []rawRecord
+	for _, fn := range files {
+		buf, err := ioutil.ReadFile(fn)
+		if err != nil {
+			return err
+		}
+		var rec rawRecord
+		if err := json.Unmarshal(buf, &rec); err != nil {
+			return fmt.Errorf("%s: %v", fn, err)
+		}
+		pfo := fo
+		pfo.units = rec.Units
+		p, err := providerNamed(rec.Provider, pfo)
+		if err != nil {
+			return fmt.Errorf("%s: %v", fn, err)
+		}
+		f, err := p.parse(rec.Location, rec.Raw)
+		if err != nil {
+			warn(rec.Location, err, fmt.Sprintf("can't read %s, leaving it out", fn))
+			continue
+		}
+		if len(byLoc[rec.Location]) == 0 {
+			recs = append(recs, rec)
+		}
+		byLoc[rec.Location] = append(byLoc[rec.Location], f)
+	}
+	type row struct {
+		rec rawRecord
+		now float64
+	}
+	var rows []row
+	for _, rec := range recs {
+		fs := byLoc[rec.Location]
+		f := fs[0]
+		if len(fs) > 1 {
+			f = average(fs)
+		}
+		// the location as it's configured now, if it still is
+		l, ok := locations[rec.Location]
+		if !ok {
+			l = loc{lat: rec.Lat, lng: rec.Lng}
+		}
+		if m := weather.MissingIn(weather.ScoredDays(f, sc), sc.Needs()); m != 0 {
+			warn(rec.Location, nil, fmt.Sprintf("the saved forecast has no %s, leaving it out", m))
+			continue
+		}
+		n := adj.adjust(rec.Location, l, weigh(rec.Location, l, weather.Score(f, l.scoring(), sc)))
+		rows = append(rows, row{rec, n})
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].now > rows[j].now })
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Location\tthen\tnow\tchange\tscoring\n")
+	for _, r := range rows {
+		d := r.now - r.rec.Score
+		sign := ""
+		if d > 0 {
+			sign = "+"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s%s\t%s -> %s\n", r.rec.Location, formatNum(r.rec.Score), formatNum(r.now), sign, formatNum(d),
+			orUnknown(r.rec.ScoringVersion), sc.Version())
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// report is one run's results and what produced them. Every output,
+// slack, the json formats, a snippet, the gist and the history, is
+// written from one, so they can't disagree about the run.
+type report struct {
+	RunID     string    `json:"run_id"`
+	Time      time.Time `json:"time"`
+	Providers []string  `json:"providers"`
+	// Units is what was asked for, each result says what it's really in
+	Units   string     `json:"units"`
+	Mode    string     `json:"mode"`
+	Results []locScore `json:"results"`
+}
+
+// newReport wraps res, sorted for display, as the run at now
+func newReport(res []locScore, provs []provider, units, mode string, now time.Time) *report {
+	r := &report{RunID: fmt.Sprintf("%d", now.UnixNano()), Time: now, Units: units, Mode: mode, Results: res}
+	for _, p := range provs {
+		r.Providers = append(r.Providers, p.name())
+	}
+	return r
+}
+
+// writeReport is -format report, the whole report as one json object.
+// -json-keys renames the keys of the results in it.
+func writeReport(w io.Writer, r *report, pretty bool, keys keyRenames) error {
+	var buf []byte
+	var err error
+	if len(keys) == 0 {
+		buf, err = marshal(r, pretty)
+	} else {
+		var res []byte
+		if res, err = keys.rename(r.Results, false); err != nil {
+			return err
+		}
+		type renamed report
+		buf, err = marshal(struct {
+			renamed
+			Results json.RawMessage `json:"results"`
+		}{renamed(*r), res}, pretty)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(buf))
+	return err
+}
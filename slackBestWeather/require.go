@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// requireFlag is the repeatable -require flag, hard constraints written
+// like -score-expr (eg "tempMax >= 65 && precipProb == 0") that a
+// location has to meet on every scored day to be ranked at all
+type requireFlag []*weather.Expr
+
+func (r *requireFlag) String() string {
+	if r == nil {
+		return ""
+	}
+	var s []string
+	for _, x := range *r {
+		s = append(s, x.String())
+	}
+	return strings.Join(s, "; ")
+}
+
+func (r *requireFlag) Set(s string) error {
+	x, err := weather.ParseExpr(s)
+	if err != nil {
+		return err
+	}
+	*r = append(*r, x)
+	return nil
+}
+
+// check is why f fails a requirement on one of the days scored, and
+// the requirement, or "" when it meets them all
+func (r requireFlag) check(f *forecast, days []day) (string, string) {
+	for _, d := range days {
+		vars := weather.ExprVars(d, f.Units, 0)
+		for _, x := range r {
+			if v, _ := x.Eval(vars); v == 0 {
+				return fmt.Sprintf("%s fails %s", d.Time.Format("Mon Jan 2"), x.String()), x.String()
+			}
+		}
+	}
+	return "", ""
+}
+
+// nobodyQualified is the message for when -require leaves no locations,
+// with the requirements that ruled out the most, eg "No locations met
+// the criteria today, all 6 were filtered out: 4 failed tempMax >= 65,
+// 2 failed precipProb == 0."
+func nobodyQualified(failedBy map[string]int, excluded int) string {
+	rules := make([]string, 0, len(failedBy))
+	for k := range failedBy {
+		rules = append(rules, k)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if failedBy[rules[i]] != failedBy[rules[j]] {
+			return failedBy[rules[i]] > failedBy[rules[j]]
+		}
+		return rules[i] < rules[j]
+	})
+	var why []string
+	named := 0
+	for i, k := range rules {
+		if i == maxReasons {
+			why = append(why, fmt.Sprintf("%d failed others", excluded-named))
+			break
+		}
+		why = append(why, fmt.Sprintf("%d failed %s", failedBy[k], k))
+		named += failedBy[k]
+	}
+	return fmt.Sprintf("No locations met the criteria today, all %d were filtered out: %s.", excluded, strings.Join(why, ", "))
+}
+
+// how many of the requirements nobodyQualified names
+const maxReasons = 3
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = time.Minute
+)
+
+// postWithRetry posts body to u, retrying rate limited (429) and server
+// error (5xx) responses up to retries times. A 429 waits for as long as
+// the Retry-After header asks, holding off every other post too (see
+// throttle), anything else backs off exponentially.
+func postWithRetry(u, contentType string, body []byte, retries int) error {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest("POST", u, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		postThrottle.wait(req.URL.Host)
+		req.Header.Set("Content-Type", contentType)
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+		retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retryable || attempt >= retries {
+			return fmt.Errorf("bad http response %s", resp.Status)
+		}
+		wait := delay
+		if resp.StatusCode == http.StatusTooManyRequests {
+			if ra, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = ra
+			}
+		}
+		slog.Info(fmt.Sprintf("post to %s: %s, retrying in %s", resp.Request.URL.Host, resp.Status, wait))
+		if resp.StatusCode == http.StatusTooManyRequests {
+			// the throttle does the waiting, for everyone
+			postThrottle.backoff(wait)
+		} else {
+			time.Sleep(wait)
+		}
+		delay *= 2
+		if delay > retryMaxDelay {
+			delay = retryMaxDelay
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header, which is either a number of
+// seconds or an http date.
+func retryAfter(h string) (time.Duration, bool) {
+	if h == "" {
+		return 0, false
+	}
+	if n, err := strconv.Atoi(h); err == nil && n >= 0 {
+		return time.Duration(n) * time.Second, true
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// staleWhileRevalidate is -stale-while-revalidate, how old a cached
+// forecast can be and still be used straight away while a fresh one is
+// fetched in the background for next time. Zero is off.
+var staleWhileRevalidate time.Duration
+
+// at most this many background refreshes at once, on top of the
+// provider's own -providers limit
+const maxRevalidations = 4
+
+var revalidations = struct {
+	mu      sync.Mutex
+	pending map[string]bool
+	wg      sync.WaitGroup
+	sem     chan struct{}
+}{pending: make(map[string]bool), sem: make(chan struct{}, maxRevalidations)}
+
+// revalidate refreshes key's cache entry in the background, once per key
+// per run
+func revalidate(u, key string) {
+	r := &revalidations
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending[key] {
+		return
+	}
+	r.pending[key] = true
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.sem <- struct{}{}
+		defer func() { <-r.sem }()
+		if stopping() {
+			return
+		}
+		// the same order as fetchAll, the provider then the key
+		prov, _, _ := strings.Cut(key, "|")
+		defer acquire(prov)()
+		defer lockCache(key)()
+		buf, t, cached := cache.load(key)
+		if _, _, err := fetchAndCache(u, key, prov, cached, buf, t); err != nil {
+			vlog("refreshing %s in the background: %v", key, err)
+			return
+		}
+		vlog("refreshed %s in the background", key)
+	}()
+}
+
+// waitRevalidations gives the background refreshes up to d to finish
+// before the program exits and drops them
+func waitRevalidations(d time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		revalidations.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(d):
+		vlog("gave up waiting on background refreshes after %s, they'll be fetched next time", d)
+	}
+}
+
+// how long the end of a run waits on background refreshes
+const revalidateWait = 10 * time.Second
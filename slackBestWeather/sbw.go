@@ -1,218 +1,1538 @@
 package main
 
 import (
-	"bytes"
 	"crypto/sha1"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"os"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
 )
 
 type loc struct {
 	lat, lng float64
+	region   string
+	// normals are the monthly normal highs in fahrenheit, January first
+	normals []float64
+	// adjust is a personal bias added to the score
+	adjust float64
+	// weight multiplies the score before adjust is added, 0 means 1
+	weight float64
+	// bearing is the wind direction -mode sail likes, nil for any
+	bearing *float64
+	// beach locations get a water temperature in -mode beach
+	beach bool
+	// here is the -include-here location
+	here bool
+	// aliasOf is the location an alias_of names
+	aliasOf string
+	// aliases are the names -near-duplicates merged into this one
+	aliases []string
 }
 
 var (
+	// offline is -offline, nothing goes over the network
+	offline bool
+
 	locations = map[string]loc{
 		"Islip":      loc{lat: 40.726911, lng: -73.218542},
 		"Bryn Mawr":  loc{lat: 40.0274743, lng: -75.3118813},
 		"Ann Arbor":  loc{lat: 42.288873, lng: -83.74613},
 		"Dublin":     loc{lat: 53.3403505, lng: -6.3534707}, // ballyer
 		"Greenville": loc{lat: 34.844068, lng: -82.404295},
-		"Anna Maria": loc{lat: 27.499887, lng: -82.715927},
+		"Anna Maria": loc{lat: 27.499887, lng: -82.715927, beach: true},
 	}
 )
 
-// Struct to unmarshal json from forcast.io
-// Only the stuff I'm interested in atm
-type fioResp struct {
-	Daily struct {
-		Data []struct {
-			Humidity          float64
-			CloudCover        float64
-			PrecipProbability float64
-			Pressure          float64
-			Summary           string
-			TemperatureMax    float64
-			TemperatureMin    float64
-			Time              float64
-			Icon              string
-		}
+type locScore struct {
+	Location          string  `json:"location"`
+	Score             float64 `json:"score"`
+	Comfort           float64 `json:"comfort"`
+	Label             string  `json:"label,omitempty"`
+	Summary           string  `json:"summary"`
+	Icon              string  `json:"icon"`
+	TemperatureMax    float64 `json:"temperatureMax"`
+	TemperatureMin    float64 `json:"temperatureMin"`
+	Humidity          float64 `json:"humidity"`
+	CloudCover        float64 `json:"cloudCover"`
+	PrecipProbability float64 `json:"precipProbability"`
+	Sunshine          float64 `json:"sunshine"`
+	Units             string  `json:"units"`
+	Region            string  `json:"region,omitempty"`
+	// Fetched is when the forecast was fetched from the provider
+	Fetched time.Time `json:"fetched"`
+	// Notes are things the reader should know about this location's
+	// forecast, like the providers disagreeing.
+	Notes []string `json:"notes,omitempty"`
+	// Trend is the score for each of the next -trend days
+	Trend []float64 `json:"trend,omitempty"`
+	// Reference is a -compare location, BeatsReference is set on the
+	// others when they rank ahead of every reference
+	Reference      bool `json:"reference,omitempty"`
+	BeatsReference bool `json:"beatsReference,omitempty"`
+	// PeakDay is the day being scored in -mode peak, or the first day of
+	// the -weekend
+	PeakDay *time.Time `json:"peakDay,omitempty"`
+	// Condition is Icon mapped to the same few conditions whatever the
+	// provider
+	Condition condition `json:"condition"`
+	// LastYear is the score on this day last year, for -compare-last-year
+	LastYear *float64 `json:"lastYear,omitempty"`
+	// Factors is the breakdown of the scored day, for -factors
+	Factors *factorShares `json:"factors,omitempty"`
+	// WindBearing is where the wind blows from, see compass
+	WindSpeed   float64 `json:"windSpeed"`
+	WindBearing float64 `json:"windBearing"`
+	// Here is the runner's own location, from -include-here
+	Here bool `json:"here,omitempty"`
+	// Alerts are the severe weather alerts in effect there
+	Alerts []weather.Alert `json:"alerts,omitempty"`
+	// Distance is how far it is from -home, in miles or km to go with
+	// Units
+	Distance *float64 `json:"distance,omitempty"`
+	// RawScore is this run's score before -smooth blended it into Score
+	RawScore *float64 `json:"rawScore,omitempty"`
+	// Surplus is how far Score is over -good-enough, 0 when it's under
+	Surplus *float64 `json:"surplus,omitempty"`
+	// Gusty is set in -mode event when its gusts are over -gust-limit
+	Gusty bool `json:"gusty,omitempty"`
+	// Aliases are the other names -near-duplicates merged into it
+	Aliases []string `json:"aliases,omitempty"`
+	// Streak is how many days in a row it's scored over -streak-above,
+	// today included
+	Streak int `json:"streak,omitempty"`
+	// Timezone is the location's time zone, the provider's name for it
+	// or an offset worked out from the longitude (see zoneFor)
+	Timezone string `json:"timezone,omitempty"`
+	zone     *time.Location
+	// ScoringVersion is how the score was worked out, see
+	// weather.Config.Version. Scores with different versions aren't
+	// comparable.
+	ScoringVersion string `json:"scoringVersion,omitempty"`
+	// Extra are the scored day's less common fields
+	Extra dayExtra `json:"extra"`
+	// Color is the #rrggbb the slack output shows it in, by whichever
+	// -color-* settings and -format-rule are in effect
+	Color string `json:"color,omitempty"`
+	// style is what the -format-rule flags do to its row
+	style rowStyle
+	// days are the scored days one at a time, for -format or -output ics
+	days []scoredDay
+}
+
+// summary is the forecast summary, with the day if it isn't today
+func (l locScore) summary() string {
+	if l.PeakDay == nil {
+		return l.Summary
 	}
+	return l.PeakDay.Format("Mon Jan 2") + ": " + l.Summary
 }
 
-type locScore struct {
-	Location string
-	Score    int
-	Summary  string
-	Icon     string
+// displayName is the location with its comfort label, if any, and
+// the -format-rule prefix and suffix
+func (l locScore) displayName() string {
+	s := l.Location
+	if l.Label != "" {
+		s = fmt.Sprintf("%s (%s)", l.Location, l.Label)
+	}
+	if l.style.prefix != "" {
+		s = l.style.prefix + " " + s
+	}
+	if l.style.suffix != "" {
+		s += " " + l.style.suffix
+	}
+	return s
 }
 
 func main() {
-	slackWebhook := flag.String("webhook", "", "Webhook URL for a slack channel")
-	useCache := flag.Bool("c", false, "Cache the results from the weather service. (For testing)")
+	var sc scoreConfig
+	so := slackOpts{watches: make(watchFlag)}
+	adjustments := make(adjustFlag)
+	flag.StringVar(&so.webhook, "webhook", "", "Webhook URL for a slack channel")
+	socketPath := flag.String("socket", "", "Write the report to this unix socket, eg for a local relay, instead of posting or printing it")
+	webhookFile := flag.String("webhook-file", "", "Read the -webhook URL from this file, keeping it out of the process list")
+	webhookFD := flag.Int("webhook-fd", -1, "Read the -webhook URL from this open file descriptor, eg 3 with 3<secret")
+	var fo fetchOpts
+	flag.BoolVar(&fo.useCache, "c", false, "Cache the results from the weather service. (For testing)")
+	flag.StringVar(&fo.units, "units", "us", "Units to request from the weather service (us, si, ca, uk2)")
+	profileModes := flag.String("profile-compare", "", "Score every location under each of these comma separated -mode values, eg daily,beach,photo, and print (or with -webhook post) a table of the scores and ranks side by side instead of the report")
+	compareProvs := flag.String("compare-providers", "", "Print what each of the -providers forecasts today for this `location`, side by side, and exit. For working out why the providers disagree")
+	flag.DurationVar(&postThrottle.every, "post-interval", 0, "Least time between posts to slack, across every output and message, to stay under its rate limits (0 is as fast as they go)")
+	flag.IntVar(&dailyQuota, "daily-quota", dailyQuota, "API calls a day the provider allows, for working out what's left from forecast.io's call count")
+	quotaFooter := flag.Bool("quota-footer", false, "Add each provider's remaining API quota, from its response headers, to the slack footer")
+	apiKeys := flag.String("api-keys", "", "Comma separated forecast.io API keys to use in turn, each retired for the run when it's rate limited. Overrides -forecastio-key")
+	flag.StringVar(&fo.fioKey, "forecastio-key", envOr("FORECASTIO_KEY", defaultFIOKey), "forecast.io API key, defaults to $FORECASTIO_KEY")
+	flag.StringVar(&fo.fioBase, "base-url", defaultFIOBase, "forecast.io API base url, eg a local mock server")
+	flag.IntVar(&fo.precision, "coord-precision", 4, "Decimal places of the coordinates to send and cache on, 0-6 (4 is about 11m)")
+	insecure := flag.Bool("insecure-skip-verify", false, "DANGEROUS: don't verify TLS certificates for provider and webhook requests. Only for testing against local mocks")
+	flag.Var(providerHeaders, "header", "Extra `Name: value` header to send to weather providers (repeatable)")
+	proxy := flag.String("proxy", "", "Proxy url for all requests, overriding $HTTP_PROXY / $HTTPS_PROXY")
+	providerList := flag.String("providers", "forecastio", "Comma separated weather providers (forecastio, openmeteo). With more than one the forecasts are averaged. name:n lets a provider have n requests at once, eg openmeteo:8 (the default is 1)")
+	var outputs outputFlag
+	flag.Var(&outputs, "output", "Send the report to `kind[:path]`, repeatable to fan out to several: slack, json, jsonl, geojson, report, png or ics (a file, or - for stdout) or csv and history (appended to the file). Takes over from -format")
+	requireOutputs := flag.String("require-outputs", "", "Comma separated -output kinds (or kind:path) that must work for the run to succeed, the rest only warn (default all of them)")
+	format := flag.String("format", "slack", "Output format: slack, json, jsonl, geojson, report (the json results with the run's time, providers, units and mode), png (the leaderboard drawn as an image card) or ics (a calendar of the good days, see -ics-above)")
+	cardChannel := flag.String("post-card", "", "Upload the leaderboard as a png card to this slack `channel` (token in $SLACK_TOKEN) instead of using the webhook")
+	flag.IntVar(&so.card.width, "card-width", 640, "Width in pixels of the png card")
+	flag.IntVar(&so.card.rowHeight, "card-row-height", 40, "Height in pixels of each location's row on the png card")
+	flag.IntVar(&so.card.scale, "card-font-scale", 2, "Font size on the png card, in pixels per dot of its 5x7 font")
+	gc := &geocoder{file: "cache/geocode.json"}
+	flag.BoolVar(&gc.refresh, "refresh-geocode", false, "Ignore cached geocoding results and look places up again")
+	nearDupes := flag.Float64("near-duplicates", 0, "Look for differently named locations within this many miles of each other, which would only tie (0 is off)")
+	nearDupesAction := flag.String("near-duplicates-action", "warn", "What to do about -near-duplicates: warn, or merge them into the first by name, keeping the others as its aliases")
+	locationsFile := flag.String("locations", "", "JSON (or .csv, or .kml like a Google My Maps export) file of locations to add to (or override) the built in ones")
+	flag.StringVar(&sc.Mode, "mode", "daily", "What to score: daily (today's comfort), now (staying dry over the next hour), ski (fresh snow and cold), photo (dramatic skies around sunset), sail (a good breeze from each location's bearing), beach (warm water and air, sun, little wind), event (comfort, marked down hard for gusts over -gust-limit) or peak (each location's best upcoming day)")
+	icsAbove := flag.Float64("ics-above", 70, "With -format ics or -output ics, the comfort index (0-100, see -bands) a day needs to go in the calendar. Score the days to look at with -days, eg -days 7, or -mode peak for the whole forecast")
+	locationsURL := flag.String("locations-url", "", "URL of a locations list, same formats as -locations: JSON, or csv or kml by the path's extension or the content type")
+	flag.BoolVar(&sc.Sunshine, "sunshine", false, "Score on the combined chance of sunshine instead of cloud cover and precipitation separately")
+	flag.Float64Var(&sc.GustLimit, "gust-limit", 30, "In -mode event, gusts over this many mph make a location unsafe: its score is cut to a quarter and it's marked gusty")
+	flag.BoolVar(&sc.FeelsLike, "feels-like", false, "Score on the heat index / wind chill rather than the air temperature")
+	flag.StringVar(&sc.ComfortModel, "comfort-model", "custom", "Score the high and low on custom (the distance from a perfect 80/60F), thi (temperature-humidity index), humidex or wbgt (wet bulb globe temperature, estimated from temperature and humidity)")
+	flag.BoolVar(&sc.DewPoint, "use-dewpoint", false, "Score humidity comfort on the dew point instead of relative humidity")
+	flag.Var(&sc.Curve, "factor-curve", "How each comfort factor falls off from perfect before it's weighed, as `factor=curve,...` with linear (the default), quadratic or sigmoid, eg high=sigmoid")
+	flag.Var(&sc.Floor, "factor-floor", "Least each comfort factor can score out of 100, as `factor=floor,...` with high, low, clouds, precip and humidity, eg precip=40")
+	flag.Float64Var(&sc.Season.Amplitude, "seasonal-perfect", 0, "Move the perfect temps up to this many degrees F warmer in summer and cooler in winter, on a cosine curve (0 is off)")
+	flag.IntVar(&sc.Season.Peak, "seasonal-peak", weather.DefaultSeasonPeak, "Day of the year the -seasonal-perfect curve is warmest, in the northern hemisphere (the south is half a year out)")
+	flag.Float64Var(&sc.Surprise, "surprise", 0, "Bonus weight for highs that beat the seasonal normal, eg 0.25 (0 is off)")
+	scoreExprSrc := flag.String("score-expr", "", "Custom scoring formula over tempMax, tempMin, humidity, cloudCover, precipProb, pressure, windSpeed, dewPoint, sunshine and builtin, eg \"builtin - 100*precipProb\"")
+	flag.IntVar(&sc.Days, "days", 1, "Number of days, starting today, to average the score over")
+	flag.Var(&sc.Missing, "missing-sky", "What to do when a provider has no cloud cover or precip probability: exclude the location, score them as -missing-sky-value (neutral), or skip those factors and scale up the rest (skip, comfort score only)")
+	flag.Float64Var(&sc.Missing.Value, "missing-sky-value", weather.DefaultSkyValue, "Cloud cover or precip probability, 0-1, to score a missing one as with -missing-sky neutral")
+	flag.IntVar(&sc.DayOffset, "day-offset", 0, "Score the day this many days from today, eg 1 for tomorrow, as if it were today (clamped to the days the forecast has)")
+	flag.BoolVar(&sc.Weekend, "weekend", false, "Score the coming Saturday and Sunday, by the date at each location, instead of -days from today")
+	onWeekend := flag.String("on-weekend", "this", "What -weekend means when run on a weekend: this (what's left of it) or next")
+	flag.Float64Var(&sc.Decay, "day-decay", 0, "How much less each of the -days counts than the one before, 0 to 1, eg 0.2 makes tomorrow count 80% as much as today (0 counts them all the same)")
+	trendDays := flag.Int("trend", 0, "Show a sparkline of each location's score over this many days, starting today (0 is off)")
+	fallbackHourly := flag.Bool("fallback-hourly", false, "Build missing days from hourly data when the daily forecast is too short for -days")
+	scoreFormat := flag.String("score-format", "{raw}", "How to show scores: a `template` with {raw} (the score), {pct} (the 0-100 comfort index) and {grade} (that as A-F), eg \"{pct}%\" or \"{grade} ({raw})\"")
+	var labels bands
+	labels.Set(defaultBands)
+	flag.Var(&labels, "bands", "Labels for comfort index (0-100) ranges as `min:label,...`")
+	useBuckets := flag.Bool("buckets", false, "Group the report into -bucket-bands, eg nice, okay and avoid, rather than ranking it with scores")
+	var buckets bands
+	buckets.Set(defaultBuckets)
+	flag.Var(&buckets, "bucket-bands", "Comfort index (0-100) bands for -buckets as `min:label,...`, best first")
+	locale := flag.String("locale", "en-US", "How to write numbers and percents, eg de-DE for 21,5 and 58 %")
+	flag.IntVar(&precision, "precision", 0, "Number of decimal places to show for scores and temperatures")
+	flag.StringVar(&so.contentType, "webhook-content-type", "", "Content-Type to post the -webhook message with, for receivers that are picky about it (default application/json, or a form with -webhook-payload-form)")
+	flag.BoolVar(&so.payloadForm, "webhook-payload-form", false, "Post the -webhook message form encoded as payload=<json> instead of as a raw json body")
+	flag.DurationVar(&so.stagger, "stagger", 0, "Post each location as its own slack message this far apart, eg 5s, for a drip through the morning (1s at the least, slack's rate limit). Not with -compact or -slack-blocks")
+	flag.IntVar(&so.retries, "slack-retries", 3, "Number of times to retry posting to slack on 429 or 5xx responses")
+	flag.Var(so.watches, "watch", "Alert -mention in slack when a location scores below a threshold, given as `Location=score` (repeatable)")
+	flag.Var(adjustments, "adjust", "Add `Location=delta` to a location's score before ranking, eg to favour home (repeatable)")
+	flag.StringVar(&so.mention, "mention", "<!channel>", "Who to mention for -watch alerts, eg <!channel>, <!here> or <@U123ABC>")
+	flag.Float64Var(&so.colorMin, "color-min", 0, "Fixed bottom of the color scale, in -sort-by units, instead of today's worst")
+	flag.Float64Var(&so.colorMax, "color-max", 0, "Fixed top of the color scale, in -sort-by units, instead of today's best")
+	flag.Float64Var(&so.colorPct, "color-percentile", 0, "Scale the colors from this percentile to its mirror, eg 10 for the 10th to 90th, so an outlier doesn't squash the rest into one color (0 uses the best and worst)")
+	flag.Var(&worstColor, "color-worst", "Color for the worst location, as `hex` like #ff0000")
+	flag.Var(&so.steps, "color-steps", "Color by `good,bad` thresholds in -sort-by units instead of a gradient: best color at good or better, -color-warn between, worst color at bad or worse")
+	flag.Var(&warnColor, "color-warn", "Middle color for -color-steps, as `hex` like #ffcc00")
+	flag.Var(&bestColor, "color-best", "Color for the best location, as `hex` like #00ff00")
+	flag.BoolVar(&so.explain, "explain-colors", false, "Add a legend for the slack colors and number the locations by rank")
+	flag.StringVar(&so.threadTS, "thread-ts", "", "Post the report as a reply to the slack message with this `ts`, in -channel, through the web api (token in $SLACK_TOKEN)")
+	flag.StringVar(&so.channel, "channel", "", "Slack channel id of the -thread-ts parent message, eg C0123ABCD")
+	snippetChannel := flag.String("post-as-snippet", "", "Upload the ranking as a text snippet to this slack `channel` (token in $SLACK_TOKEN) instead of using the webhook")
+	flag.BoolVar(&so.numbers, "verbose-fields", false, "Add the high, low, humidity, clouds and precip numbers to each location in slack")
+	flag.BoolVar(&so.blocks, "slack-blocks", false, "Post the results as slack Block Kit blocks instead of the older attachments")
+	flag.BoolVar(&so.refreshButton, "refresh-button", false, "With -slack-blocks, add a Refresh button that reruns the report in place, through a -interactive-addr server")
+	interactiveAddr := flag.String("interactive-addr", "", "Listen on this `address`, eg :8080, for the -refresh-button and update the message, instead of reporting. Point the slack app's interactivity request url at /slack/interactive")
+	signingSecretFile := flag.String("signing-secret-file", "", "Read the slack app's signing secret, which -interactive-addr checks every request with, from this file rather than $SLACK_SIGNING_SECRET")
+	flag.BoolVar(&so.compact, "compact", false, "Post the ranking as a single slack attachment instead of one per location")
+	showVersion := flag.Bool("version", false, "Print the version and exit")
+	footerVersion := flag.Bool("footer-version", false, "Include the version in the slack message footer")
+	var refs refsFlag
+	flag.Var(&refs, "compare", "Reference `locations`, comma separated, to mark the others against, eg \"is anywhere nicer than Hawaii?\"")
+	closeMargin := flag.Float64("close-call", 2, "Call the top two a tie when their scores are within this many points")
+	share := flag.Bool("share", false, "Upload the results to a secret GitHub gist (token in $GITHUB_TOKEN) and link it in the slack footer")
+	compareLastYear := flag.Bool("compare-last-year", false, "Also score each location on this day last year and show the difference (needs the forecastio provider)")
+	showFactors := flag.Bool("factors", false, "Show how close each part of the comfort score came to perfect, eg Temp 85% · Sun 70% · Dry 90% · Humidity 60%")
+	waterSource := flag.String("water-source", defaultWaterSource, "Sea temperature url for -mode beach, open-meteo marine style, with {lat}, {lng} and {unit} filled in")
+	var requirements requireFlag
+	var rules formatRules
+	flag.Var(&rules, "format-rule", "Style locations where `condition => effects` holds on the scored day, the condition written like -score-expr with builtin the score and the effects any of prefix=TEXT, suffix=TEXT, color=#hex and dim, eg \"precipProb > 0.5 => prefix=:umbrella:,dim\" (repeatable)")
+	flag.Var(&requirements, "require", "Drop locations that don't meet this `expression`, written like -score-expr, on every scored day, eg \"tempMax >= 65 && precipProb < 0.1\" (repeatable)")
+	var points pointFlag
+	flag.Var(&points, "point", "Score just this `lat,lng[,label]` instead of the configured locations, named Point 1, Point 2... without a label (repeatable)")
+	center := flag.String("center", "", "Score a grid of points around this `lat,lng` instead of the configured locations, to find the nicest spot nearby")
+	radius := flag.Float64("radius", 5, "Miles around the -center to sample")
+	gridSize := flag.Int("grid", 5, "Points across the -center grid, N by N less the corners outside the -radius")
+	gridTop := flag.Int("grid-top", 5, "Report only this many of the best -center grid points (0 for all)")
+	var home homeFlag
+	flag.BoolVar(&so.localTimes, "local-times", false, "Show each location's forecast day and when it was fetched in that location's own time zone")
+	flag.Var(&home, "home", "Show how far each location is from `lat,lng`")
+	distPenalty := flag.Float64("distance-penalty", 0, "With -home, take this many points off the score for every 100 miles away, for a realistic ranking of where to go (0 is off)")
+	includeHere := flag.Bool("include-here", false, "Add where this machine is, going by its ip address, as a \"Here\" location to compare with")
+	hereURL := flag.String("here-url", defaultHereURL, "IP geolocation service for -include-here")
+	preflightCheck := flag.Bool("preflight", false, "Check the providers and slack can be reached before fetching anything, and stop if not")
+	failFast := flag.Bool("fail-fast", false, "Panic, with a stack trace, on the first location that fails instead of skipping it. For debugging")
+	minLocations := flag.Int("min-locations", 1, "Don't report unless at least this many locations, 1 or more, could be fetched")
+	maxAge := flag.Duration("max-age", 0, "Refuse to report if even the freshest forecast is older than this, eg 6h (0 means no limit)")
+	flag.BoolVar(&deterministic, "deterministic", false, "Sort everything that would otherwise come out in random map order, for reproducible runs and screenshots (implies -locations-sort, costs a little time)")
+	sortLocations := flag.Bool("locations-sort", false, "Fetch locations in alphabetical order so logs are stable between runs")
+	compactCache := flag.Bool("compact-cache", false, "Keep the cache in the one file "+packFile+" rather than a file per forecast")
+	prune := flag.Duration("prune", 0, "Delete cache files fetched longer ago than this, eg 720h, then exit")
+	refetch := flag.Bool("refetch", false, "Fetch live and overwrite the cache even with -c, which it overrides")
+	warm := flag.Bool("warm-cache", false, "Fetch every location into the cache without scoring or posting, for a later -c run")
+	fixtureDir := flag.String("capture-fixtures", "", "Fetch every location live and save the raw responses in this `dir` as islip.json and so on, for tests and mock servers")
+	listLocs := flag.Bool("list-locations", false, "Print the locations as loaded, built in and from -locations and -locations-url, then exit without fetching")
+	check := flag.Bool("check", false, "Validate the configuration without fetching any forecasts, then exit")
+	tiebreakBy := flag.String("tiebreak", "name", "How to order tied locations: name, temp, lowhumidity or preferred:<Location>")
+	flag.StringVar(&so.order, "display-order", "score", "List the report by score, name or config (file order) while ranks and colors still follow the score")
+	flag.BoolVar(&so.pretty, "pretty", true, "Indent json that's printed, the slack message and -format json, geojson and report. What's posted to slack is always compact")
+	jsonKeys := make(keyRenames)
+	flag.Var(jsonKeys, "json-keys", "Rename keys in -format json, jsonl and report as `old=new,...`, eg location=name,score=value")
+	flag.BoolVar(&so.tldr, "tldr", false, "Add the whole ranking on one line, names and weather emoji, under the summary so notifications show it")
+	summaryTmpl := flag.String("summary", defaultSummary, "Template for the message's first line, what notifications show, with {{.Winner}}, {{.Score}}, {{.Label}}, {{.Day}} and {{.Runner}}")
+	sortBy := flag.String("sort-by", "score", "Rank by score, temp, precip, humidity, clouds or surplus (over -good-enough)")
+	flag.Float64Var(&so.goodEnough, "good-enough", 0, "The score that's good enough: report each location's surplus over it (0 under it) and, unless -sort-by says otherwise, rank and color by that (0 is off)")
+	flag.BoolVar(&verbose, "v", false, "Verbose logging")
+	historyFile := flag.String("history-file", "", "Append every run's results to this jsonl file")
+	coverageCheck := flag.Bool("coverage-check", true, "Warn about forecasts with no summary or icon, or all zeros, which usually means the location is over water or outside the provider's coverage, eg swapped lat and lng")
+	smooth := flag.Float64("smooth", 0, "Blend each location's score with its last -history-file run, weighting this run by this much (0-1, eg 0.5), for a steadier ranking when running often. 0 is off")
+	rawDir := flag.String("raw-dir", "", "Keep each location's raw provider responses, with the score they got, under this directory by date, for -rescore")
+	rescoreDate := flag.String("rescore", "", "Score the raw forecasts -raw-dir kept for this `date` (2006-01-02) again with the current settings, print them against the scores they got then, and exit")
+	flag.IntVar(&so.topMovers, "top-movers", 0, "With -history-file, call out this many of the biggest risers and fallers since the last run (0 is off)")
+	flag.Float64Var(&so.streakAbove, "streak-above", 0, "With -history-file, show how many days in a row each location has scored at least this, eg 450 (0 is off)")
+	streakGaps := flag.String("streak-gaps", "break", "What a day with no run in the history does to a -streak-above streak: break it, or ignore the day")
+	auditFile := flag.String("audit-file", "", "Append a jsonl record of every location's scoring inputs, factors and score to this file, for tuning the weights")
+	crossAt := flag.Float64("alert-crossing", 0, "Only post when a location's score rises to this since the last -history-file run, instead of the full report (0 is off)")
+	diff := flag.Bool("diff", false, "Show how the rankings moved between two saved runs, given as arguments (json or jsonl output), and exit. Posts it with -webhook")
+	explainDiff := flag.Bool("explain-diff", false, "Like -diff but say which factors moved each location's score, from the runs' -factors breakdowns")
+	historyDays := flag.Int("history-summary", 0, "Summarize the last N days of -history-file and exit")
+	logFormat := flag.String("log-format", "text", "Log as text or json (one object per line)")
+	flag.DurationVar(&minRefresh, "min-refresh", 0, "Never fetch a forecast live again within this long of the last live fetch, use the cache instead, so a loop or a bad cron can't burn the quota (-refetch overrides)")
+	flag.DurationVar(&staleWhileRevalidate, "stale-while-revalidate", 0, "Use a cached forecast up to this old straight away and fetch a fresh one in the background for next time, older than that waits for a fresh one. -c still never fetches")
+	flag.BoolVar(&offline, "offline", false, "Use only cached forecasts and never touch the network, skipping locations that aren't cached. The message is printed, not posted")
+	flag.StringVar(&healthcheckURL, "healthcheck-url", "", "Ping this url after a successful run, and url/fail after a failed one (eg healthchecks.io)")
+	var at atFlag
+	flag.Var(&at, "at", "Run as a daemon, reporting at these `HH:MM` local times each day, comma separated")
+	atTZ := flag.String("at-tz", "Local", "Time zone for -at, eg America/New_York")
+	serveAddr := flag.String("serve-addr", "", "Serve the latest report as a web page, and as json at /api/report, on this `address`, eg :8080")
+	watchEvery := flag.Duration("watch-leader", 0, "Run as a daemon, reporting this often but only posting when a new location takes the top spot")
+	serveEvery := flag.Duration("serve-every", time.Hour, "How often -serve-addr runs a new report, unless -at sets the times")
+	flag.BoolVar(&quiet, "quiet", false, "Only print errors, not logs, warnings or the slack message when there's no -webhook")
 	flag.Parse()
-	res := make([]locScore, 0)
-	// get weather data from forcast.io
-	var f fioResp
-	for k, v := range locations {
-		d, err := get(v, *useCache)
+	if err := setLogFormat(*logFormat, quiet); err != nil {
+		fatal(err)
+	}
+	if deterministic {
+		*sortLocations = true
+	}
+	if *webhookFile != "" || *webhookFD >= 0 {
+		w, err := readSecret(*webhookFile, *webhookFD)
 		if err != nil {
-			panic(err)
+			fatalf("reading the webhook: %v", err)
+		}
+		so.webhook = w
+	}
+	if err := setLocale(*locale); err != nil {
+		fatal(err)
+	}
+	if *apiKeys != "" {
+		pool, err := newKeyPool(*apiKeys)
+		if err != nil {
+			fatal(err)
+		}
+		for _, k := range pool.keys {
+			addSecret(k)
+		}
+		keyPools["forecastio"] = pool
+		fo.fioKey = pool.keys[0]
+	}
+	addSecret(fo.fioKey)
+	if fo.precision < 0 || fo.precision > 6 {
+		fatalf("-coord-precision should be 0 to 6, got %d", fo.precision)
+	}
+	if *minLocations < 1 {
+		fatalf("-min-locations should be at least 1, got %d", *minLocations)
+	}
+	addSecret(so.webhook)
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+	if *compactCache {
+		p, err := newPackCache()
+		if err != nil {
+			fatal(err)
+		}
+		cache = p
+	}
+	if *historyDays > 0 {
+		if *historyFile == "" {
+			fatal("-history-summary needs a -history-file")
+		}
+		if err := summarizeHistory(os.Stdout, *historyFile, *historyDays); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *diff || *explainDiff {
+		if flag.NArg() != 2 {
+			fatal("-diff needs two result files, eg -diff morning.json evening.json")
+		}
+		if offline && (so.webhook != "" || so.threadTS != "") {
+			fatal("-offline can't post the -diff")
+		}
+		if err := diffRuns(so, flag.Arg(0), flag.Arg(1), *explainDiff); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *prune > 0 {
+		n, err := cache.prune(*prune)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Printf("pruned %d cache files\n", n)
+		return
+	}
+	if *interactiveAddr != "" && (len(at) > 0 || *watchEvery > 0 || *serveAddr != "") {
+		fatal("-interactive-addr is a server of its own, run it apart from -at, -watch-leader and -serve-addr")
+	}
+	if len(at) > 0 && *watchEvery > 0 {
+		fatal("-watch-leader has its own schedule, leave out -at")
+	}
+	if len(at) > 0 && *serveAddr == "" {
+		// -serve-addr refreshes at -at times itself, below
+		tz, err := time.LoadLocation(*atTZ)
+		if err != nil {
+			fatal(err)
 		}
-		err = json.Unmarshal(d, &f)
+		handleSignals()
+		runAt(at, tz)
+		return
+	}
+	if so.threadTS != "" && so.channel == "" {
+		fatal("-thread-ts needs the -channel the parent message is in")
+	}
+	if offline && (so.webhook != "" || so.threadTS != "" || *snippetChannel != "" || *cardChannel != "" || *preflightCheck ||
+		*warm || *refetch || *fixtureDir != "" || healthcheckURL != "" || *share) {
+		fatal("-offline can't be used with flags that need the network, leave out -webhook to print the message instead")
+	}
+	if dailyQuota < 1 {
+		fatal("-daily-quota should be at least 1")
+	}
+	if so.card.width < 1 || so.card.rowHeight < 1 || so.card.scale < 1 {
+		fatal("-card-width, -card-row-height and -card-font-scale have to be at least 1")
+	}
+	if *socketPath != "" && so.webhook != "" {
+		fatal("use one of -webhook and -socket")
+	}
+	if so.topMovers > 0 && *historyFile == "" {
+		fatal("-top-movers needs a -history-file to compare with")
+	}
+	if so.streakAbove > 0 && *historyFile == "" {
+		fatal("-streak-above needs a -history-file to count the days in")
+	}
+	if *streakGaps != "break" && *streakGaps != "ignore" {
+		fatalf("-streak-gaps should be break or ignore, got %q", *streakGaps)
+	}
+	if *crossAt > 0 && *historyFile == "" {
+		fatal("-alert-crossing needs a -history-file to compare with")
+	}
+	if *scoreFormat != "{raw}" {
+		f, err := newScoreFormat(*scoreFormat, sc.Comfort)
+		if err != nil {
+			fatal(err)
+		}
+		formatScore = f
+	}
+	if !contains(weather.ComfortModels, sc.ComfortModel) {
+		fatalf("unknown -comfort-model %q, it should be one of %s", sc.ComfortModel, strings.Join(weather.ComfortModels, ", "))
+	}
+	if sc.ComfortModel != "custom" && sc.FeelsLike {
+		fatal("-comfort-model already takes the humidity into account, leave out -feels-like")
+	}
+	if !contains(scoringModes, sc.Mode) {
+		fatalf("unknown -mode %q", sc.Mode)
+	}
+	// with -output the -format is ignored
+	ics := outputs.has("ics") || (len(outputs) == 0 && *format == "ics")
+	if ics {
+		if sc.Mode == "now" {
+			fatal("ics is a calendar of days, it doesn't work with -mode now")
+		}
+		if sc.Days == 1 && sc.Mode != "peak" && !sc.Weekend {
+			warn("", nil, "ics with -days 1 only has the one day in it, eg -days 7 for the week")
+		}
+	}
+	var compareModes []string
+	if *profileModes != "" {
+		ms, err := parseModes(*profileModes)
+		if err != nil {
+			fatalf("-profile-compare: %v", err)
+		}
+		compareModes = ms
+	}
+	if *distPenalty != 0 && !home.set {
+		fatal("-distance-penalty needs a -home to measure from")
+	}
+	if *distPenalty < 0 {
+		fatal("-distance-penalty can't be negative")
+	}
+	if sc.Missing.Value < 0 || sc.Missing.Value > 1 {
+		fatal("-missing-sky-value should be 0 to 1")
+	}
+	if so.colorPct < 0 || so.colorPct >= 50 {
+		fatal("-color-percentile should be at least 0 and under 50")
+	}
+	switch *onWeekend {
+	case "this":
+	case "next":
+		sc.NextWeekend = true
+	default:
+		fatalf("-on-weekend should be this or next, got %q", *onWeekend)
+	}
+	if sc.DayOffset < 0 {
+		fatal("-day-offset can't be in the past")
+	}
+	if sc.DayOffset > 0 && (sc.Weekend || sc.Mode == "now" || sc.Mode == "peak") {
+		fatal("-day-offset picks the day itself, it can't go with -weekend, -mode now or -mode peak")
+	}
+	if sc.Weekend && (sc.Mode == "now" || sc.Mode == "peak") {
+		fatalf("-weekend doesn't work with -mode %s", sc.Mode)
+	}
+	if sc.Decay < 0 || sc.Decay >= 1 {
+		fatal("-day-decay should be at least 0 and less than 1")
+	}
+	if sc.Season.Amplitude < 0 {
+		fatal("-seasonal-perfect can't be negative")
+	}
+	if sc.Season.Peak < 1 || sc.Season.Peak > 366 {
+		fatalf("-seasonal-peak should be a day of the year, 1 to 366, got %d", sc.Season.Peak)
+	}
+	if err := jsonKeys.check(); err != nil {
+		fatal(err)
+	}
+	if *useBuckets {
+		so.buckets = buckets
+	}
+	summary, err := parseSummary(*summaryTmpl)
+	if err != nil {
+		fatalf("-summary: %v", err)
+	}
+	so.summary = summary
+	if *scoreExprSrc != "" {
+		x, err := weather.ParseExpr(*scoreExprSrc)
 		if err != nil {
+			fatalf("-score-expr: %v", err)
+		}
+		sc.Expr = x
+	}
+	if *insecure {
+		warn("", nil, "TLS certificate verification is disabled")
+		skipVerify()
+	}
+	if *proxy != "" {
+		if err := setProxy(*proxy); err != nil {
+			fatal(err)
+		}
+	}
+	switch so.order {
+	case "score", "name", "config":
+	default:
+		fatalf("unknown -display-order %q", so.order)
+	}
+	if so.goodEnough != 0 {
+		sortSet := false
+		flag.Visit(func(f *flag.Flag) { sortSet = sortSet || f.Name == "sort-by" })
+		if !sortSet {
+			*sortBy = "surplus"
+		}
+	}
+	colorRange := false
+	flag.Visit(func(f *flag.Flag) { colorRange = colorRange || f.Name == "color-min" || f.Name == "color-max" })
+	if colorRange && so.colorMin >= so.colorMax {
+		// the one left out is 0, which may well be on the wrong side
+		fatalf("-color-min %g should be below -color-max %g, set both", so.colorMin, so.colorMax)
+	}
+	key, ok := sortKeys[*sortBy]
+	if !ok {
+		fatalf("unknown -sort-by %q", *sortBy)
+	}
+	if *sortBy == "surplus" && so.goodEnough == 0 {
+		fatal("-sort-by surplus needs the -good-enough score it's over")
+	}
+	if *smooth < 0 || *smooth > 1 {
+		fatal("-smooth should be a weight from 0 to 1")
+	}
+	if *smooth > 0 && *historyFile == "" {
+		fatal("-smooth blends with the last run, it needs a -history-file")
+	}
+	so.key = key
+	if so.refreshButton && !so.blocks {
+		fatal("-refresh-button is a Block Kit button, it needs -slack-blocks")
+	}
+	if so.stagger > 0 && so.blocks {
+		fatal("-stagger posts attachments one at a time, it doesn't work with -slack-blocks")
+	}
+	if so.stagger > 0 && so.compact {
+		fatal("-compact puts everyone in one attachment, there's nothing for -stagger to spread out")
+	}
+	if *interactiveAddr != "" {
+		secret := os.Getenv("SLACK_SIGNING_SECRET")
+		if *signingSecretFile != "" {
+			if secret, err = readSecret(*signingSecretFile, -1); err != nil {
+				fatal(err)
+			}
+		}
+		if secret == "" {
+			fatal("-interactive-addr needs the slack app's signing secret, in $SLACK_SIGNING_SECRET or -signing-secret-file")
+		}
+		handleSignals()
+		if err := serveInteractive(*interactiveAddr, secret, so); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *serveAddr != "" {
+		if *serveEvery < time.Minute {
+			fatal("-serve-every should be at least a minute")
+		}
+		tz, err := time.LoadLocation(*atTZ)
+		if err != nil {
+			fatal(err)
+		}
+		handleSignals()
+		if err := serveDashboard(*serveAddr, so, *serveEvery, at, tz); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *watchEvery > 0 {
+		if *watchEvery < time.Minute {
+			fatal("-watch-leader should be at least a minute")
+		}
+		handleSignals()
+		watchLeader(so, *watchEvery)
+		return
+	}
+	tie, err := parseTiebreak(*tiebreakBy)
+	if err != nil {
+		fatal(err)
+	}
+	if *footerVersion {
+		so.footer = append(so.footer, versionString())
+	}
+	if *locationsFile != "" {
+		if err := loadLocations(*locationsFile, gc); err != nil {
+			if *check {
+				fmt.Println("FAIL locations:", err)
+				os.Exit(1)
+			}
+			fatal(err)
+		}
+	}
+	if *locationsURL != "" {
+		if err := loadLocationsURL(*locationsURL, gc); err != nil {
+			if *check {
+				fmt.Println("FAIL locations-url:", err)
+				os.Exit(1)
+			}
+			fatal(err)
+		}
+	}
+	if *center != "" {
+		if len(points) > 0 {
+			fatal("-center and -point don't go together")
+		}
+		lat, lng, _, err := parseLatLng(*center)
+		if err != nil {
+			fatalf("-center %v", err)
+		}
+		if *radius <= 0 {
+			fatal("-radius should be more than 0 miles")
+		}
+		// at 2 there are only the corners, which are all outside the radius
+		if *gridSize < 3 || *gridSize > 15 {
+			fatalf("-grid should be 3 to 15, got %d", *gridSize)
+		}
+		points = gridPoints(lat, lng, *radius, *gridSize)
+		if len(points) == 0 {
+			// it would quietly score the configured locations instead
+			fatalf("-grid %d has no points within the -radius", *gridSize)
+		}
+		vlog("sampling %d points within %g miles of %f,%f", len(points), *radius, lat, lng)
+	}
+	if len(points) > 0 {
+		points.use()
+	}
+	if *includeHere && !offline {
+		if l, err := locateHere(*hereURL); err != nil {
+			// nice to have, never worth failing the run over
+			warn(hereName, redactedError{err}, "can't tell where here is, leaving it out")
+		} else {
+			vlog("%s is %f,%f", hereName, l.lat, l.lng)
+			locations[hereName] = l
+		}
+	}
+	if *nearDupes > 0 {
+		if *nearDupesAction != "merge" && *nearDupesAction != "warn" {
+			fatalf("-near-duplicates-action should be merge or warn, got %q", *nearDupesAction)
+		}
+		nearDuplicates(*nearDupes, *nearDupesAction == "merge")
+	}
+	for _, n := range refs {
+		if _, ok := locations[n]; !ok {
+			fatalf("-compare location %q isn't configured", n)
+		}
+	}
+	if *listLocs {
+		if err := listLocations(os.Stdout); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *check {
+		if !checkConfig(os.Stdout, *providerList, fo, *format, so.webhook) {
+			os.Exit(1)
+		}
+		return
+	}
+	refetching = *refetch
+	if *warm || *refetch || *fixtureDir != "" {
+		// always fetch, the cache still gets the fresh copy
+		fo.useCache = false
+	}
+	provs, err := newProviders(*providerList, fo)
+	if err != nil {
+		fatal(err)
+	}
+	if *compareProvs != "" {
+		if err := compareProviders(os.Stdout, provs, *compareProvs, sc); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *rescoreDate != "" {
+		if *rawDir == "" {
+			fatal("-rescore needs the -raw-dir the forecasts were kept in")
+		}
+		if err := rescore(os.Stdout, *rawDir, *rescoreDate, fo, sc, adjustments); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if len(compareModes) > 0 {
+		t, err := profileCompare(provs, locationNames(*sortLocations), compareModes, sc,
+			profileOpts{fo: fo, adjust: adjustments, fallbackHourly: *fallbackHourly, waterSource: *waterSource})
+		if err == nil {
+			err = sendProfileCompare(so, t)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *preflightCheck {
+		probed := provs
+		if fo.useCache {
+			// the forecasts may all come from the cache
+			probed = nil
+		}
+		target := so.webhook
+		if so.threadTS != "" || *snippetChannel != "" || *cardChannel != "" {
+			target = slackAPI
+		}
+		if *socketPath != "" {
+			target = ""
+		}
+		if err := preflight(probed, target); err != nil {
+			fatal(err)
+		}
+	}
+	var hist historian
+	if *compareLastYear {
+		var ok bool
+		if hist, ok = findHistorian(provs); !ok {
+			fatal("-compare-last-year needs a provider with history, eg forecastio")
+		}
+		if sc.Mode == "now" {
+			fatal("-compare-last-year doesn't work with -mode now")
+		}
+	}
+	handleSignals()
+	if *fixtureDir != "" {
+		if err := captureFixtures(*fixtureDir, provs, fo, locationNames(*sortLocations), *failFast); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if *warm {
+		if err := warmCache(provs, locationNames(*sortLocations), *failFast); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	res := make([]locScore, 0)
+	var smoothFrom map[string]float64
+	if *smooth > 0 {
+		prev, version, err := lastRun(*historyFile)
+		switch {
+		case err != nil:
+			warn("", err, "not smoothing, can't read the history")
+		case version != "" && version != sc.Version():
+			warn("", nil, fmt.Sprintf("not smoothing, the last run was scored differently (version %s, this is %s)", version, sc.Version()))
+		default:
+			smoothFrom = prev
+		}
+	}
+	// get weather data from the providers
+	if len(disabled) > 0 {
+		vlog("%d locations disabled: %s", len(disabled), strings.Join(sortedKeys(disabled), ", "))
+	}
+	var failed []string
+	var audit []auditRecord
+	names := locationNames(*sortLocations)
+	all := fetchEach(provs, names)
+	var retry []string
+	for _, k := range names {
+		if transient(all[k].err) {
+			retry = append(retry, k)
+		}
+	}
+	if len(retry) > 0 {
+		// the network or a provider blipped, give them one more go once
+		// everyone else is done. A refused request or one without a
+		// forecast in it would only fail the same way.
+		vlog("trying %d again: %s", len(retry), strings.Join(retry, ", "))
+		for k, r := range fetchEach(provs, retry) {
+			all[k] = r
+		}
+	}
+	for _, k := range names {
+		if stopping() {
+			fatal("interrupted, not reporting a partial competition")
+		}
+		v := locations[k]
+		f, notes, err := all[k].f, all[k].notes, all[k].err
+		if err != nil && *failFast {
 			panic(err)
 		}
-		n := score(&f)
-		res = append(res, locScore{Score: n, Location: k, Summary: f.Daily.Data[0].Summary, Icon: f.Daily.Data[0].Icon})
-	}
-	sort.Sort(byScore(res))
-	sendToSlack(*slackWebhook, res)
-}
-
-func sendToSlack(webhook string, res []locScore) error {
-	type Field struct {
-		Title string `json:"title,omitempty"`
-		Value string `json:"value"`
-		Short bool   `json:"short,omitempty"`
-	}
-	type Attachment struct {
-		Fallback    string  `json:"fallback,omitempty"`
-		Color       string  `json:"color,omitempty"`
-		PreText     string  `json:"pretext,omitempty"`
-		Author_Name string  `json:"author_name,omitempty"`
-		Author_Link string  `json:"author_link,omitempty"`
-		Author_icon string  `json:"author_icon,omitempty"`
-		Title       string  `json:"title,omitempty"`
-		Title_Link  string  `json:"title_link,omitempty"`
-		Text        string  `json:"text"`
-		Fields      []Field `json:"fields,omitempty"`
-		Image_URL   string  `json:"image_url,omitempty"`
-		Thumb_URL   string  `json:"thumb_url,omitempty"`
-	}
-
-	type slackMsg struct {
-		Text        string       `json:"text"`
-		Username    string       `json:"username,omitempty"`
-		Icon_Emoji  string       `json:"icon_emoji,omitempty"`
-		Channel     string       `json:"channel,omitempty"`
-		Attachments []Attachment `json:"attachments,omitempty"`
-	}
-	var sm slackMsg
-	sm.Text = "Results of the best weather competition today are:"
-	//sm.Channel = "#general"
-	maxScore := res[0].Score
-	minScore := res[len(res)-1].Score
-	for i, v := range res {
-		f := []Field{
-			{Value: v.Location, Short: true},
-			{Value: fmt.Sprintf("%d", v.Score), Short: true},
-			{Value: v.Summary},
-		}
-		if i == 0 {
-			f[0].Title = "Location"
-			f[1].Title = "Score"
-		}
-		sm.Attachments = append(sm.Attachments, Attachment{
-			Fields:    f,
-			Color:     getValueBetweenTwoFixedColors(float64(v.Score-minScore) / float64((maxScore - minScore))),
-			Thumb_URL: fmt.Sprintf(":%s:", v.Icon),
+		if errors.Is(err, ErrBadKey) {
+			// every other location would fail the same way
+			fatal(redact(err.Error()))
+		}
+		if errors.Is(err, ErrRateLimited) {
+			// the ones after it may still be in the cache
+			warn(k, err, "rate limited, leaving it out")
+			failed = append(failed, k)
+			continue
+		}
+		if errors.Is(err, ErrNoData) || errors.Is(err, ErrRequest) {
+			warn(k, err, "the provider has no forecast for it, leaving it out")
+			failed = append(failed, k)
+			continue
+		}
+		if transient(err) {
+			warn(k, err, "still failing after a retry, leaving it out")
+			failed = append(failed, k)
+			continue
+		}
+		if err != nil {
+			// one bad location shouldn't cancel the competition
+			warn(k, err, "skipping location")
+			failed = append(failed, k)
+			continue
+		}
+		// past the end -day-offset is clamped and says so itself
+		if want := sc.DayOffset + sc.Days; f.EnsureDays(want, *fallbackHourly) < want && sc.DayOffset < len(f.Daily) {
+			warn(k, nil, fmt.Sprintf("only %d of %d days available", len(f.Daily), want))
+		}
+		if sc.Weekend && len(weather.ScoredDays(f, sc)) == 0 {
+			warn(k, nil, "the forecast doesn't reach the weekend, leaving it out")
+			failed = append(failed, k)
+			continue
+		}
+		if m := weather.MissingIn(weather.ScoredDays(f, sc), sc.Needs()); m != 0 {
+			// a missing temperature scored as 0 would put it last, or first
+			// for photo days, for no reason
+			warn(k, nil, fmt.Sprintf("the forecast has no %s, leaving it out", m))
+			failed = append(failed, k)
+			continue
+		}
+		if *coverageCheck {
+			if p := noCoverage(f, weather.ScoredDays(f, sc)); p != "" {
+				warn(k, nil, coverageWarning(v, p))
+			}
+		}
+		if s := sc.Defaulted(weather.ScoredDays(f, sc)); s != "" {
+			vlog("%s: %s", k, s)
+		}
+		if why, rule := requirements.check(f, weather.ScoredDays(f, sc)); why != "" {
+			vlog("%s: excluded, %s", k, why)
+			so.excluded++
+			if so.failedBy == nil {
+				so.failedBy = make(map[string]int)
+			}
+			so.failedBy[rule]++
+			continue
+		}
+		if sc.Mode == "beach" && v.beach {
+			if err := addWaterTemps(f, v, *waterSource, fo); err != nil {
+				warn(k, redactedError{err}, "no water temperature, scoring without it")
+			}
+		}
+		n := adjustments.adjust(k, v, weigh(k, v, weather.Score(f, v.scoring(), sc)))
+		var dist *float64
+		if home.set {
+			mi := home.miles(v)
+			n = distancePenalty(k, mi, *distPenalty, n)
+			d := weather.ConvertDistance(mi, "us", f.Units)
+			dist = &d
+		}
+		if *auditFile != "" {
+			audit = append(audit, auditScore(k, v, f, sc, adjustments, n))
+		}
+		var raw *float64
+		if p, ok := smoothFrom[k]; ok {
+			r := n
+			raw = &r
+			n = smoothed(n, p, *smooth)
+		}
+		var surplus *float64
+		if so.goodEnough != 0 {
+			s := math.Max(0, n-so.goodEnough)
+			surplus = &s
+		}
+		today := f.Daily[0]
+		if sc.Mode == "now" && f.MinutelySummary != "" {
+			today.Summary = f.MinutelySummary
+		}
+		tz := zoneFor(f.Timezone, v.lng)
+		var peak *time.Time
+		if sc.Mode == "peak" {
+			i, _ := weather.BestDay(f, v.scoring(), sc)
+			today = f.Daily[i]
+			t := today.Time.In(tz)
+			peak = &t
+		}
+		if sc.DayOffset > 0 {
+			i, clamped := sc.Offset(f)
+			if clamped {
+				warn(k, nil, fmt.Sprintf("the forecast only has %d days, scoring the last of them rather than -day-offset %d", len(f.Daily), sc.DayOffset))
+			}
+			today = f.Daily[i]
+			t := today.Time.In(tz)
+			peak = &t
+		}
+		if sc.Weekend {
+			// show the weekend's first day rather than today
+			today = weather.ScoredDays(f, sc)[0]
+			t := today.Time.In(tz)
+			peak = &t
+		}
+		var days []scoredDay
+		if ics {
+			// each day weighted, adjusted and penalised like the score
+			for _, d := range weather.ScoredDays(f, sc) {
+				s := adjustments.adjust(k, v, weigh(k, v, sc.Day(d, f.Units, v.scoring())))
+				if home.set {
+					s = distancePenalty(k, home.miles(v), *distPenalty, s)
+				}
+				days = append(days, scoredDay{date: d.Time.In(tz), score: s, comfort: sc.Comfort(s), summary: d.Summary})
+			}
+		}
+		var t []float64
+		if *trendDays > 0 && sc.Mode != "now" {
+			f.EnsureDays(*trendDays, *fallbackHourly)
+			t = weather.Trend(f, v.scoring(), sc, *trendDays)
+		}
+		var ly *float64
+		if hist != nil {
+			if s, err := lastYear(hist, k, v, sc, adjustments, lastYearDays(f, sc, time.Now())); err != nil {
+				warn(k, err, "no comparison with last year")
+			} else {
+				ly = &s
+			}
+		}
+		gusty := sc.Mode == "event" && weather.Gusty(today, f.Units, sc.GustLimit)
+		if gusty {
+			notes = append(notes, fmt.Sprintf("gusty, gusts to %s %s", formatNum(today.Extra.WindGust), speedUnit(f.Units)))
+		}
+		var shares *factorShares
+		if *showFactors && (sc.Mode == "daily" || sc.Mode == "peak") {
+			shares = weather.FactorsOf(today, f.Units, v.scoring(), sc).Shares()
+		}
+		res = append(res, locScore{
+			Score:             n,
+			Comfort:           sc.Comfort(n),
+			Label:             labels.label(sc.Comfort(n)),
+			Location:          k,
+			Summary:           today.Summary,
+			Icon:              today.Icon,
+			Condition:         today.Condition,
+			TemperatureMax:    today.TemperatureMax,
+			TemperatureMin:    today.TemperatureMin,
+			Humidity:          today.Humidity,
+			CloudCover:        today.CloudCover,
+			PrecipProbability: today.PrecipProbability,
+			Sunshine:          weather.Sunshine(today),
+			WindSpeed:         today.WindSpeed,
+			Here:              v.here,
+			Distance:          dist,
+			Surplus:           surplus,
+			RawScore:          raw,
+			Alerts:            f.Alerts,
+			Extra:             today.Extra,
+			Factors:           shares,
+			WindBearing:       today.WindBearing,
+			Trend:             t,
+			PeakDay:           peak,
+			LastYear:          ly,
+			Units:             f.Units,
+			Region:            v.region,
+			Notes:             notes,
+			Gusty:             gusty,
+			Aliases:           v.aliases,
+			Fetched:           f.Fetched,
+			ScoringVersion:    sc.Version(),
+			Timezone:          tz.String(),
+			zone:              tz,
+			days:              days,
 		})
 	}
-	buf, err := json.MarshalIndent(sm, "", " ")
-	if err != nil {
-		return err
+	for _, name := range sortedLimitNames() {
+		if pool := keyPools[name]; pool != nil {
+			pool.report(name)
+		}
+	}
+	for _, s := range usageNotes() {
+		vlog("%s", s)
+		if *quotaFooter {
+			so.footer = append(so.footer, s)
+		}
+	}
+	if s := minRefreshNote(); s != "" {
+		slog.Info(s)
 	}
-	if webhook == "" {
-		fmt.Println(string(buf))
-		return nil
+	for _, s := range rateLimits() {
+		// easy to mistake for an outage otherwise
+		warn("", nil, s)
+		so.footer = append(so.footer, ":warning: "+s)
+	}
+	if len(res) == 0 && so.excluded > 0 {
+		// everyone was fetched fine, there's just nowhere good enough
+		msg := nobodyQualified(so.failedBy, so.excluded)
+		if *format == "slack" && len(outputs) == 0 && *snippetChannel == "" && *cardChannel == "" {
+			err = sendText(so, msg)
+		} else {
+			warn("", nil, msg)
+		}
+		if err != nil {
+			fatal(err)
+		}
+		pingHealthcheck(true)
+		return
+	}
+	if len(res) == 0 {
+		// every sink leads with the winner, so there has to be one
+		if len(failed) > 0 {
+			fatalf("not reporting, no location could be fetched (failed: %s)", strings.Join(failed, ", "))
+		}
+		fatalf("not reporting, there are no locations left to report on")
+	}
+	if len(res) < *minLocations {
+		fatalf("not reporting, only %d locations could be fetched which is less than -min-locations %d (failed: %s)",
+			len(res), *minLocations, strings.Join(failed, ", "))
+	}
+	if *maxAge > 0 {
+		if t := freshest(res); time.Since(t) > *maxAge {
+			fatalf("not reporting, the freshest forecast was fetched %s which is more than -max-age %s", ago(t, time.Now()), *maxAge)
+		}
+	}
+	if deterministic {
+		sort.Stable(byScore{res, key, tie})
+	} else {
+		sort.Sort(byScore{res, key, tie})
+	}
+	if *center != "" && *gridTop > 0 && len(res) > *gridTop {
+		res = res[:*gridTop]
+	}
+	if len(refs) > 0 {
+		n, err := markReferences(res, refs, key)
+		if err != nil {
+			fatal(err)
+		}
+		so.refs = strings.Join(refs, " and ")
+		so.beaters = n
+	}
+	if n, ok := closeCall(res, *closeMargin); ok {
+		res[0].Notes = append(res[0].Notes, n)
+		so.closeCall = n
+	}
+	if so.streakAbove > 0 {
+		lines, err := readHistory(*historyFile, time.Time{})
+		if err != nil && !os.IsNotExist(err) {
+			warn("", err, "no streaks, can't read the history")
+		} else {
+			n := streaks(lines, res, time.Now(), so.streakAbove, *streakGaps == "ignore")
+			for i := range res {
+				res[i].Streak = n[res[i].Location]
+			}
+		}
+	}
+	rules.apply(res)
+	for i := range res {
+		res[i].Color = so.color(res[i], res)
+	}
+	rep := newReport(res, provs, fo.units, sc.Mode, time.Now())
+	var crossed []string
+	if *crossAt > 0 || so.topMovers > 0 {
+		prev, version, err := lastRun(*historyFile)
+		if err != nil && *crossAt > 0 {
+			fatal(err)
+		}
+		if err != nil {
+			warn("", err, "no top movers, can't read the history")
+		}
+		if version != "" && version != sc.Version() {
+			warn("", nil, fmt.Sprintf("the last run in the history was scored differently (version %s, this is %s), the changes since may not mean much", version, sc.Version()))
+		}
+		if *crossAt > 0 {
+			crossed = crossings(prev, res, *crossAt)
+		}
+		if so.topMovers > 0 && len(prev) == 0 {
+			vlog("no earlier run in the history, no top movers yet")
+		} else if so.topMovers > 0 {
+			so.risers, so.fallers = topMovers(prev, res, so.topMovers)
+		}
+	}
+	if *historyFile != "" {
+		if err := appendHistory(*historyFile, rep); err != nil {
+			warn("", err, "saving history failed")
+		}
+	}
+	if *rawDir != "" {
+		if err := saveRaw(*rawDir, provs, fo, res, time.Now()); err != nil {
+			warn("", err, "saving the raw forecasts failed")
+		}
+	}
+	if *auditFile != "" {
+		if err := appendAudit(*auditFile, audit, time.Now()); err != nil {
+			warn("", err, "saving the audit failed")
+		}
+	}
+	if *share {
+		// sharing is a nice to have, don't lose the report over it
+		if u, err := shareGist(rep); err != nil {
+			warn("", err, "sharing results failed")
+		} else {
+			slog.Info("results shared at " + u)
+			so.footer = append(so.footer, "Full results: "+u)
+		}
+	}
+	var out io.Writer = os.Stdout
+	if *socketPath != "" {
+		c, err := net.DialTimeout("unix", *socketPath, 10*time.Second)
+		if err != nil {
+			fatal(err)
+		}
+		defer c.Close()
+		out, so.out = c, c
+	}
+	switch {
+	case *crossAt > 0:
+		if len(crossed) > 0 {
+			err = sendText(so, strings.Join(crossed, "\n"))
+		}
+	case *snippetChannel != "":
+		err = postSnippet(so, *snippetChannel, rep)
+	case *cardChannel != "":
+		err = postCard(so, *cardChannel, rep)
+	case len(outputs) > 0:
+		var required []string
+		if *requireOutputs != "" {
+			required = strings.Split(*requireOutputs, ",")
+		}
+		err = notifyAll(outputs.notifiers(so, jsonKeys, icsOpts{above: *icsAbove, labels: labels}), rep, required)
+	case *format == "json":
+		err = writeJSON(out, rep, so.pretty, jsonKeys)
+	case *format == "jsonl":
+		err = writeJSONL(out, rep, jsonKeys)
+	case *format == "geojson":
+		err = writeGeoJSON(out, rep, func(v locScore) string { return so.color(v, res) }, so.pretty)
+	case *format == "report":
+		err = writeReport(out, rep, so.pretty, jsonKeys)
+	case *format == "png":
+		err = writeCard(out, rep, so)
+	case *format == "ics":
+		err = writeICS(out, rep, icsOpts{above: *icsAbove, labels: labels})
+	default:
+		err = sendToSlack(so, rep)
 	}
-	body := bytes.NewBuffer(buf)
-	resp, err := http.Post(webhook, "application/json", body)
 	if err != nil {
+		fatal(err)
+	}
+	pingHealthcheck(true)
+	waitRevalidations(revalidateWait)
+}
+
+// warmCache fetches every location so the cache is fresh for a -c run
+func warmCache(provs []provider, names []string, failFast bool) error {
+	if err := os.MkdirAll("cache", 0750); err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad http response %s", resp.Status)
+	var failed []string
+	all := fetchEach(provs, names)
+	for _, k := range names {
+		if stopping() {
+			return fmt.Errorf("interrupted warming the cache")
+		}
+		if err := all[k].err; err != nil {
+			if failFast {
+				panic(err)
+			}
+			warn(k, err, "couldn't warm the cache")
+			failed = append(failed, k)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("cache warmed for %d of %d locations, failed: %s",
+			len(names)-len(failed), len(names), strings.Join(failed, ", "))
 	}
+	vlog("cache warmed for %d locations", len(names))
 	return nil
 }
 
-type byScore []locScore
+// closeCall describes the top two locations as too close to call when
+// their scores are within margin of each other
+func closeCall(res []locScore, margin float64) (string, bool) {
+	if len(res) < 2 {
+		return "", false
+	}
+	d := math.Abs(res[0].Score - res[1].Score)
+	if d >= margin {
+		return "", false
+	}
+	return fmt.Sprintf("too close to call, %s and %s are only %s points apart",
+		res[0].Location, res[1].Location, localize(strconv.FormatFloat(d, 'f', 1, 64))), true
+}
+
+// freshest is the newest fetch time in res
+func freshest(res []locScore) time.Time {
+	var t time.Time
+	for _, v := range res {
+		if v.Fetched.After(t) {
+			t = v.Fetched
+		}
+	}
+	return t
+}
 
-func (ls byScore) Len() int {
-	return len(ls)
+// oldest is the fetch time of the stalest forecast
+func oldest(res []locScore) time.Time {
+	var t time.Time
+	for _, v := range res {
+		if t.IsZero() || v.Fetched.Before(t) {
+			t = v.Fetched
+		}
+	}
+	return t
 }
 
-func (ls byScore) Less(a, b int) bool {
-	return ls[a].Score > ls[b].Score
+// ago says how long before now t was, the way a person would
+func ago(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	case d < 48*time.Hour:
+		return "yesterday"
+	}
+	return fmt.Sprintf("%d days ago", int(d/(24*time.Hour)))
 }
 
-func (ls byScore) Swap(a, b int) {
-	ls[a], ls[b] = ls[b], ls[a]
+// readSecret reads a secret from a file, or from an already open file
+// descriptor when fd isn't -1, so it never shows up in ps or the
+// environment. Surrounding whitespace, like a trailing newline, is dropped.
+func readSecret(fn string, fd int) (string, error) {
+	var buf []byte
+	var err error
+	name := fn
+	if fd >= 0 {
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+		if f == nil {
+			return "", fmt.Errorf("fd %d isn't open", fd)
+		}
+		defer f.Close()
+		name = f.Name()
+		buf, err = ioutil.ReadAll(f)
+	} else {
+		buf, err = ioutil.ReadFile(fn)
+	}
+	if err != nil {
+		return "", err
+	}
+	s := strings.TrimSpace(string(buf))
+	if s == "" {
+		return "", fmt.Errorf("%s is empty", name)
+	}
+	return s, nil
 }
 
-const (
-	perfectMaxTemp  = 80
-	perfectMinTemp  = 60
-	perfectHumidity = .6
-)
+func sortedKeys(m map[string]bool) []string {
+	ks := make([]string, 0, len(m))
+	for k := range m {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+	return ks
+}
 
-func score(f *fioResp) int {
-	today := f.Daily.Data[0]
-	tmax := today.TemperatureMax
-	if tmax > perfectMaxTemp {
-		tmax = perfectMaxTemp*2 - tmax
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
 	}
-	tmax += 100 - perfectMaxTemp
-	tmin := today.TemperatureMin
-	if tmin > perfectMinTemp {
-		tmin = perfectMinTemp*2 - tmin
+	return def
+}
+
+// precision is the number of decimals formatNum shows
+var precision int
+
+// formatNum rounds v for display. Only output is rounded, sorting and
+// coloring always use the full precision value.
+func formatNum(v float64) string {
+	return localize(strconv.FormatFloat(v, 'f', precision, 64))
+}
+
+// formatSigned is formatNum with a sign either way, eg +12 or -3
+func formatSigned(v float64) string {
+	if v >= 0 {
+		return "+" + formatNum(v)
 	}
-	tmin += 100 - perfectMinTemp
-	ccover := int((1.0 - today.CloudCover) * 100)
-	precip := int((1.0 - today.PrecipProbability) * 100)
-	h := today.Humidity
-	if h > perfectHumidity {
-		h = perfectHumidity*2 - h
+	return formatNum(v)
+}
+
+// sortKey is what the results are ranked, colored and displayed by
+type sortKey struct {
+	title  string
+	value  func(locScore) float64
+	desc   bool // bigger values rank first
+	format func(float64) string
+}
+
+func formatPct(v float64) string { return fmt.Sprintf("%.0f%s%%", v*100, curLocale.pct) }
+
+var sortKeys = map[string]sortKey{
+	"score":    {title: "Score", value: func(l locScore) float64 { return l.Score }, desc: true, format: func(v float64) string { return formatScore(v) }},
+	"temp":     {title: "High", value: func(l locScore) float64 { return l.TemperatureMax }, desc: true, format: func(v float64) string { return formatNum(v) + "°" }},
+	"precip":   {title: "Precip", value: func(l locScore) float64 { return l.PrecipProbability }, format: formatPct},
+	"humidity": {title: "Humidity", value: func(l locScore) float64 { return l.Humidity }, format: formatPct},
+	"clouds":   {title: "Clouds", value: func(l locScore) float64 { return l.CloudCover }, format: formatPct},
+	"surplus": {title: "Surplus", value: func(l locScore) float64 {
+		if l.Surplus == nil {
+			return 0
+		}
+		return *l.Surplus
+	}, desc: true, format: func(v float64) string { return "+" + formatNum(v) }},
+}
+
+// a tiebreak reports whether a should rank ahead of b when they're tied
+type tiebreak func(a, b locScore) bool
+
+func parseTiebreak(s string) (tiebreak, error) {
+	switch {
+	case s == "name":
+		return func(a, b locScore) bool { return a.Location < b.Location }, nil
+	case s == "temp":
+		return func(a, b locScore) bool { return a.TemperatureMax > b.TemperatureMax }, nil
+	case s == "lowhumidity":
+		return func(a, b locScore) bool { return a.Humidity < b.Humidity }, nil
+	case strings.HasPrefix(s, "preferred:"):
+		name := strings.TrimPrefix(s, "preferred:")
+		return func(a, b locScore) bool { return a.Location == name && b.Location != name }, nil
 	}
-	humid := int(h*100 + 40)
-	return (int(tmax*2) + int(tmin) + ccover + precip + humid)
+	return nil, fmt.Errorf("unknown -tiebreak %q", s)
+}
+
+type byScore struct {
+	ls  []locScore
+	key sortKey
+	tie tiebreak
+}
+
+func (s byScore) Len() int {
+	return len(s.ls)
 }
 
-func get(l loc, useCache bool) ([]byte, error) {
-	u := fmt.Sprintf("https://api.forecast.io/forecast/52d39c0c95e7f6f475e316c6c516b5e7/%f,%f", l.lat, l.lng)
-	fn := fmt.Sprintf("cache/%x", sha1.Sum([]byte(u)))
-	buf, err := ioutil.ReadFile(fn)
-	if useCache && err == nil && len(buf) > 0 {
-		return buf, nil
+func (s byScore) Less(a, b int) bool {
+	va, vb := s.key.value(s.ls[a]), s.key.value(s.ls[b])
+	if va == vb && s.tie != nil {
+		return s.tie(s.ls[a], s.ls[b])
+	}
+	if s.key.desc {
+		return va > vb
+	}
+	return va < vb
+}
+
+func (s byScore) Swap(a, b int) {
+	s.ls[a], s.ls[b] = s.ls[b], s.ls[a]
+}
+
+// compass is a bearing in degrees as one of the 8 compass points
+func compass(deg float64) string {
+	points := []string{"N", "NE", "E", "SE", "S", "SW", "W", "NW"}
+	i := int(math.Round(math.Mod(deg, 360)/45)) % 8
+	if i < 0 {
+		i += 8
+	}
+	return points[i]
+}
+
+// speedUnit is how convertSpeed's units are written
+func speedUnit(units string) string {
+	switch units {
+	case "si":
+		return "m/s"
+	case "ca":
+		return "km/h"
+	}
+	return "mph"
+}
+
+// cacheKey identifies a forecast by what was asked for rather than the
+// exact url, so adding a query parameter or changing the base url doesn't
+// throw the cache away. Entries are per day.
+func cacheKey(provider string, l loc, units string, date time.Time) string {
+	return fmt.Sprintf("%s|%.6f|%.6f|%s|%s", provider, l.lat, l.lng, units, date.Format("2006-01-02"))
+}
+
+// cacheFile is where the entry for key lives. The v2- prefix keeps these
+// apart from the old sha1(url) files, which are simply never read again.
+func cacheFile(key string) string {
+	return fmt.Sprintf("cache/v2-%x", sha1.Sum([]byte(key)))
+}
+
+// get fetches u, keeping a copy in the cache directory under key. It also
+// returns when the data was fetched, which is the cache file's time if it
+// came from the cache.
+func get(u, key string, useCache bool) ([]byte, time.Time, error) {
+	// held across the fetch too, a second caller for the key waits and
+	// then reads what the first wrote (with -c)
+	defer lockCache(key)()
+	buf, t, cached := cache.load(key)
+	vlog("GET %s (cache key %s)", redact(u), key)
+	if offline && !cached {
+		return nil, time.Time{}, fmt.Errorf("%w, offline", ErrNotCached)
+	}
+	if (useCache || offline) && cached {
+		return buf, t, nil
+	}
+	if cached && tooSoon(key, t) {
+		return buf, t, nil
+	}
+	if staleWhileRevalidate > 0 && cached && time.Since(t) <= staleWhileRevalidate {
+		vlog("using the cached %s from %s and refreshing it in the background", key, ago(t, time.Now()))
+		revalidate(u, key)
+		return buf, t, nil
+	}
+	// the provider is the first part of the key
+	prov, _, _ := strings.Cut(key, "|")
+	return fetchAndCache(u, key, prov, cached, buf, t)
+}
+
+// fetchAndCache is the network half of get, the caller holds key's lock.
+// The cached copy, if there is one, stands in when the provider rate
+// limits us.
+func fetchAndCache(u, key, prov string, cached bool, cachedBuf []byte, cachedAt time.Time) ([]byte, time.Time, error) {
+	if err := limitedBy(prov); err != nil {
+		// another request would only dig the hole deeper
+		if cached {
+			noteCached(prov)
+			return cachedBuf, cachedAt, nil
+		}
+		return nil, time.Time{}, fmt.Errorf("%w, and not in the cache", err)
+	}
+	pool := poolFor(prov)
+	var buf []byte
+	var err error
+	for attempt := 1; ; attempt++ {
+		if pool != nil {
+			pool.count(u)
+		}
+		buf, err = fetchBody(u, prov)
+		if errors.Is(err, ErrRateLimited) && pool != nil {
+			if next, ok := pool.retire(u); ok {
+				// a fresh key, not a retry of the same request
+				u, attempt = next, attempt-1
+				continue
+			}
+		}
+		if err == nil || !errors.Is(err, ErrBadResponse) || attempt >= badResponseTries {
+			break
+		}
+		vlog("GET %s: %v, retrying", redact(u), err)
+	}
+	if errors.Is(err, ErrRateLimited) {
+		noteLimit(prov, err)
+		if cached {
+			vlog("GET %s: %v, using the cached copy from %s", redact(u), err, ago(cachedAt, time.Now()))
+			noteCached(prov)
+			return cachedBuf, cachedAt, nil
+		}
 	}
-	resp, err := http.Get(u)
 	if err != nil {
-		return nil, err
+		return nil, time.Time{}, err
+	}
+	now := time.Now()
+	if err := cache.save(key, buf, now); err != nil {
+		vlog("caching %s: %v", key, err)
 	}
-	buf, err = ioutil.ReadAll(resp.Body)
+	return buf, now, nil
+}
+
+// how many times get tries a url that keeps giving back broken json
+const badResponseTries = 3
+
+// fetchBody gets u's body, decompressed so -c reads it as is. A body that
+// isn't json (cut off mid stream say) is an ErrBadResponse, checked here so
+// it never makes it into the cache.
+func fetchBody(u, prov string) ([]byte, error) {
+	resp, err := providerGet(u)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNetwork, err)
+	}
+	defer resp.Body.Close()
+	// a 429 still says how much quota there is
+	noteUsage(prov, resp.Header)
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+	buf, err := readBody(resp)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: reading body: %w", ErrBadResponse, err)
+	}
+	if !json.Valid(buf) {
+		return nil, fmt.Errorf("%w: %d bytes from %s that aren't valid json (truncated?)", ErrBadResponse, len(buf), resp.Request.URL.Host)
 	}
-	ioutil.WriteFile(fn, buf, 0740)
 	return buf, nil
 }
 
+// the ends of the color gradient, worst to best
+var (
+	worstColor = rgb{R: 255}
+	bestColor  = rgb{G: 255}
+)
+
 func getValueBetweenTwoFixedColors(value float64) string {
-	aR := 255.0
-	aG := 0.0
-	aB := 0.0
-	bR := 0.0
-	bG := 255.0
-	bB := 0.0
-
-	red := int((bR-aR)*value + aR)   // Evaluated as -255*value + 255.
-	green := int((bG-aG)*value + aG) // Evaluates as 0.
-	blue := int((bB-aB)*value + aB)  // Evaluates as 255*value + 0.
-	return fmt.Sprintf("#%02x%02x%02x", red, green, blue)
+	return weather.Between(worstColor, bestColor, value)
 }
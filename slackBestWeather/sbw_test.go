@@ -0,0 +1,290 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// memProvider serves a canned forecast.io response for every location,
+// parsed each time like a cache hit is. The days are moved on by run so
+// each run's are new to the score memo, like the next day's forecast.
+type memProvider struct {
+	buf []byte
+	run int
+}
+
+func (p *memProvider) name() string { return "mem" }
+
+func (p *memProvider) fetch(name string, l loc) (*forecast, error) {
+	return p.parse(name, p.buf)
+}
+
+func (p *memProvider) parse(name string, buf []byte) (*forecast, error) {
+	f, err := parseFIO(name, buf, "us")
+	if err != nil {
+		return nil, err
+	}
+	for i := range f.Daily {
+		f.Daily[i].Time = f.Daily[i].Time.AddDate(0, 0, p.run)
+	}
+	return f, nil
+}
+
+// BenchmarkPipeline is a run's fetch, score and sort for n locations,
+// without the network or the cache
+func BenchmarkPipeline(b *testing.B) {
+	buf, err := os.ReadFile("testdata/forecastio.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	saved := locations
+	defer func() { locations = saved }()
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			locations = make(map[string]loc)
+			names := make([]string, n)
+			for i := range names {
+				names[i] = fmt.Sprintf("loc%d", i)
+				locations[names[i]] = loc{lat: float64(i)/20 - 25, lng: float64(i)*.3 - 150}
+			}
+			p := &memProvider{buf: buf}
+			sc := scoreConfig{Days: 3}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.run = i
+				all := fetchEach([]provider{p}, names)
+				res := make([]locScore, 0, n)
+				for _, k := range names {
+					if all[k].err != nil {
+						b.Fatal(all[k].err)
+					}
+					res = append(res, locScore{Location: k, Score: weather.Score(all[k].f, locations[k].scoring(), sc)})
+				}
+				sort.SliceStable(res, func(i, j int) bool { return res[i].Score > res[j].Score })
+			}
+		})
+	}
+}
+
+// inCacheDir runs the rest of the test in a new directory with an empty
+// cache/, and store as the cache
+func inCacheDir(t *testing.T, store func() (cacheStore, error)) {
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("cache", 0750); err != nil {
+		t.Fatal(err)
+	}
+	saved := cache
+	t.Cleanup(func() { cache = saved })
+	c, err := store()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache = c
+}
+
+var cacheStores = map[string]func() (cacheStore, error){
+	"files":  func() (cacheStore, error) { return fileCache{}, nil },
+	"packed": func() (cacheStore, error) { return newPackCache() },
+}
+
+// TestGetConcurrent is many fetches of one location at once with -c,
+// which should make one request and leave the rest to read the cache.
+// Run it with -race.
+func TestGetConcurrent(t *testing.T) {
+	const body = `{"daily":{"data":[{"temperatureMax":80}]}}`
+	for name, store := range cacheStores {
+		t.Run(name, func(t *testing.T) {
+			inCacheDir(t, store)
+			var hits atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				// long enough for the others to pile up behind the lock
+				time.Sleep(20 * time.Millisecond)
+				io.WriteString(w, body)
+			}))
+			defer srv.Close()
+			key := cacheKey("forecastio", locations["Islip"], "us", time.Now())
+			bufs := make([][]byte, 16)
+			errs := make([]error, len(bufs))
+			var wg sync.WaitGroup
+			for i := range bufs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					bufs[i], _, errs[i] = get(srv.URL, key, true)
+				}(i)
+			}
+			wg.Wait()
+			for i := range bufs {
+				if errs[i] != nil {
+					t.Fatalf("get %d: %v", i, errs[i])
+				}
+				if string(bufs[i]) != body {
+					t.Errorf("get %d read %q, want %q", i, bufs[i], body)
+				}
+			}
+			if n := hits.Load(); n != 1 {
+				t.Errorf("fetched %d times, want once with the rest read from the cache", n)
+			}
+			if buf, _, ok := cache.load(key); !ok || string(buf) != body {
+				t.Errorf("cached %q, want %q", buf, body)
+			}
+		})
+	}
+}
+
+// TestGetTruncated is a body cut off mid stream, which get should retry
+// and never cache
+func TestGetTruncated(t *testing.T) {
+	const body = `{"daily":{"data":[{"temperatureMax":80,"temperatureMin":60}]}}`
+	cuts := map[string]func(w http.ResponseWriter){
+		// the connection dropped before the Content-Length was sent
+		"dropped": func(w http.ResponseWriter) {
+			w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+			io.WriteString(w, body[:len(body)/2])
+		},
+		// all there as far as http goes, but not json
+		"short": func(w http.ResponseWriter) { io.WriteString(w, body[:len(body)/2]) },
+	}
+	for name, cut := range cuts {
+		for _, bad := range []int{1, badResponseTries} {
+			t.Run(fmt.Sprintf("%s %d", name, bad), func(t *testing.T) {
+				inCacheDir(t, cacheStores["files"])
+				var hits atomic.Int32
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if int(hits.Add(1)) <= bad {
+						cut(w)
+						return
+					}
+					io.WriteString(w, body)
+				}))
+				defer srv.Close()
+				key := cacheKey("forecastio", locations["Islip"], "us", time.Now())
+				buf, _, err := get(srv.URL, key, false)
+				cached, _, ok := cache.load(key)
+				if bad < badResponseTries {
+					if err != nil || string(buf) != body {
+						t.Fatalf("got %q, %v, want the body from the retry", buf, err)
+					}
+					if n := hits.Load(); n != int32(bad+1) {
+						t.Errorf("fetched %d times, want %d", n, bad+1)
+					}
+					if !ok || string(cached) != body {
+						t.Errorf("cached %q, want the good body", cached)
+					}
+					return
+				}
+				if !errors.Is(err, ErrBadResponse) {
+					t.Fatalf("got %q, %v, want an ErrBadResponse", buf, err)
+				}
+				if n := hits.Load(); n != badResponseTries {
+					t.Errorf("fetched %d times, want %d", n, badResponseTries)
+				}
+				if ok {
+					t.Errorf("cached %q, a bad body shouldn't be", cached)
+				}
+			})
+		}
+	}
+}
+
+// TestAgo is ago either side of each of its boundaries
+func TestAgo(t *testing.T) {
+	now := time.Date(2016, 10, 14, 12, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		d    time.Duration
+		want string
+	}{
+		// a clock a little ahead
+		{-time.Minute, "just now"},
+		{0, "just now"},
+		{59 * time.Second, "just now"},
+		{time.Minute, "1m ago"},
+		{59*time.Minute + 59*time.Second, "59m ago"},
+		{time.Hour, "1h ago"},
+		{23*time.Hour + 59*time.Minute, "23h ago"},
+		{24 * time.Hour, "yesterday"},
+		{47*time.Hour + 59*time.Minute, "yesterday"},
+		{48 * time.Hour, "2 days ago"},
+		{10 * 24 * time.Hour, "10 days ago"},
+	} {
+		if got := ago(now.Add(-c.d), now); got != c.want {
+			t.Errorf("ago %s = %q, want %q", c.d, got, c.want)
+		}
+	}
+}
+
+// TestFetchBodyErrors is each way a fetch fails keeping its class and the
+// error underneath it, for errors.Is and errors.As
+func TestFetchBodyErrors(t *testing.T) {
+	status := func(code int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(code) }
+	}
+	for _, c := range []struct {
+		name      string
+		h         http.HandlerFunc
+		class     error
+		transient bool
+	}{
+		{"500", status(http.StatusInternalServerError), ErrServer, true},
+		{"503", status(http.StatusServiceUnavailable), ErrServer, true},
+		{"404", status(http.StatusNotFound), ErrRequest, false},
+		{"400", status(http.StatusBadRequest), ErrRequest, false},
+		{"429", status(http.StatusTooManyRequests), ErrRateLimited, false},
+		{"bad gzip", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			io.WriteString(w, "this isn't gzip at all")
+		}, gzip.ErrHeader, true},
+	} {
+		srv := httptest.NewServer(c.h)
+		_, err := fetchBody(srv.URL, "test")
+		srv.Close()
+		if !errors.Is(err, c.class) {
+			t.Errorf("%s: %v, want a %v", c.name, err, c.class)
+		}
+		if transient(err) != c.transient {
+			t.Errorf("%s: transient is %v, want %v", c.name, !c.transient, c.transient)
+		}
+	}
+	// nothing listening
+	srv := httptest.NewServer(status(http.StatusOK))
+	srv.Close()
+	_, err := fetchBody(srv.URL, "test")
+	var ue *url.Error
+	if !errors.Is(err, ErrNetwork) || !errors.As(err, &ue) || !transient(err) {
+		t.Errorf("%v, want an ErrNetwork wrapping the *url.Error", err)
+	}
+}
+
+// TestChildArgs is a -serve or refresh child run left only the flags
+// for what it's asked for, so it doesn't post, fan out or drip
+// messages itself
+func TestChildArgs(t *testing.T) {
+	args := []string{"-serve-addr", ":8080", "-output", "slack", "-output=png:out.png", "-require-outputs", "slack",
+		"-stagger", "5s", "-post-card", "weather", "-mode", "ski", "-days", "2"}
+	for _, c := range []struct {
+		name      string
+		got, want []string
+	}{
+		{"report", reportArgs(args), []string{"-format", "report", "-mode", "ski", "-days", "2"}},
+		{"refresh", refreshArgs(args), []string{"-format", "slack", "-slack-blocks", "-refresh-button", "-mode", "ski", "-days", "2"}},
+	} {
+		if !reflect.DeepEqual(c.got, c.want) {
+			t.Errorf("%s: %q, want %q", c.name, c.got, c.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// clock is a time of day, HH:MM
+type clock struct {
+	hour, min int
+}
+
+// atFlag is a comma separated, repeatable list of local times to run at
+type atFlag []clock
+
+func (a *atFlag) String() string {
+	var s []string
+	for _, c := range *a {
+		s = append(s, fmt.Sprintf("%02d:%02d", c.hour, c.min))
+	}
+	return strings.Join(s, ",")
+}
+
+func (a *atFlag) Set(s string) error {
+	for _, t := range strings.Split(s, ",") {
+		var c clock
+		if _, err := fmt.Sscanf(strings.TrimSpace(t), "%d:%d", &c.hour, &c.min); err != nil ||
+			c.hour < 0 || c.hour > 23 || c.min < 0 || c.min > 59 {
+			return fmt.Errorf("-at %q should be HH:MM", t)
+		}
+		*a = append(*a, c)
+	}
+	return nil
+}
+
+// nextAt is the first of the times after now, in tz. time.Date takes care
+// of DST: a time skipped in the spring runs an hour later, and a time that
+// happens twice in the fall runs the first time.
+func nextAt(now time.Time, times []clock, tz *time.Location) time.Time {
+	now = now.In(tz)
+	var next time.Time
+	for day := 0; day <= 1; day++ {
+		for _, c := range times {
+			t := time.Date(now.Year(), now.Month(), now.Day()+day, c.hour, c.min, 0, 0, tz)
+			if t.After(now) && (next.IsZero() || t.Before(next)) {
+				next = t
+			}
+		}
+	}
+	return next
+}
+
+// runAt is the daemon: it runs a report at each of the times, forever.
+// Every report is a fresh run of this program with the same flags
+// minus the scheduling ones, so one bad run doesn't take the daemon
+// down with it.
+func runAt(times []clock, tz *time.Location) {
+	args := withoutFlags(os.Args[1:], "at", "at-tz")
+	for {
+		next := nextAt(time.Now(), times, tz)
+		slog.Info("next report at " + next.Format("Mon Jan 2 15:04 MST"))
+		for time.Now().Before(next) {
+			if stopping() {
+				return
+			}
+			d := time.Until(next)
+			if d > time.Second {
+				d = time.Second
+			}
+			time.Sleep(d)
+		}
+		cmd := exec.Command(os.Args[0], args...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			warn("", err, "scheduled report failed")
+		}
+	}
+}
+
+// withoutFlags drops the named flags, and their values, from args
+func withoutFlags(args []string, names ...string) []string {
+	drop := make(map[string]bool)
+	for _, n := range names {
+		drop[n] = true
+	}
+	var out []string
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" || !strings.HasPrefix(a, "-") {
+			out = append(out, args[i:]...)
+			break
+		}
+		name := strings.TrimLeft(a, "-")
+		if j := strings.Index(name, "="); j >= 0 {
+			if !drop[name[:j]] {
+				out = append(out, a)
+			}
+			continue
+		}
+		// unless it's a bool flag the value is the next argument, which
+		// goes or stays with it
+		n := 1
+		if !isBoolFlag(name) && i+1 < len(args) {
+			n = 2
+		}
+		if !drop[name] {
+			out = append(out, args[i:i+n]...)
+		}
+		i += n - 1
+	}
+	return out
+}
+
+func isBoolFlag(name string) bool {
+	f := flag.Lookup(name)
+	if f == nil {
+		return false
+	}
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
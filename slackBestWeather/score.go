@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math"
+	"strings"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// the forecast model and the scoring are in package weather, these are
+// the names the command has always used for them
+type (
+	forecast     = weather.Forecast
+	day          = weather.Day
+	hour         = weather.Hour
+	minute       = weather.Minute
+	dayExtra     = weather.DayExtra
+	condition    = weather.Condition
+	scoreConfig  = weather.Config
+	factorShares = weather.FactorShares
+	rgb          = weather.RGB
+)
+
+// scoring is what the weather package needs to know about l
+func (l loc) scoring() weather.Location {
+	return weather.Location{Lat: l.lat, Lng: l.lng, Normals: l.normals, Bearing: l.bearing}
+}
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws scores as bars on the same 0 to BestScore scale for
+// every location, so the rows can be compared with each other
+func sparkline(scores []float64) string {
+	var b strings.Builder
+	for _, s := range scores {
+		i := int(math.Round(s / weather.BestScore * float64(len(sparks)-1)))
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(sparks) {
+			i = len(sparks) - 1
+		}
+		b.WriteRune(sparks[i])
+	}
+	return b.String()
+}
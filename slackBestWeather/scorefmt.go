@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// formatScore is how a score is shown everywhere it's shown, -score-format
+var formatScore = formatNum
+
+// scorePlaceholder is one {name} in a -score-format
+var scorePlaceholder = regexp.MustCompile(`\{[^}]*\}`)
+
+// newScoreFormat is the formatScore for the -score-format template t. Its
+// placeholders are {raw} for the score, {pct} for the 0-100 comfort
+// index comfort works out and {grade} for that index as a letter, eg
+// "{pct}%" or "{grade} ({raw})".
+func newScoreFormat(t string, comfort func(float64) float64) (func(float64) string, error) {
+	for _, p := range scorePlaceholder.FindAllString(t, -1) {
+		switch p {
+		case "{raw}", "{pct}", "{grade}":
+		default:
+			return nil, fmt.Errorf("-score-format %q: unknown %s, it can have {raw}, {pct} and {grade}", t, p)
+		}
+	}
+	return func(v float64) string {
+		// rounded so the grade agrees with the {pct} next to it
+		c := math.Round(comfort(v))
+		return strings.NewReplacer(
+			"{raw}", formatNum(v),
+			"{pct}", strconv.Itoa(int(c)),
+			"{grade}", letterGrade(c),
+		).Replace(t)
+	}, nil
+}
+
+// letterGrade is a 0-100 comfort index as a school grade, 90 and up an
+// A down to an F under 60
+func letterGrade(c float64) string {
+	switch {
+	case c >= 90:
+		return "A"
+	case c >= 80:
+		return "B"
+	case c >= 70:
+		return "C"
+	case c >= 60:
+		return "D"
+	}
+	return "F"
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"time"
+)
+
+// dashboard is -serve-addr, the latest report on a web page and as json.
+// Like runAt every report is a child run of this program with the same
+// flags, asked for -format report, so a bad run only leaves the last
+// good report up.
+type dashboard struct {
+	so slackOpts
+	// args are the child's, see reportArgs
+	args []string
+
+	mu  sync.Mutex
+	rep *report
+	err error
+}
+
+// reportArgs are the flags for a child run: the serving and scheduling
+// ones dropped, and any other output replaced by -format report
+func reportArgs(args []string) []string {
+	args = withoutFlags(args, "serve-addr", "serve-every", "watch-leader", "interactive-addr", "signing-secret-file", "at", "at-tz", "format", "json-keys",
+		"output", "require-outputs", "stagger", "socket", "post-as-snippet", "post-card", "alert-crossing", "share")
+	return append([]string{"-format", "report"}, args...)
+}
+
+// refresh runs a report and keeps it if it worked
+func (d *dashboard) refresh() {
+	cmd := exec.Command(os.Args[0], d.args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	var r report
+	if err == nil {
+		if err = json.Unmarshal(out, &r); err != nil {
+			err = fmt.Errorf("reading the report: %v", err)
+		}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+	if err != nil {
+		warn("", err, "dashboard report failed, keeping the last one")
+		return
+	}
+	d.rep = &r
+}
+
+func (d *dashboard) latest() (*report, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rep, d.err
+}
+
+func (d *dashboard) serveAPI(w http.ResponseWriter, req *http.Request) {
+	r, err := d.latest()
+	if r == nil {
+		msg := "no report yet"
+		if err != nil {
+			msg += ": " + err.Error()
+		}
+		http.Error(w, msg, http.StatusServiceUnavailable)
+		return
+	}
+	buf, err := marshal(r, d.so.pretty)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(buf, '\n'))
+}
+
+var dashboardPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Reload}}">
+<title>Best weather</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: .4em .8em; text-align: left; }
+td.n { text-align: right; }
+.note { color: #555; font-size: .9em; }
+</style>
+</head>
+<body>
+<h1>Best weather</h1>
+{{if .Report}}<p class="note">{{.Report.Time.Format "Mon Jan 2 15:04 MST"}}, {{.Report.Mode}} mode{{range .Report.Providers}}, {{.}}{{end}}</p>
+<table>
+<tr><th>#</th><th>Location</th><th>Score</th><th>Summary</th><th>High</th><th>Low</th><th>Rain</th><th>Clouds</th></tr>
+{{range .Rows}}<tr style="background-color: {{.Color}}">
+<td class="n">{{.Rank}}</td><td>{{.V.Location}}</td><td class="n">{{.Score}}</td><td>{{.V.Summary}}</td>
+<td class="n">{{printf "%.0f" .V.TemperatureMax}}</td><td class="n">{{printf "%.0f" .V.TemperatureMin}}</td>
+<td class="n">{{printf "%.0f%%" .Precip}}</td><td class="n">{{printf "%.0f%%" .Clouds}}</td>
+</tr>
+{{end}}</table>
+{{else}}<p>No report yet.</p>
+{{end}}{{if .Err}}<p class="note">The last update failed: {{.Err}}</p>
+{{end}}</body>
+</html>
+`))
+
+type dashboardRow struct {
+	V              locScore
+	Score          string
+	Rank           int
+	Color          template.CSS
+	Precip, Clouds float64
+}
+
+func (d *dashboard) servePage(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	r, err := d.latest()
+	data := struct {
+		Report *report
+		Rows   []dashboardRow
+		Err    error
+		Reload int
+	}{Report: r, Err: err, Reload: dashboardReload}
+	if r != nil && len(r.Results) > 0 {
+		rank := ranks(r.Results)
+		// color wants them best first, whatever the -display-order
+		byKey := append([]locScore(nil), r.Results...)
+		sort.SliceStable(byKey, func(i, j int) bool {
+			a, b := d.so.key.value(byKey[i]), d.so.key.value(byKey[j])
+			if d.so.key.desc {
+				return a > b
+			}
+			return a < b
+		})
+		for _, v := range r.Results {
+			// the child worked the color out, -format-rule and all
+			c := v.Color
+			if c == "" {
+				c = d.so.color(v, byKey)
+			}
+			data.Rows = append(data.Rows, dashboardRow{V: v, Rank: rank[v.Location], Score: formatScore(v.Score),
+				Color: template.CSS(c), Precip: v.PrecipProbability * 100, Clouds: v.CloudCover * 100})
+		}
+	}
+	var buf bytes.Buffer
+	if err := dashboardPage.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+// how often, in seconds, the page reloads itself. It's cheap, the report
+// only changes on the refresh schedule.
+const dashboardReload = 300
+
+// serveDashboard serves the report on addr, refreshing it every interval
+// or, with -at, at those times
+func serveDashboard(addr string, so slackOpts, every time.Duration, times []clock, tz *time.Location) error {
+	d := &dashboard{so: so, args: reportArgs(os.Args[1:])}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", d.servePage)
+	mux.HandleFunc("/api/report", d.serveAPI)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ListenAndServe() }()
+	slog.Info("serving the dashboard on " + addr)
+	for {
+		d.refresh()
+		next := time.Now().Add(every)
+		if len(times) > 0 {
+			next = nextAt(time.Now(), times, tz)
+		}
+		for time.Now().Before(next) {
+			select {
+			case err := <-errc:
+				return err
+			default:
+			}
+			if stopping() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				return srv.Shutdown(ctx)
+			}
+			wait := time.Until(next)
+			if wait > time.Second {
+				wait = time.Second
+			}
+			time.Sleep(wait)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+)
+
+// interrupted is set once a SIGINT or SIGTERM arrives
+var interrupted int32
+
+// handleSignals lets the current location finish, with its cache and
+// history writes, on the first SIGINT or SIGTERM. A second one exits
+// right away, which is safe because the files are written atomically.
+func handleSignals() {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		s := <-c
+		atomic.StoreInt32(&interrupted, 1)
+		warn("", nil, "got "+s.String()+", stopping after the current location (again to quit now)")
+		<-c
+		os.Exit(1)
+	}()
+}
+
+func stopping() bool {
+	return atomic.LoadInt32(&interrupted) != 0
+}
+
+// writeFileAtomic is ioutil.WriteFile by way of a temp file and a rename,
+// so a reader (or a killed run) never sees half a file
+func writeFileAtomic(fn string, buf []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(fn), filepath.Base(fn)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, fn); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
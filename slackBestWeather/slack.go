@@ -0,0 +1,740 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// The slack incoming webhook message format
+type field struct {
+	Title string `json:"title,omitempty"`
+	Value string `json:"value"`
+	Short bool   `json:"short,omitempty"`
+}
+
+type attachment struct {
+	Fallback    string   `json:"fallback,omitempty"`
+	Color       string   `json:"color,omitempty"`
+	PreText     string   `json:"pretext,omitempty"`
+	Author_Name string   `json:"author_name,omitempty"`
+	Author_Link string   `json:"author_link,omitempty"`
+	Author_icon string   `json:"author_icon,omitempty"`
+	Title       string   `json:"title,omitempty"`
+	Title_Link  string   `json:"title_link,omitempty"`
+	Text        string   `json:"text"`
+	Fields      []field  `json:"fields,omitempty"`
+	Image_URL   string   `json:"image_url,omitempty"`
+	Thumb_URL   string   `json:"thumb_url,omitempty"`
+	Footer      string   `json:"footer,omitempty"`
+	Mrkdwn_In   []string `json:"mrkdwn_in,omitempty"`
+}
+
+type slackMsg struct {
+	Text        string       `json:"text"`
+	Username    string       `json:"username,omitempty"`
+	Icon_Emoji  string       `json:"icon_emoji,omitempty"`
+	Channel     string       `json:"channel,omitempty"`
+	Link_Names  int          `json:"link_names,omitempty"`
+	Attachments []attachment `json:"attachments,omitempty"`
+	Blocks      []block      `json:"blocks,omitempty"`
+	Thread_TS   string       `json:"thread_ts,omitempty"`
+	// for a reply to an interaction's response_url, see interactive
+	Replace_Original bool   `json:"replace_original,omitempty"`
+	Response_Type    string `json:"response_type,omitempty"`
+}
+
+type slackOpts struct {
+	webhook string
+	// refreshButton adds a button that reruns the report, for
+	// -interactive-addr to take the presses
+	refreshButton bool
+	// order is how the rows are listed: score, name or config. Rank and
+	// color always follow the score.
+	order string
+	// pretty indents the message when it's printed rather than posted
+	pretty bool
+	// threadTS is the parent message to reply under in channel, which
+	// takes the web api rather than the webhook
+	channel, threadTS string
+	retries           int
+	// contentType and payloadForm are for webhook receivers that want
+	// something other than a raw json body, see webhookBody
+	contentType string
+	payloadForm bool
+	// localTimes adds each location's forecast day and fetch time, in
+	// its own time zone
+	localTimes bool
+	// streakAbove is -streak-above, for the streak line
+	streakAbove float64
+	// buckets groups the report by these comfort bands, without scores,
+	// when set
+	buckets bands
+	// tldr adds the one line ranking to the text
+	tldr bool
+	// summary is the -summary template for the message text
+	summary *template.Template
+	// card sizes the png leaderboard
+	card cardOpts
+	// out, when set, gets the message instead of the webhook
+	out io.Writer
+	// refs names the -compare locations, beaters is how many beat them
+	refs    string
+	beaters int
+	// goodEnough is -good-enough, the score a location has to beat to
+	// count
+	goodEnough float64
+	// closeCall is set when the winner isn't clear
+	closeCall string
+	// topMovers is -top-movers, how many risers and fallers since the
+	// last run to call out, and risers and fallers are them
+	topMovers       int
+	risers, fallers []mover
+	// compact puts every location in one attachment
+	compact bool
+	// stagger posts each attachment as its own message this far apart,
+	// see deliverStaggered
+	stagger time.Duration
+	// colorMin and colorMax fix the range colors are scaled over, when
+	// they're different, instead of using the day's best and worst
+	colorMin, colorMax float64
+	// colorPct scales the colors between percentiles rather than the
+	// best and worst, see percentileRange
+	colorPct float64
+	// excluded is how many locations failed -require, and failedBy how
+	// many failed each requirement first
+	excluded int
+	failedBy map[string]int
+	// steps replaces the gradient with three colors, see colorSteps
+	steps colorSteps
+	// numbers adds the raw forecast numbers as fields
+	numbers bool
+	// blocks posts Block Kit blocks instead of attachments
+	blocks bool
+	// explain adds a color legend and numbers the locations, so the
+	// ranking doesn't depend on seeing the colors
+	explain bool
+	// footer lines go under the last attachment
+	footer []string
+	// the value shown next to each location
+	key sortKey
+	// mention is who to notify (eg <!channel> or <@U123>) when a watched
+	// location scores below its threshold
+	mention string
+	watches watchFlag
+}
+
+// watchFlag is a repeatable Location=threshold flag
+type watchFlag map[string]float64
+
+func (w watchFlag) String() string {
+	var s []string
+	for k, v := range w {
+		s = append(s, fmt.Sprintf("%s=%g", k, v))
+	}
+	return strings.Join(s, ",")
+}
+
+func (w watchFlag) Set(s string) error {
+	k, v, err := parseLocFloat("watch", s)
+	if err != nil {
+		return err
+	}
+	w[k] = v
+	return nil
+}
+
+// parseLocFloat splits a Location=number flag value
+func parseLocFloat(flag, s string) (string, float64, error) {
+	i := strings.LastIndex(s, "=")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("%s %q should look like Location=number", flag, s)
+	}
+	v, err := strconv.ParseFloat(s[i+1:], 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("%s %q: %v", flag, s, err)
+	}
+	return s[:i], v, nil
+}
+
+// crossings are the locations that have come up to threshold since the
+// last run. A location that's new to the history doesn't count, there's
+// nothing to cross from.
+func crossings(prev map[string]float64, res []locScore, threshold float64) []string {
+	var lines []string
+	for _, v := range res {
+		p, ok := prev[v.Location]
+		if !ok || p >= threshold || v.Score < threshold {
+			continue
+		}
+		what := "nice"
+		if v.Label != "" {
+			what = strings.ToLower(v.Label)
+		}
+		lines = append(lines, fmt.Sprintf(":sunny: %s just became %s! (%s, up from %s)",
+			v.Location, what, formatScore(v.Score), formatScore(p)))
+	}
+	return lines
+}
+
+// sendText posts a plain text message, or prints it without a webhook
+func sendText(so slackOpts, s string) error {
+	sm := slackMsg{Text: s}
+	so.address(&sm)
+	buf, err := json.Marshal(sm)
+	if err != nil {
+		return err
+	}
+	return so.deliver(buf)
+}
+
+// address points sm at the -thread-ts thread, if there is one
+func (so slackOpts) address(sm *slackMsg) {
+	if so.threadTS != "" {
+		sm.Channel, sm.Thread_TS = so.channel, so.threadTS
+	}
+}
+
+// slack takes about a message a second from a webhook, -stagger can't
+// go faster than that
+const minStagger = time.Second
+
+// deliverStaggered is -stagger: the text goes first on its own, then
+// each attachment (a location's card, or the alerts) as a message of its
+// own, stagger apart
+func (so slackOpts) deliverStaggered(sm slackMsg) error {
+	first := sm
+	first.Attachments = nil
+	msgs := []slackMsg{first}
+	for _, a := range sm.Attachments {
+		msgs = append(msgs, slackMsg{Username: sm.Username, Icon_Emoji: sm.Icon_Emoji, Attachments: []attachment{a}})
+	}
+	wait := so.stagger
+	if wait < minStagger {
+		wait = minStagger
+	}
+	for i, m := range msgs {
+		if i > 0 {
+			if stopping() {
+				return fmt.Errorf("interrupted after posting %d of %d messages", i, len(msgs))
+			}
+			time.Sleep(wait)
+		}
+		so.address(&m)
+		buf, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if err := so.deliver(buf); err != nil {
+			return fmt.Errorf("message %d of %d: %w", i+1, len(msgs), err)
+		}
+	}
+	return nil
+}
+
+// webhookBody is the content type and body to post the json message
+// buf as. By default that's buf itself, with -webhook-payload-form it's
+// a form with buf in its payload field, which classic slack webhooks
+// and some other receivers want.
+func (so slackOpts) webhookBody(buf []byte) (string, []byte) {
+	ct := so.contentType
+	if so.payloadForm {
+		if ct == "" {
+			ct = "application/x-www-form-urlencoded"
+		}
+		buf = []byte(url.Values{"payload": {string(buf)}}.Encode())
+	}
+	if ct == "" {
+		ct = "application/json"
+	}
+	return ct, buf
+}
+
+// deliver posts a message to the webhook, or as a thread reply, or writes
+// it to -socket for a local relay to pass on, or prints it when there's
+// none of those
+func (so slackOpts) deliver(buf []byte) error {
+	if so.out == nil && so.threadTS != "" {
+		return callSlackAPI("chat.postMessage", "application/json; charset=utf-8", buf)
+	}
+	if so.out == nil && so.webhook != "" {
+		ct, body := so.webhookBody(buf)
+		return postWithRetry(so.webhook, ct, body, so.retries)
+	}
+	// only what people read gets indented
+	if so.pretty {
+		var b bytes.Buffer
+		if err := json.Indent(&b, buf, "", " "); err == nil {
+			buf = b.Bytes()
+		}
+	}
+	if so.out != nil {
+		_, err := fmt.Fprintln(so.out, string(buf))
+		return err
+	}
+	if !quiet {
+		fmt.Println(string(buf))
+	}
+	return nil
+}
+
+// alerts are the mention lines for watched locations below their threshold
+func (so slackOpts) alerts(res []locScore) []string {
+	var lines []string
+	for _, v := range res {
+		if t, ok := so.watches[v.Location]; ok && v.Score < t {
+			lines = append(lines, fmt.Sprintf("%s %s is down to %s (below %s)",
+				so.mention, v.Location, formatScore(v.Score), formatScore(t)))
+		}
+	}
+	return lines
+}
+
+func sendToSlack(so slackOpts, r *report) error {
+	res := r.Results
+	var sm slackMsg
+	// the text is what notifications and the channel preview show, the
+	// detail is in the attachments
+	h, err := so.headline(res)
+	if err != nil {
+		return err
+	}
+	sm.Text = h
+	if so.tldr {
+		sm.Text += "\n" + tldr(res)
+	}
+	//sm.Channel = "#general"
+	if so.refs != "" {
+		others := 0
+		for _, v := range res {
+			if !v.Reference {
+				others++
+			}
+		}
+		sm.Text += fmt.Sprintf("\n%d of %d beat %s.", so.beaters, others, so.refs)
+	}
+	if so.excluded > 0 {
+		sm.Text += fmt.Sprintf("\n%d more didn't meet the requirements.", so.excluded)
+	}
+	if so.goodEnough != 0 {
+		sm.Text += "\n" + goodEnoughLine(res, so.goodEnough)
+	}
+	if so.explain {
+		sm.Text += "\n" + so.legend(res)
+	}
+	if so.closeCall != "" {
+		sm.Text += "\n:balance_scale: It's " + so.closeCall + "."
+	}
+	if a := so.alerts(res); len(a) > 0 {
+		// mentions only notify from the text field, and link_names makes
+		// slack turn @names into real mentions
+		sm.Text = strings.Join(a, "\n") + "\n" + sm.Text
+		sm.Link_Names = 1
+	}
+	var footer []string
+	if so.buckets == nil {
+		footer = append(footer, spread(res))
+	}
+	if t := oldest(res); !t.IsZero() {
+		footer = append(footer, "Fetched "+ago(t, time.Now()))
+	}
+	footerText := strings.Join(append(footer, so.footer...), " | ")
+	switch {
+	case so.buckets != nil:
+		sm.Attachments = so.bucketAttachments(res)
+	case so.blocks:
+		sm.Blocks = so.blockKit(res, footerText)
+	case so.compact:
+		sm.Attachments = so.compactAttachments(res)
+	default:
+		sm.Attachments = so.attachments(res)
+	}
+	if len(sm.Attachments) > 0 {
+		sm.Attachments[len(sm.Attachments)-1].Footer = footerText
+	}
+	if m := moversAttachment(so.risers, so.fallers); m != nil {
+		sm.Attachments = append([]attachment{*m}, sm.Attachments...)
+	}
+	if d := alertDigest(res); d != nil {
+		// ahead of the ranking, it matters more than the comfort scores
+		sm.Attachments = append([]attachment{*d}, sm.Attachments...)
+	}
+	if so.stagger > 0 && len(sm.Blocks) == 0 && len(sm.Attachments) > 1 {
+		return so.deliverStaggered(sm)
+	}
+	if so.stagger > 0 {
+		warn("", nil, "only one attachment to post, -stagger has nothing to spread out")
+	}
+	so.address(&sm)
+	buf, err := json.Marshal(sm)
+	if err != nil {
+		return err
+	}
+	return so.deliver(buf)
+}
+
+// goodEnoughLine says how many locations beat the -good-enough bar
+func goodEnoughLine(res []locScore, bar float64) string {
+	n := 0
+	for _, v := range res {
+		if v.Score > bar {
+			n++
+		}
+	}
+	return fmt.Sprintf("%d of %d are better than good enough (%s).", n, len(res), formatNum(bar))
+}
+
+// below this many points apart the locations are basically the same
+const tinySpread = 20
+
+// spread says how far apart the best and worst scores are
+func spread(res []locScore) string {
+	max, min := res[0].Score, res[0].Score
+	for _, v := range res {
+		max = math.Max(max, v.Score)
+		min = math.Min(min, v.Score)
+	}
+	s := fmt.Sprintf("Best beats worst by %s points", formatNum(max-min))
+	if max-min < tinySpread {
+		s += ", it's about the same everywhere today"
+	}
+	return s
+}
+
+// color is v's place on the red (worst) to green (best) gradient, or
+// the color its -format-rule gave it
+func (so slackOpts) color(v locScore, res []locScore) string {
+	c := v.style.color
+	if c == "" {
+		c = so.gradient(v, res)
+	}
+	if v.style.dim {
+		c = dimmed(c)
+	}
+	return c
+}
+
+func (so slackOpts) gradient(v locScore, res []locScore) string {
+	if so.steps.set {
+		c := so.steps.color(so.key.value(v))
+		return c.String()
+	}
+	best := so.key.value(res[0])
+	worst := so.key.value(res[len(res)-1])
+	if so.colorPct > 0 {
+		worst, best = so.percentileRange(res)
+	}
+	if so.colorMin != so.colorMax {
+		// a fixed range keeps colors comparable from one day to the next
+		best, worst = so.colorMax, so.colorMin
+		if !so.key.desc {
+			best, worst = worst, best
+		}
+	}
+	return getValueBetweenTwoFixedColors(normalize(so.key.value(v), worst, best))
+}
+
+// percentileRange is the worst and best ends of the color scale for
+// -color-percentile p: the pth and (100-p)th percentiles, so one runaway
+// location doesn't squash everyone else into one color. Past them
+// normalize clamps to the end colors.
+func (so slackOpts) percentileRange(res []locScore) (worst, best float64) {
+	vs := make([]float64, len(res))
+	for i, v := range res {
+		vs[i] = so.key.value(v)
+	}
+	sort.Float64s(vs)
+	lo, hi := percentile(vs, so.colorPct), percentile(vs, 100-so.colorPct)
+	if so.key.desc {
+		return lo, hi
+	}
+	return hi, lo
+}
+
+// percentile interpolates the pth percentile of the sorted vs
+func percentile(vs []float64, p float64) float64 {
+	if len(vs) == 0 {
+		return 0
+	}
+	r := p / 100 * float64(len(vs)-1)
+	i := int(r)
+	if i >= len(vs)-1 {
+		return vs[len(vs)-1]
+	}
+	return vs[i] + (r-float64(i))*(vs[i+1]-vs[i])
+}
+
+// ordinal is n as 10th, 90th and so on
+func ordinal(n float64) string {
+	i := int(math.Round(n))
+	suffix := "th"
+	if i%100 < 11 || i%100 > 13 {
+		switch i % 10 {
+		case 1:
+			suffix = "st"
+		case 2:
+			suffix = "nd"
+		case 3:
+			suffix = "rd"
+		}
+	}
+	return strconv.Itoa(i) + suffix
+}
+
+// factorLine is the shares written compactly, eg Temp 85% · Sun 70% · Dry 90%
+func factorLine(s *factorShares) string {
+	return fmt.Sprintf("Temp %s · Sun %s · Dry %s · Humidity %s",
+		formatPct(s.Temp), formatPct(s.Sun), formatPct(s.Dry), formatPct(s.Humidity))
+}
+
+// metricFields are the numbers behind a location's score, laid out two
+// to a row
+func metricFields(v locScore) []field {
+	deg := func(t float64) string { return formatNum(t) + "°" }
+	return []field{
+		{Title: "High", Value: deg(v.TemperatureMax), Short: true},
+		{Title: "Low", Value: deg(v.TemperatureMin), Short: true},
+		{Title: "Humidity", Value: formatPct(v.Humidity), Short: true},
+		{Title: "Clouds", Value: formatPct(v.CloudCover), Short: true},
+		{Title: "Precip", Value: formatPct(v.PrecipProbability), Short: true},
+		{Title: "Wind", Value: fmt.Sprintf("%s %s %s", formatNum(v.WindSpeed), speedUnit(v.Units), compass(v.WindBearing)), Short: true},
+	}
+}
+
+// legend explains the colors and what they're scaled over
+func (so slackOpts) legend(res []locScore) string {
+	if so.steps.set {
+		t := strings.ToLower(so.key.title)
+		return fmt.Sprintf("Colors are %s for %s %s or better, %s down to %s and %s past that.", bestColor.Name(),
+			t, so.key.format(so.steps.good), warnColor.Name(), so.key.format(so.steps.bad), worstColor.Name())
+	}
+	if so.colorMin != so.colorMax {
+		best, worst := so.colorMax, so.colorMin
+		if !so.key.desc {
+			best, worst = worst, best
+		}
+		return fmt.Sprintf("Colors run from %s at %s %s to %s at %s.", bestColor.Name(),
+			strings.ToLower(so.key.title), so.key.format(best), worstColor.Name(), so.key.format(worst))
+	}
+	if so.colorPct > 0 {
+		worst, best := so.percentileRange(res)
+		return fmt.Sprintf("Colors run from %s at %s %s (the %s percentile) or better to %s at %s (the %s) or worse.", bestColor.Name(),
+			strings.ToLower(so.key.title), so.key.format(best), ordinal(100-so.colorPct), worstColor.Name(), so.key.format(worst), ordinal(so.colorPct))
+	}
+	return fmt.Sprintf("Colors run from %s for the best %s (%s) to %s for the worst (%s).", bestColor.Name(),
+		strings.ToLower(so.key.title), so.key.format(so.key.value(res[0])),
+		worstColor.Name(), so.key.format(so.key.value(res[len(res)-1])))
+}
+
+// normalize maps v onto 0 (worst) to 1 (best). It doesn't care about the
+// sign of the scores or which way the key sorts, and when everyone ties
+// they're all best rather than NaN.
+func normalize(v, worst, best float64) float64 {
+	if best == worst || math.IsNaN(v) {
+		return 1
+	}
+	n := (v - worst) / (best - worst)
+	return math.Max(0, math.Min(1, n))
+}
+
+// details is a location's summary and the notes under it, the same in
+// attachments and Block Kit so a refreshed message loses nothing
+func (so slackOpts) details(v locScore) string {
+	s := fmt.Sprintf("%s (%s chance of sunshine)", v.summary(), formatPct(v.Sunshine/100))
+	if len(v.Trend) > 0 {
+		s += "\nNext days: " + sparkline(v.Trend)
+	}
+	if n := v.lastYearNote(); n != "" {
+		s += "\n" + n
+	}
+	if v.Factors != nil {
+		s += "\n" + factorLine(v.Factors)
+	}
+	if n := streakNote(v, so.streakAbove); n != "" {
+		s += "\n" + n
+	}
+	if so.localTimes {
+		s += "\n" + localTime(v)
+	}
+	if v.Distance != nil {
+		s += "\n" + distanceNote(v)
+	}
+	if len(v.Aliases) > 0 {
+		s += "\nAlso " + strings.Join(v.Aliases, ", ")
+	}
+	if v.RawScore != nil && formatScore(*v.RawScore) != formatScore(v.Score) {
+		s += "\n" + smoothNote(v)
+	}
+	return s
+}
+
+// marks are the warnings and -compare marks shown after a location
+func (so slackOpts) marks(v locScore) []string {
+	var ms []string
+	for _, n := range v.Notes {
+		ms = append(ms, ":warning: "+n)
+	}
+	if v.Reference {
+		ms = append(ms, ":round_pushpin: Reference")
+	}
+	if v.BeatsReference {
+		ms = append(ms, ":white_check_mark: Beats "+so.refs)
+	}
+	return ms
+}
+
+// attachments is one attachment per location
+func (so slackOpts) attachments(res []locScore) []attachment {
+	rank := make(map[string]int)
+	for i, v := range res {
+		rank[v.Location] = i + 1
+	}
+	var as []attachment
+	first := true
+	for _, g := range groupByRegion(so.display(res)) {
+		if g.region != "" {
+			as = append(as, attachment{Title: g.region})
+		}
+		for _, v := range g.scores {
+			f := []field{
+				{Value: v.displayName(), Short: true},
+				{Value: so.key.format(so.key.value(v)), Short: true},
+				{Value: so.details(v)},
+			}
+			if so.numbers {
+				f = append(f, metricFields(v)...)
+			}
+			for _, m := range so.marks(v) {
+				f = append(f, field{Value: m})
+			}
+			if v.Here {
+				f[0].Value = ":house: " + f[0].Value
+			}
+			if so.explain || so.order != "score" {
+				// out of score order the rank has to be spelled out
+				f[0].Value = fmt.Sprintf("%d. %s", rank[v.Location], f[0].Value)
+			}
+			if first {
+				f[0].Title = "Location"
+				f[1].Title = so.key.title
+				first = false
+			}
+			as = append(as, attachment{
+				Fields:    f,
+				Color:     so.color(v, res),
+				Thumb_URL: emoji(v.Condition),
+			})
+		}
+	}
+	return as
+}
+
+// compactAttachments puts the whole ranking in a single attachment as a
+// code block table, colored for the winner.
+func (so slackOpts) compactAttachments(res []locScore) []attachment {
+	return []attachment{{
+		Fallback:  fmt.Sprintf("%s wins with %s", res[0].Location, so.key.format(so.key.value(res[0]))),
+		Color:     so.color(res[0], res),
+		Text:      "```\n" + so.table(res) + "```",
+		Mrkdwn_In: []string{"text"},
+	}}
+}
+
+// table is the ranking as plain text, a line per location
+func (so slackOpts) table(res []locScore) string {
+	width := len("Location")
+	for _, v := range res {
+		if n := len(v.displayName()); n > width {
+			width = n
+		}
+	}
+	rank := make(map[string]int)
+	for i, v := range res {
+		rank[v.Location] = i + 1
+	}
+	var b strings.Builder
+	for _, g := range groupByRegion(so.display(res)) {
+		if g.region != "" {
+			fmt.Fprintf(&b, "%s\n", g.region)
+		}
+		for _, v := range g.scores {
+			fmt.Fprintf(&b, "%2d. %-*s %8s  ", rank[v.Location], width, v.displayName(), so.key.format(so.key.value(v)))
+			if len(v.Trend) > 0 {
+				fmt.Fprintf(&b, "%s  ", sparkline(v.Trend))
+			}
+			if so.refs != "" {
+				fmt.Fprintf(&b, "%-5s  ", refMark(v))
+			}
+			fmt.Fprintf(&b, "%s\n", v.summary())
+		}
+	}
+	return b.String()
+}
+
+type regionGroup struct {
+	region string
+	scores []locScore
+}
+
+// groupByRegion splits the sorted results into regions, keeping the
+// score order inside each region. Regions are ordered by their best
+// location. Without any regions everything ends up in one unnamed group.
+func groupByRegion(res []locScore) []regionGroup {
+	hasRegions := false
+	for _, v := range res {
+		if v.Region != "" {
+			hasRegions = true
+			break
+		}
+	}
+	if !hasRegions {
+		return []regionGroup{{scores: res}}
+	}
+	var groups []regionGroup
+	idx := make(map[string]int)
+	for _, v := range res {
+		r := v.Region
+		if r == "" {
+			r = "Other"
+		}
+		i, ok := idx[r]
+		if !ok {
+			i = len(groups)
+			idx[r] = i
+			groups = append(groups, regionGroup{region: r})
+		}
+		groups[i].scores = append(groups[i].scores, v)
+	}
+	return groups
+}
+
+// display is res, ranked by score, in the -display-order
+func (so slackOpts) display(res []locScore) []locScore {
+	if so.order == "score" || so.order == "" {
+		return res
+	}
+	d := append([]locScore(nil), res...)
+	pos := make(map[string]int)
+	if so.order == "config" {
+		for i, k := range configOrder {
+			pos[k] = i + 1
+		}
+	}
+	sort.SliceStable(d, func(i, j int) bool {
+		a, b := d[i].Location, d[j].Location
+		// locations not from a file, the built in ones, go last
+		if pa, pb := pos[a], pos[b]; pa != pb {
+			return pb == 0 || (pa != 0 && pa < pb)
+		}
+		return a < b
+	})
+	return d
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestNormalize is the color scale with negative scores: worst red and
+// best green whatever the sign, the key's direction or a tie
+func TestNormalize(t *testing.T) {
+	for _, c := range []struct {
+		v, worst, best, want float64
+	}{
+		{-200, -200, -10, 0},
+		{-10, -200, -10, 1},
+		{-105, -200, -10, .5},
+		// across zero
+		{0, -100, 100, .5},
+		{-100, -100, 100, 0},
+		// a key where lower is better, like precip
+		{.9, .9, .1, 0},
+		{.1, .9, .1, 1},
+		// past the ends, with a fixed -color-min/-color-max
+		{-500, -200, -10, 0},
+		{50, -200, -10, 1},
+		// everyone tied, or no score
+		{-7, -7, -7, 1},
+		{math.NaN(), -200, -10, 1},
+	} {
+		if got := normalize(c.v, c.worst, c.best); math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("normalize(%g, %g, %g) = %g, want %g", c.v, c.worst, c.best, got, c.want)
+		}
+	}
+	// and the colors a report with only negative scores gets
+	res := []locScore{{Location: "a", Score: -10}, {Location: "b", Score: -105}, {Location: "c", Score: -200}}
+	so := slackOpts{key: sortKeys["score"]}
+	for i, want := range []string{"#00ff00", "#808000", "#ff0000"} {
+		if got := so.color(res[i], res); got != want {
+			t.Errorf("%s (%g) is %s, want %s", res[i].Location, res[i].Score, got, want)
+		}
+	}
+	// a fixed -color-min and -color-max, past which it's the end colors
+	fixed := slackOpts{key: sortKeys["score"], colorMin: 100, colorMax: 300}
+	for score, want := range map[float64]string{300: "#00ff00", 200: "#808000", 100: "#ff0000", 500: "#00ff00", 0: "#ff0000"} {
+		v := locScore{Location: "a", Score: score}
+		if got := fixed.color(v, res); got != want {
+			t.Errorf("score %g between -color-min 100 and -color-max 300 is %s, want %s", score, got, want)
+		}
+	}
+	// for precip lower is better, so -color-min is the green end
+	precip := slackOpts{key: sortKeys["precip"], colorMin: .1, colorMax: .5}
+	for p, want := range map[float64]string{.1: "#00ff00", .3: "#808000", .5: "#ff0000", 0: "#00ff00", 1: "#ff0000"} {
+		v := locScore{Location: "a", PrecipProbability: p}
+		if got := precip.color(v, res); got != want {
+			t.Errorf("precip %g between -color-min .1 and -color-max .5 is %s, want %s", p, got, want)
+		}
+	}
+}
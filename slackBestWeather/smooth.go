@@ -0,0 +1,18 @@
+package main
+
+import "fmt"
+
+// smoothed is -smooth: score blended with the location's score in the
+// last run, an exponential moving average weighting this run by weight.
+// The history keeps the blended score, so each run carries all the
+// earlier ones with it and one jumpy forecast only moves a location part
+// of the way.
+func smoothed(score, prev, weight float64) float64 {
+	return weight*score + (1-weight)*prev
+}
+
+// smoothNote is the line under a smoothed location's summary, eg
+// "Smoothed, 512 this run"
+func smoothNote(v locScore) string {
+	return fmt.Sprintf("Smoothed, %s this run", formatScore(*v.RawScore))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// slackAPI is the slack web api, which file uploads need rather than a
+// webhook
+var slackAPI = "https://slack.com/api"
+
+// postSnippet uploads the ranking table as a text snippet to channel,
+// which reads better than attachments once there are a lot of locations.
+// The bot token comes from $SLACK_TOKEN and needs the files:write scope.
+func postSnippet(so slackOpts, channel string, r *report) error {
+	res := r.Results
+	form := url.Values{
+		"channels":        {channel},
+		"content":         {so.table(res)},
+		"filetype":        {"text"},
+		"filename":        {"best-weather.txt"},
+		"title":           {"Best weather competition"},
+		"initial_comment": {fmt.Sprintf("%s wins with %s", res[0].Location, so.key.format(so.key.value(res[0])))},
+	}
+	return callSlackAPI("files.upload", "application/x-www-form-urlencoded", []byte(form.Encode()))
+}
+
+// how many times a rate limited web api call is tried again
+const slackAPIRetries = 3
+
+// callSlackAPI posts body to a slack web api method with the bot token
+// from $SLACK_TOKEN. Like the webhook it waits out a 429's Retry-After.
+func callSlackAPI(method, contentType string, body []byte) error {
+	token := os.Getenv("SLACK_TOKEN")
+	if token == "" {
+		return fmt.Errorf("SLACK_TOKEN is not set")
+	}
+	addSecret(token)
+	var resp *http.Response
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest("POST", slackAPI+"/"+method, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+token)
+		postThrottle.wait(req.URL.Host)
+		resp, err = httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || attempt >= slackAPIRetries {
+			break
+		}
+		resp.Body.Close()
+		wait, ok := retryAfter(resp.Header.Get("Retry-After"))
+		if !ok {
+			wait = retryBaseDelay << attempt
+		}
+		vlog("slack %s: %s, retrying in %s", method, resp.Status, wait)
+		postThrottle.backoff(wait)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("bad http response %s", resp.Status)
+	}
+	// slack says 200 either way, the verdict is in the body
+	var r struct {
+		OK    bool
+		Error string
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return err
+	}
+	if !r.OK {
+		return fmt.Errorf("slack %s: %s", method, r.Error)
+	}
+	return nil
+}
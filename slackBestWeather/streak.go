@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// streaks are how many days in a row, up to and including today's run,
+// each location in res has scored at least threshold, going by the
+// history. A day's last run is the one that counts. A day with no run at
+// all breaks a streak unless ignoreGaps, which only looks at the days
+// that have runs. A run the location is missing from always breaks it.
+func streaks(lines []jsonlLine, res []locScore, now time.Time, threshold float64, ignoreGaps bool) map[string]int {
+	const layout = "2006-01-02"
+	scores := make(map[string]map[string]float64)
+	runDays := make(map[string]bool)
+	add := func(name, date string, score float64) {
+		if scores[name] == nil {
+			scores[name] = make(map[string]float64)
+		}
+		scores[name][date] = score
+		runDays[date] = true
+	}
+	// the history is in run order, so later runs in a day win
+	for _, l := range lines {
+		add(l.Location, l.Time.Local().Format(layout), l.Score)
+	}
+	today := now.Local().Format(layout)
+	for _, v := range res {
+		add(v.Location, today, v.Score)
+	}
+	var days []string
+	if ignoreGaps {
+		for d := range runDays {
+			days = append(days, d)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(days)))
+	} else {
+		// every calendar day back to the first run
+		first := today
+		for d := range runDays {
+			if d < first {
+				first = d
+			}
+		}
+		for t := now.Local(); t.Format(layout) >= first; t = t.AddDate(0, 0, -1) {
+			days = append(days, t.Format(layout))
+		}
+	}
+	n := make(map[string]int)
+	for _, v := range res {
+		for _, d := range days {
+			s, ok := scores[v.Location][d]
+			if !ok || s < threshold {
+				break
+			}
+			n[v.Location]++
+		}
+	}
+	return n
+}
+
+// streakNote is v's streak for the slack message, empty under two days
+func streakNote(v locScore, threshold float64) string {
+	if v.Streak < 2 {
+		return ""
+	}
+	return fmt.Sprintf(":fire: %d days in a row scoring %s or more", v.Streak, formatNum(threshold))
+}
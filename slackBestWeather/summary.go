@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"text/template"
+)
+
+// defaultSummary is the -summary template, the line notifications show
+const defaultSummary = ":trophy: {{.Winner}} wins {{.Day}}!"
+
+// summaryData is what a -summary template can use
+type summaryData struct {
+	// Winner is the top location and Score its formatted -sort-by value
+	Winner, Score, Label string
+	// Day is "today", or the winning day in -mode peak
+	Day string
+	// Runner is the second place location, if there is one
+	Runner string
+}
+
+// parseSummary parses a -summary template and tries it out, so a typo
+// fails before anything is fetched
+func parseSummary(s string) (*template.Template, error) {
+	t, err := template.New("summary").Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.Execute(io.Discard, summaryData{}); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// headline fills in the summary template for res, which is sorted
+func (so slackOpts) headline(res []locScore) (string, error) {
+	w := res[0]
+	d := summaryData{Winner: w.Location, Score: so.key.format(so.key.value(w)), Label: w.Label, Day: "today"}
+	if w.PeakDay != nil {
+		d.Day = "on " + w.PeakDay.Format("Monday Jan 2")
+	}
+	if len(res) > 1 {
+		d.Runner = res[1].Location
+	}
+	var b strings.Builder
+	if err := so.summary.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// tldrMax is about as much text as a notification shows
+const tldrMax = 150
+
+// tldr is the whole ranking on one line, eg
+// "Anna Maria :sunny: > Greenville :partly_sunny: > Islip :rain_cloud:",
+// cut short with … past tldrMax characters. The > is written &gt;
+// because slack treats a bare one as markup.
+func tldr(res []locScore) string {
+	var b strings.Builder
+	for i, v := range res {
+		part := v.Location + " " + emoji(v.Condition)
+		if i > 0 {
+			part = " &gt; " + part
+		}
+		if b.Len()+len(part) > tldrMax {
+			b.WriteString(" &gt; …")
+			break
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// throttle spaces out posts to chat platforms, which rate limit. It's
+// shared by every output and message, so a staggered report and a
+// snippet upload in the same run take turns rather than both hitting
+// the limit.
+type throttle struct {
+	mu sync.Mutex
+	// every is -post-interval, the least time between posts
+	every time.Duration
+	// next is the soonest the next post can go
+	next time.Time
+}
+
+var postThrottle = &throttle{}
+
+// wait blocks until it's host's turn to post
+func (t *throttle) wait(host string) {
+	t.mu.Lock()
+	now := time.Now()
+	slot := now
+	if t.next.After(now) {
+		slot = t.next
+	}
+	t.next = slot.Add(t.every)
+	t.mu.Unlock()
+	if d := slot.Sub(now); d > 0 {
+		vlog("throttling the post to %s for %s", host, d.Round(time.Millisecond))
+		time.Sleep(d)
+	}
+}
+
+// backoff holds every post off for d, when a platform says to with a 429
+func (t *throttle) backoff(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if until := time.Now().Add(d); until.After(t.next) {
+		t.next = until
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// dailyQuota is -daily-quota, the calls a day the provider allows when
+// it only says how many have been made (forecast.io's X-Forecast-API-Calls)
+var dailyQuota = 1000
+
+// warn once this share of the quota or less is left
+const quotaWarnShare = .1
+
+// usage is what a provider's response headers say about its quota,
+// -1 for what they don't say
+type usage struct {
+	calls, limit, remaining int
+	warned                  bool
+}
+
+func (u usage) seen() bool { return u.calls >= 0 || u.remaining >= 0 }
+
+// noteUsage reads the quota headers off a response from prov (a cache
+// key's provider), keeping the newest. forecast.io counts the day's
+// calls in X-Forecast-API-Calls, others send the common
+// X-RateLimit-Limit and X-RateLimit-Remaining.
+func noteUsage(prov string, h http.Header) {
+	name, _, _ := strings.Cut(prov, "-")
+	pl, ok := providerLimits[name]
+	if !ok {
+		return
+	}
+	num := func(k string) int {
+		n, err := strconv.Atoi(strings.TrimSpace(h.Get(k)))
+		if err != nil || n < 0 {
+			return -1
+		}
+		return n
+	}
+	calls, limit, remaining := num("X-Forecast-API-Calls"), num("X-RateLimit-Limit"), num("X-RateLimit-Remaining")
+	if calls < 0 && remaining < 0 {
+		return
+	}
+	if calls >= 0 && limit < 0 {
+		limit = dailyQuota
+	}
+	if remaining < 0 && limit >= 0 {
+		remaining = limit - calls
+		if remaining < 0 {
+			remaining = 0
+		}
+	}
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	warned := pl.usage.warned
+	pl.usage = usage{calls: calls, limit: limit, remaining: remaining, warned: warned}
+	if !warned && limit > 0 && float64(remaining) <= float64(limit)*quotaWarnShare {
+		pl.usage.warned = true
+		warn("", nil, fmt.Sprintf("%s has only %d of %d API calls left", name, remaining, limit))
+	}
+}
+
+// usageNotes describe each provider's quota as its last response had it,
+// eg "forecastio: 123 API calls made today, 877 of 1000 left"
+func usageNotes() []string {
+	var s []string
+	for _, name := range sortedLimitNames() {
+		pl := providerLimits[name]
+		pl.mu.Lock()
+		u := pl.usage
+		pl.mu.Unlock()
+		if !u.seen() {
+			continue
+		}
+		var parts []string
+		if u.calls >= 0 {
+			parts = append(parts, fmt.Sprintf("%d API calls made today", u.calls))
+		}
+		if u.remaining >= 0 && u.limit >= 0 {
+			parts = append(parts, fmt.Sprintf("%d of %d left", u.remaining, u.limit))
+		} else if u.remaining >= 0 {
+			parts = append(parts, fmt.Sprintf("%d left", u.remaining))
+		}
+		s = append(s, name+": "+strings.Join(parts, ", "))
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// watchLeader is -watch-leader, a daemon that runs a report every
+// interval and only posts when a new location takes the top spot. Like
+// the dashboard each report is a child run with -format report. The
+// first report announces the leader, so a restart says it once more.
+func watchLeader(so slackOpts, every time.Duration) {
+	args := reportArgs(os.Args[1:])
+	var leader string
+	for {
+		if r, err := childReport(args); err != nil {
+			warn("", err, "watch report failed, keeping the leader")
+		} else if len(r.Results) == 0 {
+			vlog("nowhere in the report, keeping the leader")
+		} else if top := so.leader(r.Results); top.Location != leader {
+			if err := sendText(so, leaderChange(so, top, leader)); err != nil {
+				warn("", err, "posting the new leader failed")
+			} else {
+				leader = top.Location
+			}
+		} else {
+			vlog("%s still leads", leader)
+		}
+		next := time.Now().Add(every)
+		slog.Info("next watch report at " + next.Format("Mon Jan 2 15:04 MST"))
+		for time.Now().Before(next) {
+			if stopping() {
+				return
+			}
+			time.Sleep(min(time.Until(next), time.Second))
+		}
+	}
+}
+
+// childReport runs a report as a child with args and reads it back
+func childReport(args []string) (*report, error) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var r report
+	if err := json.Unmarshal(out, &r); err != nil {
+		return nil, fmt.Errorf("reading the report: %v", err)
+	}
+	return &r, nil
+}
+
+// leader is the best of res by the -sort-by key, the results can be
+// in another -display-order
+func (so slackOpts) leader(res []locScore) locScore {
+	best := res[0]
+	for _, v := range res[1:] {
+		a, b := so.key.value(v), so.key.value(best)
+		if so.key.desc && a > b || !so.key.desc && a < b {
+			best = v
+		}
+	}
+	return best
+}
+
+// leaderChange is the post for v taking the top spot from was, which
+// is "" for the first report
+func leaderChange(so slackOpts, v locScore, was string) string {
+	s := fmt.Sprintf("%s (%s %s)", v.Location, so.key.title, so.key.format(so.key.value(v)))
+	if was == "" {
+		return ":crown: Leader: " + s
+	}
+	return fmt.Sprintf(":crown: New leader: %s, taking over from %s", s, was)
+}
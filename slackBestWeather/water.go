@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/reds/cmds/slackBestWeather/weather"
+)
+
+// defaultWaterSource is open-meteo's marine api, which has sea surface
+// temperatures for anywhere on the coast. {lat}, {lng} and {unit}
+// (fahrenheit or celsius) are filled in.
+const defaultWaterSource = "https://marine-api.open-meteo.com/v1/marine?latitude={lat}&longitude={lng}" +
+	"&hourly=sea_surface_temperature&timeformat=unixtime&temperature_unit={unit}"
+
+// addWaterTemps fills in WaterTemp for each of f's days from src, an
+// open-meteo marine style url, averaging the hours of each day
+func addWaterTemps(f *forecast, l loc, src string, fo fetchOpts) error {
+	l = fo.round(l)
+	unit := "fahrenheit"
+	if weather.Celsius(f.Units) {
+		unit = "celsius"
+	}
+	u := strings.NewReplacer("{lat}", fmt.Sprintf("%f", l.lat), "{lng}", fmt.Sprintf("%f", l.lng), "{unit}", unit).Replace(src)
+	buf, _, err := get(u, cacheKey("water", l, f.Units, time.Now()), fo.useCache)
+	if err != nil {
+		return err
+	}
+	var r struct {
+		Hourly struct {
+			Time []int64
+			// null over land
+			Sea_Surface_Temperature []*float64
+		}
+	}
+	if err := json.Unmarshal(buf, &r); err != nil {
+		return err
+	}
+	tz, err := time.LoadLocation(f.Timezone)
+	if err != nil {
+		tz = time.UTC
+	}
+	sum := make(map[string]float64)
+	n := make(map[string]int)
+	for i, t := range r.Hourly.Time {
+		if i >= len(r.Hourly.Sea_Surface_Temperature) || r.Hourly.Sea_Surface_Temperature[i] == nil {
+			continue
+		}
+		k := time.Unix(t, 0).In(tz).Format("2006-01-02")
+		sum[k] += *r.Hourly.Sea_Surface_Temperature[i]
+		n[k]++
+	}
+	if len(n) == 0 {
+		return fmt.Errorf("%w, no water temperatures (inland?)", ErrNoData)
+	}
+	for i := range f.Daily {
+		d := &f.Daily[i]
+		if k := d.Time.In(tz).Format("2006-01-02"); n[k] > 0 {
+			v := sum[k] / float64(n[k])
+			d.WaterTemp = &v
+		}
+	}
+	return nil
+}
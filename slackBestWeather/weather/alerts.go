@@ -0,0 +1,31 @@
+package weather
+
+import (
+	"strings"
+	"time"
+)
+
+// Alert is a severe weather alert issued for the location by a
+// government agency, as forecast.io passes them on
+type Alert struct {
+	Title    string    `json:"title"`
+	Regions  []string  `json:"regions,omitempty"`
+	Severity string    `json:"severity"`
+	Time     time.Time `json:"time"`
+	Expires  time.Time `json:"expires,omitempty"`
+	URI      string    `json:"uri,omitempty"`
+}
+
+// SeverityRank orders the severities, warning first. Anything unknown
+// goes after advisory.
+func (a Alert) SeverityRank() int {
+	switch strings.ToLower(a.Severity) {
+	case "warning":
+		return 0
+	case "watch":
+		return 1
+	case "advisory":
+		return 2
+	}
+	return 3
+}
@@ -0,0 +1,54 @@
+package weather
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RGB is a color, as a flag it's a hex string like #ff0000
+type RGB struct {
+	R, G, B float64
+}
+
+func (c *RGB) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", int(c.R), int(c.G), int(c.B))
+}
+
+func (c *RGB) Set(s string) error {
+	h := strings.TrimPrefix(s, "#")
+	if len(h) != 6 {
+		return fmt.Errorf("color %q should be hex like #00ff00", s)
+	}
+	n, err := strconv.ParseUint(h, 16, 32)
+	if err != nil {
+		return fmt.Errorf("color %q should be hex like #00ff00", s)
+	}
+	c.R, c.G, c.B = float64(n>>16), float64(n>>8&0xff), float64(n&0xff)
+	return nil
+}
+
+// Name is what to call the color in a legend
+func (c RGB) Name() string {
+	switch c {
+	case RGB{255, 0, 0}:
+		return "red"
+	case RGB{0, 255, 0}:
+		return "green"
+	case RGB{0, 0, 255}:
+		return "blue"
+	case RGB{255, 204, 0}:
+		return "yellow"
+	}
+	return c.String()
+}
+
+// Between is the hex color value (0-1) of the way from a to b
+func Between(a, b RGB, value float64) string {
+	// round rather than truncate so the channels aren't biased down
+	red := int(math.Round((b.R-a.R)*value + a.R))
+	green := int(math.Round((b.G-a.G)*value + a.G))
+	blue := int(math.Round((b.B-a.B)*value + a.B))
+	return fmt.Sprintf("#%02x%02x%02x", red, green, blue)
+}
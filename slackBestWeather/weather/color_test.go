@@ -0,0 +1,56 @@
+package weather
+
+import "testing"
+
+// TestBetween is the gradient at and around the hex values where
+// truncating rather than rounding would go wrong
+func TestBetween(t *testing.T) {
+	black, white := RGB{}, RGB{255, 255, 255}
+	red, green := RGB{R: 255}, RGB{G: 255}
+	for _, c := range []struct {
+		a, b  RGB
+		value float64
+		want  string
+	}{
+		{black, white, 0, "#000000"},
+		{black, white, 1, "#ffffff"},
+		{black, white, .5, "#808080"},
+		// half a step rounds up, just under it doesn't
+		{black, white, .5 / 255, "#010101"},
+		{black, white, .499 / 255, "#000000"},
+		// nearly white is white, truncating made it #fefefe
+		{black, white, 1 - .4/255, "#ffffff"},
+		{white, black, .4 / 255, "#ffffff"},
+		{red, green, 0, "#ff0000"},
+		{red, green, 1, "#00ff00"},
+		{red, green, .5, "#808000"},
+		{red, green, .25, "#bf4000"},
+	} {
+		if got := Between(c.a, c.b, c.value); got != c.want {
+			t.Errorf("Between(%s, %s, %g) = %s, want %s", c.a.String(), c.b.String(), c.value, got, c.want)
+		}
+	}
+}
+
+// TestRGBSet is the color flag's hex, which should read back the same
+func TestRGBSet(t *testing.T) {
+	for in, want := range map[string]string{
+		"#000000": "#000000",
+		"#ffffff": "#ffffff",
+		"#ABCDEF": "#abcdef",
+		"0a0b0c":  "#0a0b0c",
+	} {
+		var c RGB
+		if err := c.Set(in); err != nil {
+			t.Errorf("%s: %v", in, err)
+		} else if got := c.String(); got != want {
+			t.Errorf("%s reads back as %s, want %s", in, got, want)
+		}
+	}
+	for _, in := range []string{"", "#fff", "#1234567", "#gggggg", "#-12345"} {
+		var c RGB
+		if err := c.Set(in); err == nil {
+			t.Errorf("%q should be an error, got %s", in, c.String())
+		}
+	}
+}
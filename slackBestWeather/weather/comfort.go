@@ -0,0 +1,97 @@
+package weather
+
+import "math"
+
+// ApparentTemp is how warm t (fahrenheit) feels given the relative
+// humidity (0-1) and wind speed (mph). Hot days use the NWS heat index,
+// cold windy days the NWS wind chill, everything in between is just t.
+func ApparentTemp(t, humidity, wind float64) float64 {
+	switch {
+	case t >= 80:
+		return heatIndex(t, humidity)
+	case t <= 50 && wind >= 3:
+		return windChill(t, wind)
+	}
+	return t
+}
+
+// heatIndex is the NWS Rothfusz regression, with its low and high
+// humidity adjustments.
+// https://www.wpc.ncep.noaa.gov/html/heatindex_equation.shtml
+func heatIndex(t, humidity float64) float64 {
+	rh := humidity * 100
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 < 80 {
+		return hi
+	}
+	hi = -42.379 + 2.04901523*t + 10.14333127*rh - .22475541*t*rh -
+		.00683783*t*t - .05481717*rh*rh + .00122874*t*t*rh +
+		.00085282*t*rh*rh - .00000199*t*t*rh*rh
+	switch {
+	case rh < 13 && t >= 80 && t <= 112:
+		hi -= (13 - rh) / 4 * math.Sqrt((17-math.Abs(t-95))/17)
+	case rh > 85 && t >= 80 && t <= 87:
+		hi += (rh - 85) / 10 * (87 - t) / 5
+	}
+	return hi
+}
+
+// windChill is the 2001 NWS formula, valid for t <= 50F and wind >= 3mph
+func windChill(t, wind float64) float64 {
+	v := math.Pow(wind, 0.16)
+	return 35.74 + 0.6215*t - 35.75*v + 0.4275*t*v
+}
+
+// ComfortModels are the -comfort-model choices. Custom is the score's
+// own falloff from the perfect temps, the others are published heat
+// stress indices.
+var ComfortModels = []string{"custom", "thi", "humidex", "wbgt"}
+
+// THI is Thom's temperature-humidity (discomfort) index, celsius in and
+// out with humidity 0-1. Under 21 nobody's uncomfortable, by 32 it's a
+// medical emergency.
+func THI(t, humidity float64) float64 {
+	return t - 0.55*(1-humidity)*(t-14.5)
+}
+
+// Humidex is Environment Canada's humidex, celsius in and out. 29 and
+// under is comfortable, over 45 dangerous.
+func Humidex(t, humidity float64) float64 {
+	e := humidity * 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+t)))
+	return t + 5.0/9*(e-10)
+}
+
+// WBGT is the Bureau of Meteorology's approximation of the wet bulb
+// globe temperature from the temperature and humidity alone, for
+// moderate sun and light wind, celsius in and out. Under 23 the risk
+// is low, over 33 extreme.
+func WBGT(t, humidity float64) float64 {
+	e := humidity * 6.105 * math.Exp(17.27*t/(237.7+t))
+	return 0.567*t + 0.393*e + 3.94
+}
+
+// modelComfort scores t (fahrenheit) with model as 0-100, falling in a
+// straight line from the index's comfortable limit to its dangerous
+// one. The indices only measure heat, so the cold side still falls off
+// from perfect like the custom model and the score is the worse of the
+// two.
+func modelComfort(model string, t, humidity, perfect float64) float64 {
+	cold := 100.0
+	if t < perfect {
+		cold = t + 100 - perfect
+	}
+	c := ConvertTemp(t, "us", "si")
+	var v, ok, bad float64
+	switch model {
+	case "thi":
+		v, ok, bad = THI(c, humidity), 21, 32
+	case "humidex":
+		v, ok, bad = Humidex(c, humidity), 29, 45
+	case "wbgt":
+		v, ok, bad = WBGT(c, humidity), 23, 33
+	default:
+		return cold
+	}
+	heat := 100 * (bad - v) / (bad - ok)
+	return math.Min(cold, factor(heat))
+}
@@ -0,0 +1,83 @@
+package weather
+
+import (
+	"math"
+	"testing"
+)
+
+// TestApparentTemp is against the NWS heat index and wind chill charts,
+// which are rounded to the degree
+func TestApparentTemp(t *testing.T) {
+	for _, c := range []struct {
+		t, humidity, wind, want float64
+	}{
+		// heat index
+		{80, .40, 0, 80},
+		{90, .50, 0, 95},
+		{96, .60, 0, 116},
+		{100, .40, 0, 109},
+		{86, .90, 0, 105},
+		// wind chill
+		{40, .5, 5, 36},
+		{30, .5, 10, 21},
+		{0, .5, 15, -19},
+		{-10, .5, 20, -35},
+		// in between, or too calm for wind chill
+		{65, .9, 20, 65},
+		{40, .5, 2, 40},
+	} {
+		if got := ApparentTemp(c.t, c.humidity, c.wind); math.Abs(got-c.want) > .5 {
+			t.Errorf("ApparentTemp(%g, %g, %g) = %.1f, want %g", c.t, c.humidity, c.wind, got, c.want)
+		}
+	}
+}
+
+// TestComfortIndices is each -comfort-model index against published
+// values
+func TestComfortIndices(t *testing.T) {
+	// the relative humidity at t with dew point dp, by the vapour
+	// pressure formula humidex is defined with
+	rh := func(t, dp float64) float64 {
+		e := func(t float64) float64 { return 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+t))) }
+		return e(dp) / e(t)
+	}
+	for _, c := range []struct {
+		name            string
+		index           func(t, humidity float64) float64
+		t, humidity     float64
+		want, tolerance float64
+	}{
+		// Thom's discomfort index, to a tenth
+		{"THI", THI, 30, .5, 25.7, .05},
+		{"THI", THI, 14.5, .2, 14.5, .05},
+		{"THI", THI, 27, 1, 27, .05},
+		// Environment Canada's worked example, 30C with a 15C dew point is
+		// a humidex of 34
+		{"Humidex", Humidex, 30, rh(30, 15), 34, .5},
+		// no vapour pressure over 10hPa, no difference
+		{"Humidex", Humidex, 20, rh(20, 6.98), 20, .05},
+		// the Bureau of Meteorology's approximate WBGT, to a tenth
+		{"WBGT", WBGT, 30, .5, 29.3, .05},
+		{"WBGT", WBGT, 25, .5, 24.3, .05},
+	} {
+		if got := c.index(c.t, c.humidity); math.Abs(got-c.want) > c.tolerance {
+			t.Errorf("%s(%g, %.3f) = %.2f, want %g", c.name, c.t, c.humidity, got, c.want)
+		}
+	}
+}
+
+// TestModelComfort is an index's comfortable limit scoring 100, its
+// dangerous one 0 and halfway 50. At 100% humidity THI is the
+// temperature.
+func TestModelComfort(t *testing.T) {
+	f := func(c float64) float64 { return ConvertTemp(c, "si", "us") }
+	for _, c := range []struct{ thi, want float64 }{{18, 100}, {21, 100}, {26.5, 50}, {32, 0}, {40, 0}} {
+		if got := modelComfort("thi", f(c.thi), 1, 60); math.Abs(got-c.want) > .01 {
+			t.Errorf("THI %g scored %g, want %g", c.thi, got, c.want)
+		}
+	}
+	// the cold side falls off from perfect like the custom model
+	if got := modelComfort("thi", 50, .5, 80); got != 70 {
+		t.Errorf("50F with a perfect 80 scored %g, want 70", got)
+	}
+}
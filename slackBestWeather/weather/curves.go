@@ -0,0 +1,87 @@
+package weather
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Curve reshapes a comfort factor before it's weighed. The factors fall
+// off in a straight line from perfect, a curve changes how hard: quadratic
+// goes easy on a small miss and catches up by the far end, sigmoid barely
+// notices a small miss and all but zeroes a big one.
+type Curve string
+
+const (
+	CurveLinear    Curve = "linear"
+	CurveQuadratic Curve = "quadratic"
+	CurveSigmoid   Curve = "sigmoid"
+)
+
+// how steep the sigmoid is and where, as a share of the factor's range,
+// it's halfway down
+const (
+	sigmoidSteepness = 10
+	sigmoidMidpoint  = .5
+)
+
+// Apply reshapes a 0-100 factor, 100 being perfect
+func (c Curve) Apply(v float64) float64 {
+	miss := 1 - v/100
+	switch c {
+	case CurveQuadratic:
+		miss *= miss
+	case CurveSigmoid:
+		sig := func(x float64) float64 { return 1 / (1 + math.Exp(-sigmoidSteepness*(x-sigmoidMidpoint))) }
+		// stretched so no miss is still 100 and the most is still 0
+		lo, hi := sig(0), sig(1)
+		miss = (sig(miss) - lo) / (hi - lo)
+	}
+	return 100 * (1 - miss)
+}
+
+// Curves are the curve for each comfort factor, unset is linear, which is
+// how the factors have always scored
+type Curves struct {
+	High, Low, Clouds, Precip, Humidity Curve
+}
+
+// Fields are the curves by factor name
+func (c *Curves) Fields() map[string]*Curve {
+	return map[string]*Curve{
+		"high": &c.High, "low": &c.Low, "clouds": &c.Clouds,
+		"precip": &c.Precip, "humidity": &c.Humidity,
+	}
+}
+
+func (c *Curves) String() string {
+	if c == nil {
+		return ""
+	}
+	var s []string
+	for _, k := range []string{"high", "low", "clouds", "precip", "humidity"} {
+		if v := *c.Fields()[k]; v != "" && v != CurveLinear {
+			s = append(s, fmt.Sprintf("%s=%s", k, v))
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+// Set takes factor=curve pairs, comma separated, eg high=sigmoid,precip=quadratic
+func (c *Curves) Set(s string) error {
+	fs := c.Fields()
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		p, found := fs[k]
+		if !ok || !found {
+			return fmt.Errorf("%q should be factor=curve, the factors are high, low, clouds, precip and humidity", kv)
+		}
+		switch cv := Curve(v); cv {
+		case CurveLinear, CurveQuadratic, CurveSigmoid:
+			*p = cv
+		default:
+			return fmt.Errorf("%s curve %q should be linear, quadratic or sigmoid", k, v)
+		}
+	}
+	return nil
+}
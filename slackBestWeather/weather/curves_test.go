@@ -0,0 +1,44 @@
+package weather
+
+import (
+	"math"
+	"testing"
+)
+
+// TestSigmoid is the sigmoid curve going easy on a mild miss and all but
+// zeroing a big one, with perfect and worst left where they were
+func TestSigmoid(t *testing.T) {
+	s := CurveSigmoid.Apply
+	if got := s(100); math.Abs(got-100) > 1e-9 {
+		t.Errorf("perfect is %g, want 100", got)
+	}
+	if got := s(0); math.Abs(got) > 1e-9 {
+		t.Errorf("worst is %g, want 0", got)
+	}
+	// a tenth off loses about 1 rather than 10, four fifths off keeps
+	// about 4 rather than 20
+	for _, c := range []struct{ v, min, max float64 }{{90, 98.5, 99.5}, {80, 95, 97}, {20, 3, 5}, {10, .5, 2}} {
+		if got := s(c.v); got < c.min || got > c.max {
+			t.Errorf("sigmoid(%g) = %.2f, want %g to %g", c.v, got, c.min, c.max)
+		}
+	}
+	for v := 0.0; v < 100; v++ {
+		if s(v+1) <= s(v) {
+			t.Fatalf("sigmoid(%g) = %g isn't above sigmoid(%g) = %g", v+1, s(v+1), v, s(v))
+		}
+	}
+	// the default curve is the straight line the factors always had
+	for _, v := range []float64{0, 37, 90, 100} {
+		if got := Curve("").Apply(v); math.Abs(got-v) > 1e-9 {
+			t.Errorf("unset curve(%g) = %g", v, got)
+		}
+	}
+	// and in a score: a high 8° off perfect barely costs anything, 70°
+	// off costs nearly everything
+	c := Config{Curve: Curves{High: CurveSigmoid}}
+	mild := FactorsOf(Day{TemperatureMax: 72, TemperatureMin: 60, Humidity: .6}, "us", Location{}, c).High
+	hot := FactorsOf(Day{TemperatureMax: 150, TemperatureMin: 60, Humidity: .6}, "us", Location{}, c).High
+	if mild < 98 || hot > 15 {
+		t.Errorf("8° off scored %.1f (linear 92) and 70° off %.1f (linear 30)", mild, hot)
+	}
+}
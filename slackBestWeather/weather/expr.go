@@ -0,0 +1,179 @@
+package weather
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+)
+
+// Expr is a user supplied scoring formula, written as a Go expression
+// over the metrics in ExprVars, eg
+//
+//	builtin - 200*precipProb + 2*sunshine
+//
+// It supports numbers, + - * /, comparisons and && || ! (true is 1,
+// false 0), parentheses and the functions min, max, abs, pow and sqrt.
+type Expr struct {
+	src string
+	e   ast.Expr
+}
+
+// String is the formula as it was written
+func (x *Expr) String() string { return x.src }
+
+// ExprVars are the metrics an Expr can use. Temperatures are
+// fahrenheit, wind mph and the fractions 0-1. builtin is what the normal
+// score gives the day. Visibility is miles and intensities inches per
+// hour.
+func ExprVars(d Day, units string, builtin float64) map[string]float64 {
+	return map[string]float64{
+		"tempMax":    ConvertTemp(d.TemperatureMax, units, "us"),
+		"tempMin":    ConvertTemp(d.TemperatureMin, units, "us"),
+		"humidity":   d.Humidity,
+		"cloudCover": d.CloudCover,
+		"precipProb": d.PrecipProbability,
+		"pressure":   d.Pressure,
+		"windSpeed":  ConvertSpeed(d.WindSpeed, units, "us"),
+		"dewPoint":   ConvertTemp(d.DewPoint, units, "us"),
+		"sunshine":   Sunshine(d),
+		"builtin":    builtin,
+		// the extra fields, 0 when the provider doesn't have them
+		"windGust":           ConvertSpeed(d.Extra.WindGust, units, "us"),
+		"precipIntensityMax": ConvertIntensity(d.Extra.PrecipIntensityMax, units, "us"),
+		"apparentMax":        ConvertTemp(d.Extra.ApparentTemperatureMax, units, "us"),
+		"apparentMin":        ConvertTemp(d.Extra.ApparentTemperatureMin, units, "us"),
+		"ozone":              d.Extra.Ozone,
+		"uvIndex":            d.Extra.UVIndex,
+		"moonPhase":          d.Extra.MoonPhase,
+		"visibility":         ConvertDistance(d.Visibility, units, "us"),
+	}
+}
+
+// ParseExpr parses s and checks it only uses known metrics and
+// functions, so a bad formula fails when it's given rather than mid run.
+func ParseExpr(s string) (*Expr, error) {
+	e, err := parser.ParseExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	x := &Expr{src: s, e: e}
+	if _, err := x.Eval(ExprVars(Day{}, "us", 0)); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Eval is the formula's value given the vars
+func (x *Expr) Eval(vars map[string]float64) (float64, error) {
+	return evalNode(x.e, vars)
+}
+
+func boolNum(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func evalNode(n ast.Expr, vars map[string]float64) (float64, error) {
+	switch n := n.(type) {
+	case *ast.BasicLit:
+		if n.Kind != token.INT && n.Kind != token.FLOAT {
+			return 0, fmt.Errorf("unsupported literal %s", n.Value)
+		}
+		return strconv.ParseFloat(n.Value, 64)
+	case *ast.Ident:
+		v, ok := vars[n.Name]
+		if !ok {
+			return 0, fmt.Errorf("unknown metric %q", n.Name)
+		}
+		return v, nil
+	case *ast.ParenExpr:
+		return evalNode(n.X, vars)
+	case *ast.UnaryExpr:
+		v, err := evalNode(n.X, vars)
+		if err != nil {
+			return 0, err
+		}
+		switch n.Op {
+		case token.SUB:
+			return -v, nil
+		case token.ADD:
+			return v, nil
+		case token.NOT:
+			return boolNum(v == 0), nil
+		}
+		return 0, fmt.Errorf("unsupported operator %s", n.Op)
+	case *ast.BinaryExpr:
+		a, err := evalNode(n.X, vars)
+		if err != nil {
+			return 0, err
+		}
+		b, err := evalNode(n.Y, vars)
+		if err != nil {
+			return 0, err
+		}
+		switch n.Op {
+		case token.ADD:
+			return a + b, nil
+		case token.SUB:
+			return a - b, nil
+		case token.MUL:
+			return a * b, nil
+		case token.QUO:
+			return a / b, nil
+		case token.LSS:
+			return boolNum(a < b), nil
+		case token.LEQ:
+			return boolNum(a <= b), nil
+		case token.GTR:
+			return boolNum(a > b), nil
+		case token.GEQ:
+			return boolNum(a >= b), nil
+		case token.EQL:
+			return boolNum(a == b), nil
+		case token.NEQ:
+			return boolNum(a != b), nil
+		case token.LAND:
+			return boolNum(a != 0 && b != 0), nil
+		case token.LOR:
+			return boolNum(a != 0 || b != 0), nil
+		}
+		return 0, fmt.Errorf("unsupported operator %s", n.Op)
+	case *ast.CallExpr:
+		fn, ok := n.Fun.(*ast.Ident)
+		if !ok {
+			return 0, fmt.Errorf("unsupported call")
+		}
+		var args []float64
+		for _, a := range n.Args {
+			v, err := evalNode(a, vars)
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, v)
+		}
+		want := map[string]int{"min": 2, "max": 2, "pow": 2, "abs": 1, "sqrt": 1}
+		if c, ok := want[fn.Name]; !ok {
+			return 0, fmt.Errorf("unknown function %q", fn.Name)
+		} else if len(args) != c {
+			return 0, fmt.Errorf("%s takes %d arguments", fn.Name, c)
+		}
+		switch fn.Name {
+		case "min":
+			return math.Min(args[0], args[1]), nil
+		case "max":
+			return math.Max(args[0], args[1]), nil
+		case "pow":
+			return math.Pow(args[0], args[1]), nil
+		case "abs":
+			return math.Abs(args[0]), nil
+		default:
+			return math.Sqrt(args[0]), nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported expression")
+}
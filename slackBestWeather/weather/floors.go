@@ -0,0 +1,53 @@
+package weather
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Floors are the least each comfort factor can score, out of its 100, so
+// one terrible number (a sure chance of rain, say) can only take so much
+// off an otherwise nice day. The high counts double so its floor does too.
+type Floors struct {
+	High, Low, Clouds, Precip, Humidity float64
+}
+
+// Fields are the floors by factor name
+func (f *Floors) Fields() map[string]*float64 {
+	return map[string]*float64{
+		"high": &f.High, "low": &f.Low, "clouds": &f.Clouds,
+		"precip": &f.Precip, "humidity": &f.Humidity,
+	}
+}
+
+func (f *Floors) String() string {
+	if f == nil {
+		return ""
+	}
+	var s []string
+	for _, k := range []string{"high", "low", "clouds", "precip", "humidity"} {
+		if v := *f.Fields()[k]; v > 0 {
+			s = append(s, fmt.Sprintf("%s=%g", k, v))
+		}
+	}
+	return strings.Join(s, ",")
+}
+
+// Set takes factor=floor pairs, comma separated, eg precip=40,clouds=20
+func (f *Floors) Set(s string) error {
+	fs := f.Fields()
+	for _, kv := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		p, found := fs[k]
+		if !ok || !found {
+			return fmt.Errorf("%q should be factor=floor, the factors are high, low, clouds, precip and humidity", kv)
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || n < 0 || n > 100 {
+			return fmt.Errorf("%s floor %q should be 0 to 100", k, v)
+		}
+		*p = n
+	}
+	return nil
+}
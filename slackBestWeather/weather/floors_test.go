@@ -0,0 +1,33 @@
+package weather
+
+import "testing"
+
+// TestFloors is one bad factor no longer deciding between two days: a
+// perfect day but for a sure chance of rain, and a dry day that's only
+// all right. Without a floor the rain puts the perfect day behind, with
+// a precip floor it's ahead again.
+func TestFloors(t *testing.T) {
+	rain := Day{TemperatureMax: 80, TemperatureMin: 60, Humidity: .6, PrecipProbability: 1}
+	meh := Day{TemperatureMax: 70, TemperatureMin: 52, Humidity: .6, CloudCover: .3, PrecipProbability: .1}
+	plain := Config{}
+	floored := Config{Floor: Floors{Precip: 60}}
+	if r, m := plain.Day(rain, "us", Location{}), plain.Day(meh, "us", Location{}); r >= m {
+		t.Fatalf("without a floor the rainy day scored %g and the so-so one %g, want the so-so one ahead", r, m)
+	}
+	r, m := floored.Day(rain, "us", Location{}), floored.Day(meh, "us", Location{})
+	if r <= m {
+		t.Errorf("with a precip floor of 60 the rainy day scored %g and the so-so one %g, want the rainy one ahead", r, m)
+	}
+	if m != plain.Day(meh, "us", Location{}) {
+		t.Errorf("the floor changed the so-so day's score, none of its factors are under it")
+	}
+	// whatever the day, no factor scores under its floor
+	all := Floors{High: 50, Low: 40, Clouds: 30, Precip: 60, Humidity: 20}
+	awful := Day{TemperatureMax: 130, TemperatureMin: -20, Humidity: 1, CloudCover: 1, PrecipProbability: 1}
+	f := FactorsOf(awful, "us", Location{}, Config{Floor: all})
+	for name, got := range map[string]float64{"high": f.High, "low": f.Low, "clouds": f.Clouds, "precip": f.Precip, "humidity": f.Humidity} {
+		if floor := *all.Fields()[name]; got < floor {
+			t.Errorf("%s scored %g, under its floor of %g", name, got, floor)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+// Package weather is the provider neutral forecast model and the scoring
+// slackBestWeather ranks locations with. It does no io, everything here
+// is a function of its arguments.
+package weather
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Forecast is the provider neutral weather model everything past the
+// fetch works with. Temperatures are in Units, fractions are 0-1.
+type Forecast struct {
+	Units string
+	// Timezone is the IANA zone of the location, if the provider knows it
+	Timezone string
+	// Fetched is when the provider produced this forecast
+	Fetched time.Time
+	Daily   []Day
+	Hourly  []Hour
+	// Minutely is the next hour minute by minute. Not every provider
+	// has it.
+	Minutely        []Minute
+	MinutelySummary string
+	// Alerts are the severe weather alerts in effect, if the provider
+	// has them
+	Alerts []Alert
+}
+
+type Day struct {
+	Time              time.Time
+	Summary           string
+	Icon              string
+	Condition         Condition
+	TemperatureMax    float64
+	TemperatureMin    float64
+	Humidity          float64
+	CloudCover        float64
+	PrecipProbability float64
+	Pressure          float64
+	WindSpeed         float64
+	DewPoint          float64
+	// PrecipAccumulation is the day's snowfall, inches in us and cm
+	// otherwise
+	PrecipAccumulation float64
+	// Visibility is miles in us and uk2, km otherwise, 0 when unknown
+	Visibility float64
+	// Sunrise and Sunset are zero when the provider doesn't say
+	Sunrise, Sunset time.Time
+	// WindBearing is the degrees clockwise from north the wind blows from
+	WindBearing float64
+	// WaterTemp is the sea surface temperature for -mode beach, nil when
+	// there isn't one
+	WaterTemp *float64
+	Extra     DayExtra
+	// Missing are the scored metrics the provider didn't have, which are
+	// zero above but shouldn't be scored as zero
+	Missing Metric
+}
+
+// DayExtra are the less common daily fields, which not every provider
+// has (they're zero when it doesn't). Nothing scores them by default,
+// they're for -score-expr and the json output. Gusts are in the wind
+// speed units, intensities in the precipitation ones.
+type DayExtra struct {
+	WindGust               float64 `json:"windGust,omitempty"`
+	PrecipIntensity        float64 `json:"precipIntensity,omitempty"`
+	PrecipIntensityMax     float64 `json:"precipIntensityMax,omitempty"`
+	PrecipType             string  `json:"precipType,omitempty"`
+	ApparentTemperatureMax float64 `json:"apparentTemperatureMax,omitempty"`
+	ApparentTemperatureMin float64 `json:"apparentTemperatureMin,omitempty"`
+	// Ozone is in Dobson units
+	Ozone   float64 `json:"ozone,omitempty"`
+	UVIndex float64 `json:"uvIndex,omitempty"`
+	// MoonPhase is 0 for new, .5 for full
+	MoonPhase float64 `json:"moonPhase,omitempty"`
+}
+
+func (e *DayExtra) Convert(from, to string) {
+	e.WindGust = ConvertSpeed(e.WindGust, from, to)
+	e.PrecipIntensity = ConvertIntensity(e.PrecipIntensity, from, to)
+	e.PrecipIntensityMax = ConvertIntensity(e.PrecipIntensityMax, from, to)
+	e.ApparentTemperatureMax = ConvertTemp(e.ApparentTemperatureMax, from, to)
+	e.ApparentTemperatureMin = ConvertTemp(e.ApparentTemperatureMin, from, to)
+}
+
+type Hour struct {
+	Time              time.Time
+	Summary           string
+	Icon              string
+	Condition         Condition
+	Temperature       float64
+	Humidity          float64
+	CloudCover        float64
+	PrecipProbability float64
+	Pressure          float64
+	WindSpeed         float64
+	DewPoint          float64
+}
+
+type Minute struct {
+	Time              time.Time
+	PrecipIntensity   float64
+	PrecipProbability float64
+}
+
+// Convert changes the temperatures and wind speeds in f to the given units
+func (f *Forecast) Convert(units string) {
+	for i := range f.Daily {
+		d := &f.Daily[i]
+		d.TemperatureMax = ConvertTemp(d.TemperatureMax, f.Units, units)
+		d.TemperatureMin = ConvertTemp(d.TemperatureMin, f.Units, units)
+		d.WindSpeed = ConvertSpeed(d.WindSpeed, f.Units, units)
+		d.DewPoint = ConvertTemp(d.DewPoint, f.Units, units)
+		d.PrecipAccumulation = ConvertDepth(d.PrecipAccumulation, f.Units, units)
+		d.Visibility = ConvertDistance(d.Visibility, f.Units, units)
+		d.Extra.Convert(f.Units, units)
+	}
+	for i := range f.Hourly {
+		h := &f.Hourly[i]
+		h.Temperature = ConvertTemp(h.Temperature, f.Units, units)
+		h.WindSpeed = ConvertSpeed(h.WindSpeed, f.Units, units)
+		h.DewPoint = ConvertTemp(h.DewPoint, f.Units, units)
+	}
+	for i := range f.Minutely {
+		m := &f.Minutely[i]
+		m.PrecipIntensity = ConvertIntensity(m.PrecipIntensity, f.Units, units)
+	}
+	f.Units = units
+}
+
+// EnsureDays makes sure f has n days of daily data if it can. When the
+// daily block is short and fallbackHourly is set the missing days are
+// built from the hourly block. It returns the number of days available.
+func (f *Forecast) EnsureDays(n int, fallbackHourly bool) int {
+	if len(f.Daily) >= n || !fallbackHourly || len(f.Hourly) == 0 {
+		return len(f.Daily)
+	}
+	tz, err := time.LoadLocation(f.Timezone)
+	if err != nil {
+		tz = time.UTC
+	}
+	have := make(map[string]bool)
+	for _, d := range f.Daily {
+		have[d.Time.In(tz).Format("2006-01-02")] = true
+	}
+	var dates []string
+	byDate := make(map[string][]Hour)
+	for _, h := range f.Hourly {
+		k := h.Time.In(tz).Format("2006-01-02")
+		if have[k] {
+			continue
+		}
+		if _, ok := byDate[k]; !ok {
+			dates = append(dates, k)
+		}
+		byDate[k] = append(byDate[k], h)
+	}
+	for _, k := range dates {
+		if len(f.Daily) >= n {
+			break
+		}
+		f.Daily = append(f.Daily, DayFromHours(byDate[k]))
+	}
+	return len(f.Daily)
+}
+
+// DayFromHours summarizes a day's worth of hourly data the way the daily
+// block would: temperature extremes, average humidity, cloud cover,
+// pressure and dew point, and the worst chance of rain and wind.
+func DayFromHours(hs []Hour) Day {
+	d := Day{
+		Time:           hs[0].Time,
+		TemperatureMax: hs[0].Temperature,
+		TemperatureMin: hs[0].Temperature,
+	}
+	// the conditions at midday, or as close as we have, describe the day
+	mid := hs[len(hs)/2]
+	d.Summary, d.Icon, d.Condition = mid.Summary, mid.Icon, mid.Condition
+	for _, h := range hs {
+		d.TemperatureMax = math.Max(d.TemperatureMax, h.Temperature)
+		d.TemperatureMin = math.Min(d.TemperatureMin, h.Temperature)
+		d.PrecipProbability = math.Max(d.PrecipProbability, h.PrecipProbability)
+		d.WindSpeed = math.Max(d.WindSpeed, h.WindSpeed)
+		d.Humidity += h.Humidity
+		d.CloudCover += h.CloudCover
+		d.Pressure += h.Pressure
+		d.DewPoint += h.DewPoint
+	}
+	c := float64(len(hs))
+	d.Humidity, d.CloudCover, d.Pressure = d.Humidity/c, d.CloudCover/c, d.Pressure/c
+	d.DewPoint /= c
+	return d
+}
+
+// Sunshine is the percent chance of a clear and dry day
+func Sunshine(d Day) float64 {
+	return (1 - d.CloudCover) * (1 - d.PrecipProbability) * 100
+}
+
+// Condition is the provider neutral sky, each provider maps its own
+// icons or codes onto it once and the outputs only deal with these
+type Condition int
+
+const (
+	CondUnknown Condition = iota
+	CondClear
+	CondPartlyCloudy
+	CondCloudy
+	CondRain
+	CondSnow
+	CondSleet
+	CondWind
+	CondFog
+)
+
+var conditionNames = map[Condition]string{
+	CondUnknown:      "unknown",
+	CondClear:        "clear",
+	CondPartlyCloudy: "partly-cloudy",
+	CondCloudy:       "cloudy",
+	CondRain:         "rain",
+	CondSnow:         "snow",
+	CondSleet:        "sleet",
+	CondWind:         "wind",
+	CondFog:          "fog",
+}
+
+func (c Condition) String() string { return conditionNames[c] }
+
+// MarshalText makes the json output the name rather than a number
+func (c Condition) MarshalText() ([]byte, error) { return []byte(c.String()), nil }
+
+func (c *Condition) UnmarshalText(b []byte) error {
+	for k, v := range conditionNames {
+		if v == string(b) {
+			*c = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown condition %q", b)
+}
@@ -0,0 +1,172 @@
+package weather
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Metric is a set of the scored daily metrics, for saying which a
+// provider left out. A zero is a real reading, a missing metric is in
+// Day.Missing.
+type Metric uint
+
+const (
+	TempMax Metric = 1 << iota
+	TempMin
+	Humidity
+	CloudCover
+	PrecipProbability
+)
+
+var metricNames = []struct {
+	m    Metric
+	name string
+}{
+	{TempMax, "temperatureMax"},
+	{TempMin, "temperatureMin"},
+	{Humidity, "humidity"},
+	{CloudCover, "cloudCover"},
+	{PrecipProbability, "precipProbability"},
+}
+
+// MetricNamed is the metric with the given json name, 0 if there's none
+func MetricNamed(name string) Metric {
+	for _, n := range metricNames {
+		if n.name == name {
+			return n.m
+		}
+	}
+	return 0
+}
+
+func (m Metric) String() string {
+	var s []string
+	for _, n := range metricNames {
+		if m&n.m != 0 {
+			s = append(s, n.name)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// Needs are the metrics c's mode can't score without
+func (c Config) Needs() Metric {
+	sky := CloudCover | PrecipProbability
+	if c.Missing.Mode == "neutral" {
+		return c.needs() &^ sky
+	}
+	if c.Missing.Mode == "skip" && (c.Mode == "daily" || c.Mode == "peak" || c.Mode == "event" || c.Mode == "") {
+		// only the comfort score can do without a factor
+		return c.needs() &^ sky
+	}
+	return c.needs()
+}
+
+func (c Config) needs() Metric {
+	switch c.Mode {
+	case "now":
+		// the minutely block
+		return 0
+	case "ski":
+		// no snow is a fine reading
+		return TempMax
+	case "photo":
+		return CloudCover | PrecipProbability
+	case "sail":
+		return PrecipProbability
+	case "beach":
+		return TempMax | CloudCover | PrecipProbability
+	}
+	return TempMax | TempMin | Humidity | CloudCover | PrecipProbability
+}
+
+// count is how many of the metrics in of are in m
+func (m Metric) count(of Metric) int {
+	n := 0
+	for _, x := range metricNames {
+		if m&of&x.m != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Sky is how a day with no cloud cover or precip probability is scored.
+// Left as zero they'd read as perfectly clear and dry, the best case.
+type Sky struct {
+	// Mode is exclude (the default) to leave the location out, neutral
+	// to score the missing ones as Value, or skip to score the comfort
+	// factors there are and scale them up to make up for the rest
+	Mode string
+	// Value is the 0-1 cloud cover or precip probability for neutral
+	Value float64
+}
+
+// DefaultSkyValue is a coin flip, neither best case nor worst
+const DefaultSkyValue = .5
+
+func (s Sky) String() string {
+	if s.Mode == "" {
+		return "exclude"
+	}
+	return s.Mode
+}
+
+func (s *Sky) Set(v string) error {
+	switch v {
+	case "exclude", "neutral", "skip":
+		s.Mode = v
+		return nil
+	}
+	return fmt.Errorf("%q should be exclude, neutral or skip", v)
+}
+
+// fill puts Value in for d's missing sky metrics when Mode is neutral
+func (s Sky) fill(d Day) Day {
+	if s.Mode != "neutral" {
+		return d
+	}
+	if d.Missing&CloudCover != 0 {
+		d.CloudCover = s.Value
+	}
+	if d.Missing&PrecipProbability != 0 {
+		d.PrecipProbability = s.Value
+	}
+	return d
+}
+
+// skipped are the comfort factors to leave out of d's score. Sunshine
+// is made of both, so it goes if either is missing.
+func (s Sky) skipped(d Day, sunshine bool) Metric {
+	if s.Mode != "skip" {
+		return 0
+	}
+	m := d.Missing & (CloudCover | PrecipProbability)
+	if sunshine && m != 0 {
+		m = CloudCover | PrecipProbability
+	}
+	return m
+}
+
+// Defaulted says what happened to the sky metrics missing from days,
+// eg "cloudCover scored as 50%", for verbose output. It's empty when
+// nothing was defaulted.
+func (c Config) Defaulted(days []Day) string {
+	m := MissingIn(days, CloudCover|PrecipProbability)
+	if m == 0 || c.Needs()&m != 0 {
+		return ""
+	}
+	if c.Missing.Mode == "neutral" {
+		return fmt.Sprintf("%s scored as %.0f%%", m, c.Missing.Value*100)
+	}
+	return fmt.Sprintf("%s left out of the score", m)
+}
+
+// MissingIn is which of the needed metrics are missing from any of days
+func MissingIn(days []Day, needs Metric) Metric {
+	var m Metric
+	for _, d := range days {
+		m |= d.Missing & needs
+	}
+	return m
+}
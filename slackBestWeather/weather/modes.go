@@ -0,0 +1,179 @@
+package weather
+
+import "math"
+
+const (
+	// a foot of new snow is as good as it gets
+	perfectSnow = 12.0
+	// highs at or below skiColdest are perfectly cold, by skiWarmest the
+	// snow is slush
+	skiColdest = 28.0
+	skiWarmest = 45.0
+)
+
+// skiDay scores fresh snow and cold out of BestScore, 400 for the snow and
+// 200 for the high. It turns the comfort formula upside down, snow and
+// cold are what we want.
+func skiDay(d Day, units string) float64 {
+	snow := ConvertDepth(d.PrecipAccumulation, units, "us")
+	tmax := ConvertTemp(d.TemperatureMax, units, "us")
+	snowPts := math.Min(1, math.Max(0, snow/perfectSnow)) * 400
+	coldPts := math.Min(1, math.Max(0, (skiWarmest-tmax)/(skiWarmest-skiColdest))) * 200
+	return snowPts + coldPts
+}
+
+const (
+	// partly cloudy makes for the best sunset, clear is dull and overcast
+	// hides it
+	photoClouds = .4
+	// past about ten miles the air is as clear as it gets
+	clearAir = 10.0
+	// daylight from photoShortDay to photoLongDay hours scores 0 to 100
+	photoShortDay = 8.0
+	photoLongDay  = 16.0
+)
+
+// photoDay scores a day for golden hour photography out of BestScore: 200
+// for clouds near photoClouds, 200 for staying dry, 100 for visibility and
+// 100 for a long day with a late sunset. Visibility and Daylight score
+// half when the provider doesn't have them.
+func photoDay(d Day, units string) float64 {
+	clouds := math.Max(0, 1-math.Abs(d.CloudCover-photoClouds)/(1-photoClouds)) * 200
+	dry := (1 - d.PrecipProbability) * 200
+	vis := 50.0
+	if d.Visibility > 0 {
+		vis = math.Min(1, ConvertDistance(d.Visibility, units, "us")/clearAir) * 100
+	}
+	light := 50.0
+	if !d.Sunrise.IsZero() && !d.Sunset.IsZero() {
+		h := d.Sunset.Sub(d.Sunrise).Hours()
+		light = factor((h - photoShortDay) / (photoLongDay - photoShortDay) * 100)
+	}
+	return clouds + factor(dry/2)*2 + vis + light
+}
+
+const (
+	// a breeze from sailLight to sailStrong mph is perfect, it's too calm
+	// to move at none and too much by sailGale
+	sailLight  = 10.0
+	sailStrong = 18.0
+	sailGale   = 30.0
+)
+
+// sailDay scores a day for sailing out of BestScore: 300 for the wind
+// speed, 200 for the wind coming from the location's bearing (half when
+// it has none, nothing when it's dead opposite) and 100 for staying dry.
+func sailDay(d Day, units string, l Location) float64 {
+	ws := ConvertSpeed(d.WindSpeed, units, "us")
+	var speed float64
+	switch {
+	case ws < sailLight:
+		speed = ws / sailLight
+	case ws <= sailStrong:
+		speed = 1
+	default:
+		speed = math.Max(0, (sailGale-ws)/(sailGale-sailStrong))
+	}
+	dir := 100.0
+	if l.Bearing != nil {
+		// cos of the angle off the bearing, 1 on it and -1 opposite
+		off := (d.WindBearing - *l.Bearing) * math.Pi / 180
+		dir = (math.Cos(off) + 1) / 2 * 200
+	}
+	return speed*300 + dir + factor((1-d.PrecipProbability)*100)
+}
+
+// dewPointComfort scores a dew point (fahrenheit) on the same 40-100
+// range as the relative humidity factor. Up to 55 is comfortable, it's
+// sticky by 65, uncomfortable by 70 and oppressive past 75.
+func dewPointComfort(dp float64) float64 {
+	switch {
+	case dp <= 55:
+		return 100
+	case dp <= 65:
+		return 100 - (dp-55)*2
+	case dp <= 75:
+		return 80 - (dp-65)*4
+	}
+	return 40
+}
+
+// highFactor scores a high temperature (fahrenheit), 100 at perfect
+// (usually PerfectMaxTemp)
+func highFactor(tmax, perfect float64) float64 {
+	if tmax > perfect {
+		tmax = perfect*2 - tmax
+	}
+	return tmax + 100 - perfect
+}
+
+// an hourly precipitation intensity (inches) that's a soaking
+const heavyRain = 0.1
+
+// NowScore is 0-100 for how dry the next hour will be: each minute counts
+// the chance it stays dry, less for the heavier the rain would be. Without
+// minutely data the first hour of the hourly forecast stands in.
+func NowScore(f *Forecast) float64 {
+	ms := f.Minutely
+	if len(ms) == 0 {
+		if len(f.Hourly) == 0 {
+			return 0
+		}
+		return (1 - f.Hourly[0].PrecipProbability) * 100
+	}
+	var total float64
+	for _, m := range ms {
+		in := ConvertIntensity(m.PrecipIntensity, f.Units, "us")
+		total += (1 - m.PrecipProbability) * (1 - math.Min(1, in/heavyRain))
+	}
+	return total / float64(len(ms)) * 100
+}
+
+const (
+	// water at perfectWater or warmer is perfect for swimming, by
+	// coldWater nobody's going in
+	perfectWater = 78.0
+	coldWater    = 60.0
+	// wind up to calmWind mph is fine on the sand, by sandblast it isn't
+	calmWind  = 10.0
+	sandblast = 25.0
+)
+
+// beachDay scores a beach day out of BestScore: 150 for the water, 150
+// for the high, 200 for sun and 100 for not much wind. Without a water
+// temperature, inland or when the source didn't have one, the water
+// scores half.
+func beachDay(d Day, units string) float64 {
+	water := 75.0
+	if d.WaterTemp != nil {
+		w := ConvertTemp(*d.WaterTemp, units, "us")
+		water = math.Min(1, math.Max(0, (w-coldWater)/(perfectWater-coldWater))) * 150
+	}
+	air := factor(highFactor(ConvertTemp(d.TemperatureMax, units, "us"), PerfectMaxTemp)) * 1.5
+	sun := factor(Sunshine(d)) * 2
+	ws := ConvertSpeed(d.WindSpeed, units, "us")
+	wind := math.Min(1, math.Max(0, (sandblast-ws)/(sandblast-calmWind))) * 100
+	return water + air + sun + wind
+}
+
+// gustyShare is how much of its comfort score a day keeps in event mode
+// when Gusty
+const gustyShare = .25
+
+// Gusty is whether d's gusts go over limit (mph), too much for tents and
+// umbrellas whatever the sustained wind. A day without gusts in the
+// forecast isn't gusty.
+func Gusty(d Day, units string, limit float64) bool {
+	return limit > 0 && ConvertSpeed(d.Extra.WindGust, units, "us") > limit
+}
+
+// eventDay scores a day for an outdoor event: the comfort score, cut to
+// gustyShare of itself when it's Gusty. It's a safety call rather than
+// a matter of comfort, so it's a hard cut rather than another factor.
+func eventDay(d Day, units string, l Location, c Config) float64 {
+	s := scoreDay(d, units, l, c)
+	if Gusty(d, units, c.GustLimit) {
+		s *= gustyShare
+	}
+	return s
+}
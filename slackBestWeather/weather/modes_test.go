@@ -0,0 +1,72 @@
+package weather
+
+import (
+	"math"
+	"testing"
+)
+
+// TestDewPointComfort is the humidity factor at the dew point comfort
+// breakpoints: comfortable to 55F, sticky by 65, uncomfortable by 70 and
+// oppressive from 75
+func TestDewPointComfort(t *testing.T) {
+	for _, c := range []struct{ dp, want float64 }{
+		{30, 100},
+		{55, 100},
+		{60, 90},
+		{65, 80},
+		{70, 60},
+		{75, 40},
+		{85, 40},
+	} {
+		if got := dewPointComfort(c.dp); got != c.want {
+			t.Errorf("dewPointComfort(%g) = %g, want %g", c.dp, got, c.want)
+		}
+	}
+	// and in the score, from celsius: 21.1C is 70F
+	d := Day{TemperatureMax: 26.7, TemperatureMin: 15.6, DewPoint: 21.1111}
+	if got := FactorsOf(d, "si", Location{}, Config{DewPoint: true}).Humidity; math.Abs(got-60) > .01 {
+		t.Errorf("a 21.1C dew point's humidity factor is %g, want 60", got)
+	}
+}
+
+// TestGusty is -mode event's gust limit: a gust right at it is fine,
+// anything over it is gusty, in any units, and a gusty day keeps
+// gustyShare of its score
+func TestGusty(t *testing.T) {
+	const limit = 30
+	for _, c := range []struct {
+		units string
+		gust  float64
+		want  bool
+	}{
+		{"us", 0, false},
+		{"us", 29.99, false},
+		{"us", 30, false},
+		{"us", 30.01, true},
+		// 30mph in m/s and km/h
+		{"si", 13.4112, false},
+		{"si", 13.42, true},
+		{"ca", 48.28032, false},
+		{"ca", 48.3, true},
+	} {
+		d := Day{TemperatureMax: 75, TemperatureMin: 58, Humidity: .5, Extra: DayExtra{WindGust: c.gust}}
+		if c.units != "us" {
+			d.TemperatureMax, d.TemperatureMin = ConvertTemp(75, "us", c.units), ConvertTemp(58, "us", c.units)
+		}
+		if got := Gusty(d, c.units, limit); got != c.want {
+			t.Errorf("%g %s gusts: Gusty = %v, want %v", c.gust, c.units, got, c.want)
+		}
+		event := Config{Mode: "event", GustLimit: limit}.Day(d, c.units, Location{})
+		daily := Config{}.Day(d, c.units, Location{})
+		want := daily
+		if c.want {
+			want *= gustyShare
+		}
+		if math.Abs(event-want) > 1e-9 {
+			t.Errorf("%g %s gusts: event scored %g, want %g", c.gust, c.units, event, want)
+		}
+	}
+	if Gusty(Day{Extra: DayExtra{WindGust: 100}}, "us", 0) {
+		t.Error("a limit of 0 should turn the check off")
+	}
+}
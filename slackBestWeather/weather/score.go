@@ -0,0 +1,386 @@
+package weather
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	PerfectMaxTemp  = 80
+	PerfectMinTemp  = 60
+	PerfectHumidity = .6
+
+	// BestScore is what Score gives a perfect day: 200 for the high, 100
+	// each for the low, clouds, rain and humidity. Each factor is capped
+	// to its share (see factor) so a day scores 0 to BestScore however
+	// extreme the forecast, and one runaway number can't outweigh the
+	// rest. -score-expr, -adjust and -weight can still go outside it.
+	BestScore = 600
+)
+
+// factor caps a factor to the 0-100 share it's allowed
+func factor(v float64) float64 {
+	return math.Max(0, math.Min(100, v))
+}
+
+// Location is what scoring needs to know about a place
+type Location struct {
+	Lat, Lng float64
+	// Normals are the monthly normal highs in fahrenheit, January first
+	Normals []float64
+	// Bearing is the wind direction sail mode likes, nil for any
+	Bearing *float64
+}
+
+// SeasonalNormal is l's normal high for t's month, if it has normals
+func SeasonalNormal(l Location, t time.Time) (float64, bool) {
+	if len(l.Normals) != 12 {
+		return 0, false
+	}
+	return l.Normals[t.Month()-1], true
+}
+
+// Config holds the knobs that change how Score works
+type Config struct {
+	// Mode is daily for the usual comfort score over Days or now for how
+	// dry the next hour will be (see NowScore), or one of ski, photo,
+	// sail, beach, event and peak
+	Mode string
+	// GustLimit is the gust speed (mph) past which event mode marks a day
+	// down, see Gusty
+	GustLimit float64
+	// use Sunshine() in place of the cloud cover and precip factors
+	Sunshine bool
+	// score the feels like temperature (see ApparentTemp) rather than the air temperature
+	FeelsLike bool
+	// ComfortModel scores the high and low on a heat stress index, one
+	// of ComfortModels, rather than their distance from perfect. "" is
+	// custom.
+	ComfortModel string
+	// average the score over this many days, starting today
+	Days int
+	// DayOffset starts the Days that many days after today, eg 1 to score
+	// tomorrow. See Offset.
+	DayOffset int
+	// Weekend scores the coming Saturday and Sunday in place of Days.
+	// On a weekend that's the rest of this one, or with NextWeekend the
+	// one after.
+	Weekend, NextWeekend bool
+	// Decay is how much less each day counts than the one before in
+	// that average, see DayWeight. 0 counts them all the same.
+	Decay float64
+	// score mugginess on the dew point rather than relative humidity
+	DewPoint bool
+	// Surprise is how much of the improvement over the seasonal normal
+	// high (see SeasonalNormal) to add as a bonus, 0 turns it off
+	Surprise float64
+	// Expr replaces the built in formula when set
+	Expr *Expr
+	// Floor keeps each comfort factor from dropping below a share of its
+	// best, see Floors
+	Floor Floors
+	// Curve reshapes each comfort factor before the floor, see Curves
+	Curve Curves
+	// Season shifts the perfect temps with the time of year
+	Season SeasonCurve
+	// Missing is what to do about a day with no cloud cover or precip
+	// probability, see Sky
+	Missing Sky
+}
+
+// Comfort is score as a 0-100 index, 100 being perfect
+func (c Config) Comfort(score float64) float64 {
+	best := BestScore
+	if c.Mode == "now" {
+		best = 100
+	}
+	return math.Max(0, math.Min(100, score/float64(best)*100))
+}
+
+// Score is kept at full precision, it should only be rounded for
+// display so close locations still sort correctly.
+func Score(f *Forecast, l Location, c Config) float64 {
+	if c.Mode == "now" {
+		return NowScore(f)
+	}
+	if c.Mode == "peak" {
+		_, s := BestDay(f, l, c)
+		return s
+	}
+	days := ScoredDays(f, c)
+	if len(days) == 0 {
+		return 0
+	}
+	var total, weights float64
+	for i, d := range days {
+		w := c.DayWeight(i)
+		total += w * c.Day(d, f.Units, l)
+		weights += w
+	}
+	return total / weights
+}
+
+// DayWeight is how much the i'th scored day, 0 being today, counts in
+// the average. Forecasts get less reliable further out so with a Decay
+// of .2 tomorrow counts .8 as much as today, the day after .64 and so on.
+func (c Config) DayWeight(i int) float64 {
+	return math.Pow(1-c.Decay, float64(i))
+}
+
+// ScoredDays are the days Score looks at: the first Days, all of them
+// for peak, none for now (which scores the minutely block)
+func ScoredDays(f *Forecast, c Config) []Day {
+	switch c.Mode {
+	case "now":
+		return nil
+	case "peak":
+		return f.Daily
+	}
+	if c.Weekend {
+		return weekendDays(f, c.NextWeekend)
+	}
+	off, _ := c.Offset(f)
+	n := c.Days
+	if n < 1 {
+		n = 1
+	}
+	if off+n > len(f.Daily) {
+		n = len(f.Daily) - off
+	}
+	return f.Daily[off : off+n]
+}
+
+// Offset is the index in f of the first day to score, DayOffset clamped
+// to the days f has and whether it had to be
+func (c Config) Offset(f *Forecast) (int, bool) {
+	switch {
+	case c.DayOffset <= 0 || len(f.Daily) == 0:
+		return 0, false
+	case c.DayOffset >= len(f.Daily):
+		return len(f.Daily) - 1, true
+	}
+	return c.DayOffset, false
+}
+
+// weekendDays are the first Saturday and Sunday in f, by the date where
+// the location is. With next, a weekend f starts in is skipped.
+func weekendDays(f *Forecast, next bool) []Day {
+	tz, err := time.LoadLocation(f.Timezone)
+	if err != nil {
+		tz = time.Local
+	}
+	weekend := func(d Day) bool {
+		w := d.Time.In(tz).Weekday()
+		return w == time.Saturday || w == time.Sunday
+	}
+	i := 0
+	if next {
+		for i < len(f.Daily) && weekend(f.Daily[i]) {
+			i++
+		}
+	}
+	for i < len(f.Daily) && !weekend(f.Daily[i]) {
+		i++
+	}
+	start := i
+	for i < len(f.Daily) && weekend(f.Daily[i]) {
+		i++
+	}
+	return f.Daily[start:i]
+}
+
+// BestDay is the index and score of the best day in the whole forecast
+func BestDay(f *Forecast, l Location, c Config) (int, float64) {
+	best, bs := 0, math.Inf(-1)
+	for i, d := range f.Daily {
+		if s := c.Day(d, f.Units, l); s > bs {
+			best, bs = i, s
+		}
+	}
+	return best, bs
+}
+
+// Trend is the score of each of the next n days, starting today
+func Trend(f *Forecast, l Location, c Config, n int) []float64 {
+	if n > len(f.Daily) {
+		n = len(f.Daily)
+	}
+	t := make([]float64, 0, n)
+	for _, d := range f.Daily[:n] {
+		t = append(t, c.Day(d, f.Units, l))
+	}
+	return t
+}
+
+// dayKey is what a day's score depends on
+type dayKey struct {
+	c        Config
+	d        Day
+	units    string
+	lat, lng float64
+	// bearing is -1 when the location has none
+	bearing float64
+	// normals are the location's, for the surprise bonus, with
+	// hasNormals false when it has none (or not all 12)
+	normals    [12]float64
+	hasNormals bool
+}
+
+// dayScores remembers day scores, Score, BestDay and Trend all go over
+// the same days
+var dayScores = struct {
+	sync.Mutex
+	m map[dayKey]float64
+}{m: make(map[dayKey]float64)}
+
+// dayScoresMax bounds dayScores. A run only looks its own days up again,
+// so when a long lived process fills it, it's started over rather than
+// grown.
+const dayScoresMax = 1 << 14
+
+// Day is one day's score, by the Expr if there is one
+func (c Config) Day(d Day, units string, l Location) float64 {
+	k := dayKey{c: c, d: d, units: units, lat: l.Lat, lng: l.Lng, bearing: -1}
+	if l.Bearing != nil {
+		k.bearing = *l.Bearing
+	}
+	if len(l.Normals) == 12 {
+		k.hasNormals = true
+		copy(k.normals[:], l.Normals)
+	}
+	dayScores.Lock()
+	defer dayScores.Unlock()
+	if s, ok := dayScores.m[k]; ok {
+		return s
+	}
+	s := c.dayUncached(d, units, l)
+	if len(dayScores.m) >= dayScoresMax {
+		clear(dayScores.m)
+	}
+	dayScores.m[k] = s
+	return s
+}
+
+func (c Config) dayUncached(d Day, units string, l Location) float64 {
+	d = c.Missing.fill(d)
+	var s float64
+	switch c.Mode {
+	case "ski":
+		s = skiDay(d, units)
+	case "photo":
+		s = photoDay(d, units)
+	case "sail":
+		s = sailDay(d, units, l)
+	case "beach":
+		s = beachDay(d, units)
+	case "event":
+		s = eventDay(d, units, l, c)
+	default:
+		s = scoreDay(d, units, l, c)
+	}
+	if c.Expr != nil {
+		// ParseExpr already made sure every name is known
+		s, _ = c.Expr.Eval(ExprVars(d, units, s))
+	}
+	return s
+}
+
+// Factors are the comfort score's parts, each 0-100 (the high counts
+// double in the total) plus the Surprise bonus
+type Factors struct {
+	High, Low, Clouds, Precip, Humidity, Bonus float64
+	// Skipped are the factors left out for want of data, see Sky
+	Skipped Metric
+}
+
+func (f Factors) Total() float64 {
+	t := f.High*2 + f.Low + f.Clouds + f.Precip + f.Humidity
+	// the rest are scaled up to make up for what's skipped, so a day
+	// still scores out of BestScore
+	if n := f.Skipped.count(CloudCover | PrecipProbability); n > 0 {
+		t *= BestScore / (BestScore - 100*float64(n))
+	}
+	// the surprise bonus can't lift a day past perfect
+	return math.Min(BestScore, t+f.Bonus)
+}
+
+// FactorShares is how close to perfect (0-1) each part of the comfort
+// score came, for showing people where a score came from. Temp weighs
+// the high double like the score does.
+type FactorShares struct {
+	Temp     float64 `json:"temp"`
+	Sun      float64 `json:"sun"`
+	Dry      float64 `json:"dry"`
+	Humidity float64 `json:"humidity"`
+}
+
+func (f Factors) Shares() *FactorShares {
+	return &FactorShares{
+		Temp:     (f.High*2 + f.Low) / 300,
+		Sun:      f.Clouds / 100,
+		Dry:      f.Precip / 100,
+		Humidity: f.Humidity / 100,
+	}
+}
+
+func scoreDay(today Day, units string, l Location, c Config) float64 {
+	return FactorsOf(today, units, l, c).Total()
+}
+
+// FactorsOf breaks a day's comfort score down
+func FactorsOf(today Day, units string, l Location, c Config) Factors {
+	today = c.Missing.fill(today)
+	// the perfect temps are in fahrenheit
+	tmax := ConvertTemp(today.TemperatureMax, units, "us")
+	tmin := ConvertTemp(today.TemperatureMin, units, "us")
+	if c.FeelsLike {
+		wind := ConvertSpeed(today.WindSpeed, units, "us")
+		tmax = ApparentTemp(tmax, today.Humidity, wind)
+		tmin = ApparentTemp(tmin, today.Humidity, wind)
+	}
+	shift := c.Season.Shift(l.Lat, today.Time)
+	perfectMax, perfectMin := PerfectMaxTemp+shift, PerfectMinTemp+shift
+	var bonus float64
+	if normal, ok := SeasonalNormal(l, today.Time); ok && c.Surprise > 0 {
+		bonus = math.Max(0, highFactor(tmax, perfectMax)-highFactor(normal, perfectMax)) * c.Surprise
+	}
+	if c.ComfortModel != "" && c.ComfortModel != "custom" {
+		tmax = modelComfort(c.ComfortModel, tmax, today.Humidity, perfectMax)
+		tmin = modelComfort(c.ComfortModel, tmin, today.Humidity, perfectMin)
+	} else {
+		tmax = factor(highFactor(tmax, perfectMax))
+		if tmin > perfectMin {
+			tmin = perfectMin*2 - tmin
+		}
+		tmin = factor(tmin + 100 - perfectMin)
+	}
+	tmax = math.Max(c.Floor.High, c.Curve.High.Apply(tmax))
+	tmin = math.Max(c.Floor.Low, c.Curve.Low.Apply(tmin))
+	ccover := factor((1.0 - today.CloudCover) * 100)
+	precip := factor((1.0 - today.PrecipProbability) * 100)
+	if c.Sunshine {
+		// same 0-200 range as the two factors it replaces
+		ccover = factor(Sunshine(today))
+		precip = ccover
+	}
+	ccover = math.Max(c.Floor.Clouds, c.Curve.Clouds.Apply(ccover))
+	precip = math.Max(c.Floor.Precip, c.Curve.Precip.Apply(precip))
+	skipped := c.Missing.skipped(today, c.Sunshine)
+	if skipped&CloudCover != 0 {
+		ccover = 0
+	}
+	if skipped&PrecipProbability != 0 {
+		precip = 0
+	}
+	h := today.Humidity
+	if h > PerfectHumidity {
+		h = PerfectHumidity*2 - h
+	}
+	humid := factor(h*100 + 40)
+	if c.DewPoint {
+		humid = dewPointComfort(ConvertTemp(today.DewPoint, units, "us"))
+	}
+	humid = math.Max(c.Floor.Humidity, c.Curve.Humidity.Apply(humid))
+	return Factors{High: tmax, Low: tmin, Clouds: ccover, Precip: precip, Humidity: humid, Bonus: bonus, Skipped: skipped}
+}
@@ -0,0 +1,145 @@
+package weather
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// week is a representative us forecast: a week of days from perfect to
+// wet, cold and muggy
+func week() *Forecast {
+	f := &Forecast{Units: "us", Timezone: "America/New_York"}
+	start := time.Date(2016, 10, 13, 0, 0, 0, 0, time.UTC)
+	days := []struct{ tmax, tmin, hum, cc, pp float64 }{
+		{80, 60, .6, 0, 0},
+		{72, 55, .5, .2, .1},
+		{91, 74, .8, .4, .3},
+		{66, 49, .62, .06, 0},
+		{55, 41, .9, .95, .85},
+		{38, 24, .4, .7, .6},
+		{98, 81, .35, .1, .05},
+	}
+	for i, d := range days {
+		f.Daily = append(f.Daily, Day{
+			Time:              start.AddDate(0, 0, i),
+			TemperatureMax:    d.tmax,
+			TemperatureMin:    d.tmin,
+			Humidity:          d.hum,
+			CloudCover:        d.cc,
+			PrecipProbability: d.pp,
+			WindSpeed:         8,
+			DewPoint:          d.tmin - 5,
+		})
+	}
+	return f
+}
+
+// BenchmarkScore scores the week from scratch each time, not from the
+// memo
+func BenchmarkScore(b *testing.B) {
+	f := week()
+	l := Location{Lat: 42.36, Lng: -71.06}
+	c := Config{Days: 7}
+	for i := 0; i < b.N; i++ {
+		clear(dayScores.m)
+		Score(f, l, c)
+	}
+}
+
+// TestScoreBounds is the 0 to BestScore scale holding however extreme
+// the day, under every option that changes the comfort factors
+func TestScoreBounds(t *testing.T) {
+	perfect := Day{TemperatureMax: PerfectMaxTemp, TemperatureMin: PerfectMinTemp, Humidity: PerfectHumidity,
+		Time: time.Date(2016, 7, 1, 0, 0, 0, 0, time.UTC)}
+	extremes := []Day{
+		perfect,
+		{TemperatureMax: 1000, TemperatureMin: 900, Humidity: 5, CloudCover: 3, PrecipProbability: 4, DewPoint: 500, WindSpeed: 200},
+		{TemperatureMax: -1000, TemperatureMin: -900, Humidity: -5, CloudCover: -3, PrecipProbability: -4, DewPoint: -500, WindSpeed: 200},
+		{TemperatureMax: 140, TemperatureMin: -60, Humidity: 1, CloudCover: 1, PrecipProbability: 1},
+		{TemperatureMax: -40, TemperatureMin: 130, Humidity: 0},
+	}
+	// normals well below perfect, for the biggest surprise bonus
+	l := Location{Lat: 40, Normals: []float64{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}}
+	configs := map[string]Config{
+		"default":   {},
+		"sunshine":  {Sunshine: true},
+		"feelslike": {FeelsLike: true},
+		"dewpoint":  {DewPoint: true},
+		"surprise":  {Surprise: 10},
+		"thi":       {ComfortModel: "thi"},
+		"humidex":   {ComfortModel: "humidex"},
+		"wbgt":      {ComfortModel: "wbgt"},
+		"floored":   {Floor: Floors{High: 100, Low: 100, Clouds: 100, Precip: 100, Humidity: 100}},
+		"curved":    {Curve: Curves{High: CurveSigmoid, Low: CurveQuadratic, Clouds: CurveSigmoid, Precip: CurveQuadratic, Humidity: CurveSigmoid}},
+	}
+	for name, c := range configs {
+		for _, d := range extremes {
+			s := c.Day(d, "us", l)
+			if math.IsNaN(s) || s < 0 || s > BestScore {
+				t.Errorf("%s: %+v scored %g, outside 0 to %d", name, d, s, BestScore)
+			}
+		}
+	}
+	if s := (Config{}).Day(perfect, "us", Location{}); s != BestScore {
+		t.Errorf("a perfect day scored %g, want %d", s, BestScore)
+	}
+}
+
+// TestDayWeight is the -decay weighting: the powers themselves, and a
+// three day Score being their weighted average of the days' scores
+func TestDayWeight(t *testing.T) {
+	f := week()
+	l := Location{Lat: 42.36, Lng: -71.06}
+	for _, c := range []struct {
+		decay   float64
+		weights []float64
+	}{
+		// every day the same
+		{0, []float64{1, 1, 1}},
+		{.2, []float64{1, .8, .64}},
+		// all but today
+		{.999, []float64{1, .001, .000001}},
+	} {
+		cfg := Config{Days: 3, Decay: c.decay}
+		var total, weights float64
+		for i, w := range c.weights {
+			if got := cfg.DayWeight(i); math.Abs(got-w) > 1e-12 {
+				t.Errorf("decay %g: day %d weighs %g, want %g", c.decay, i, got, w)
+			}
+			total += w * cfg.Day(f.Daily[i], f.Units, l)
+			weights += w
+		}
+		if got, want := Score(f, l, cfg), total/weights; math.Abs(got-want) > 1e-9 {
+			t.Errorf("decay %g: scored %g, want %g", c.decay, got, want)
+		}
+	}
+	// with no decay it's the plain mean, near 1 it's today
+	days := []float64{Config{}.Day(f.Daily[0], f.Units, l), Config{}.Day(f.Daily[1], f.Units, l), Config{}.Day(f.Daily[2], f.Units, l)}
+	if got, want := Score(f, l, Config{Days: 3}), (days[0]+days[1]+days[2])/3; math.Abs(got-want) > 1e-9 {
+		t.Errorf("no decay scored %g, want the mean %g", got, want)
+	}
+	if got := Score(f, l, Config{Days: 3, Decay: .999}); math.Abs(got-days[0]) > .5 {
+		t.Errorf("decay .999 scored %g, want about today's %g", got, days[0])
+	}
+}
+
+// TestDayMemoNormals is two names at the same point, one with normals and
+// one without, each getting its own score for the same day rather than
+// whichever was worked out first
+func TestDayMemoNormals(t *testing.T) {
+	c := Config{Surprise: 1}
+	d := Day{TemperatureMax: 70, TemperatureMin: 55, Humidity: .6, Time: time.Date(2016, 10, 13, 0, 0, 0, 0, time.UTC)}
+	plain := Location{Lat: 40.7, Lng: -73.2}
+	cold := plain
+	cold.Normals = []float64{20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20, 20}
+	clear(dayScores.m)
+	a, b := c.Day(d, "us", plain), c.Day(d, "us", cold)
+	clear(dayScores.m)
+	if b2, a2 := c.Day(d, "us", cold), c.Day(d, "us", plain); a != a2 || b != b2 {
+		t.Errorf("the order changed the scores: %g and %g, then %g and %g", a, b, a2, b2)
+	}
+	if b <= a {
+		t.Errorf("70° with a normal of 20° scored %g, no better than without normals (%g)", b, a)
+	}
+}
@@ -0,0 +1,40 @@
+package weather
+
+import (
+	"math"
+	"time"
+)
+
+// SeasonCurve moves the perfect temperatures with the time of year, since
+// 65 is lovely in March and cool in August. The shift is a cosine over
+// the year,
+//
+//	shift = amplitude * cos(2π * (day of year - peak) / 365.25)
+//
+// so the perfect high is PerfectMaxTemp+amplitude on the peak day,
+// PerfectMaxTemp-amplitude half a year later and PerfectMaxTemp at the
+// equinoxes in between. The low moves the same. South of the equator the
+// curve is half a year out, so January is the warm end there. Zero
+// amplitude is the fixed perfect temps.
+type SeasonCurve struct {
+	// Amplitude is in fahrenheit
+	Amplitude float64
+	// Peak is the northern hemisphere day of the year with the warmest
+	// perfect temps, the warmest week of the year is about right
+	Peak int
+}
+
+// DefaultSeasonPeak is July 19th, give or take a leap year
+const DefaultSeasonPeak = 200
+
+// Shift is what to add to the perfect temps on t at latitude lat
+func (c SeasonCurve) Shift(lat float64, t time.Time) float64 {
+	if c.Amplitude == 0 {
+		return 0
+	}
+	d := float64(t.YearDay() - c.Peak)
+	if lat < 0 {
+		d -= 365.25 / 2
+	}
+	return c.Amplitude * math.Cos(2*math.Pi*d/365.25)
+}
@@ -0,0 +1,69 @@
+package weather
+
+// The units are forecast.io's: us, si, ca, uk and uk2.
+
+// Celsius is whether units report temperature in celsius, si, ca and uk2
+// all do
+func Celsius(units string) bool {
+	switch units {
+	case "si", "ca", "uk", "uk2":
+		return true
+	}
+	return false
+}
+
+func ConvertTemp(t float64, from, to string) float64 {
+	switch {
+	case Celsius(from) && !Celsius(to):
+		return t*9/5 + 32
+	case !Celsius(from) && Celsius(to):
+		return (t - 32) * 5 / 9
+	}
+	return t
+}
+
+// wind speed is mph in us and uk2, km/h in ca and m/s in si
+func ConvertSpeed(v float64, from, to string) float64 {
+	perMph := map[string]float64{"si": 0.44704, "ca": 1.609344}
+	if f, ok := perMph[from]; ok {
+		v /= f
+	}
+	if f, ok := perMph[to]; ok {
+		v *= f
+	}
+	return v
+}
+
+// precipitation intensity is inches per hour in us, mm per hour otherwise
+func ConvertIntensity(v float64, from, to string) float64 {
+	switch {
+	case Celsius(from) && !Celsius(to):
+		return v / 25.4
+	case !Celsius(from) && Celsius(to):
+		return v * 25.4
+	}
+	return v
+}
+
+// snow accumulation is inches in us, cm otherwise
+func ConvertDepth(v float64, from, to string) float64 {
+	switch {
+	case Celsius(from) && !Celsius(to):
+		return v / 2.54
+	case !Celsius(from) && Celsius(to):
+		return v * 2.54
+	}
+	return v
+}
+
+// visibility is miles in us and uk2, km otherwise
+func ConvertDistance(v float64, from, to string) float64 {
+	km := func(u string) bool { return u == "si" || u == "ca" || u == "uk" }
+	switch {
+	case km(from) && !km(to):
+		return v / 1.609344
+	case !km(from) && km(to):
+		return v * 1.609344
+	}
+	return v
+}
@@ -0,0 +1,26 @@
+package weather
+
+import (
+	"crypto/sha1"
+	"fmt"
+)
+
+// Revision goes up whenever the built in formulas change in a way that
+// moves scores, eg a new factor or different perfect temps
+const Revision = 1
+
+// Version identifies how c scores, so saved scores can be told apart
+// from ones that were worked out some other way. It's the Revision and
+// a hash of every setting that changes a score, eg "1-3fa2c9d0".
+func (c Config) Version() string {
+	expr := ""
+	if c.Expr != nil {
+		expr = c.Expr.String()
+	}
+	s := fmt.Sprintf("%s|%t/%t|%t|%t/%s|%d/%d|%t|%g|%q|%s|%s|%g/%d|%g|%s/%g|%g|%g/%g/%g/%g",
+		c.Mode, c.Weekend, c.NextWeekend, c.Sunshine, c.FeelsLike, c.ComfortModel, c.Days, c.DayOffset, c.DewPoint, c.Surprise, expr, c.Floor.String(), c.Curve.String(),
+		c.Season.Amplitude, c.Season.Peak, c.Decay, c.Missing, c.Missing.Value, c.GustLimit,
+		float64(PerfectMaxTemp), float64(PerfectMinTemp), float64(PerfectHumidity), float64(BestScore))
+	sum := sha1.Sum([]byte(s))
+	return fmt.Sprintf("%d-%x", Revision, sum[:4])
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// zoneFor is the time zone at a location: the one the provider named if
+// it's in the tz database, otherwise the offset from its longitude, an
+// hour every 15 degrees. That's the sun's time, which can be an hour or
+// so out from the clocks there but never a day.
+func zoneFor(name string, lng float64) *time.Location {
+	if name != "" {
+		if tz, err := time.LoadLocation(name); err == nil {
+			return tz
+		}
+	}
+	h := int(math.Round(lng / 15))
+	if h == 0 {
+		return time.UTC
+	}
+	return time.FixedZone(fmt.Sprintf("UTC%+d", h), h*3600)
+}
+
+// localTime is v's forecast day and fetch time where it is, for
+// -local-times, eg "Wed Oct 14 as of 7:05am EDT". In -mode peak the
+// summary already says the day.
+func localTime(v locScore) string {
+	tz := v.zone
+	if tz == nil {
+		tz = time.UTC
+	}
+	asOf := "as of " + v.Fetched.In(tz).Format("3:04pm MST")
+	if v.PeakDay != nil {
+		return asOf
+	}
+	return v.Fetched.In(tz).Format("Mon Jan 2") + " " + asOf
+}